@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// archiveEntry is a regular file of a bulk upload archive
+type archiveEntry struct {
+	name string
+	size int64
+	r    io.Reader
+}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// walkArchive calls fn with the regular files of a zip, tar or gzipped tar archive, in archive order.
+// An entry can only be read until fn returns.
+func walkArchive(archive io.ReaderAt, size int64, fn func(archiveEntry) error) error {
+	magic := make([]byte, 4)
+	if _, err := archive.ReadAt(magic, 0); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to read archive - %w", err)
+	}
+	if bytes.Equal(magic, zipMagic) {
+		return walkZip(archive, size, fn)
+	}
+
+	r := io.Reader(io.NewSectionReader(archive, 0, size))
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("unable to read gzip archive - %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+	return walkTar(r, fn)
+}
+
+func walkZip(archive io.ReaderAt, size int64, fn func(archiveEntry) error) error {
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return fmt.Errorf("unable to read zip archive - %w", err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("unable to read zip entry %s - %w", f.Name, err)
+		}
+		err = fn(archiveEntry{name: entryName(f.Name), size: int64(f.UncompressedSize64), r: rc})
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(r io.Reader, fn func(archiveEntry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read tar archive - %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = fn(archiveEntry{name: entryName(hdr.Name), size: hdr.Size, r: tr}); err != nil {
+			return err
+		}
+	}
+}
+
+// entryName is the path of an archive entry as it is referred to by the manifest
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// bulkManifestCols are the columns of a csv bulk upload manifest, along with tag.<key> columns for document tags
+var bulkManifestCols = []string{"file", "ownerEmail", "docTitle", "docDesc", "ownerFirstName", "ownerLastName",
+	"validFrom", "validUntil"}
+
+const tagColPrefix = "tag."
+
+// readManifest reads a bulk upload manifest, a json array of entries or a csv file with a header row,
+// and returns its entries by archive path
+func readManifest(r io.Reader) (map[string]rest.BulkManifestEntry, error) {
+	br := bufio.NewReader(r)
+	var entries []rest.BulkManifestEntry
+	var err error
+	if isJson, _ := isJsonArray(br); isJson {
+		entries, err = readJsonManifest(br)
+	} else {
+		entries, err = readCsvManifest(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]rest.BulkManifestEntry, len(entries))
+	for i, e := range entries {
+		if e.File == "" {
+			return nil, fmt.Errorf("manifest entry %d has no file", i+1)
+		}
+		e.File = entryName(e.File)
+		if _, ok := out[e.File]; ok {
+			return nil, fmt.Errorf("manifest has more than one entry for %s", e.File)
+		}
+		out[e.File] = e
+	}
+	return out, nil
+}
+
+// isJsonArray peeks at the first non-space byte of a manifest
+func isJsonArray(br *bufio.Reader) (bool, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b == '[', br.UnreadByte()
+	}
+}
+
+func readJsonManifest(r io.Reader) ([]rest.BulkManifestEntry, error) {
+	var entries []rest.BulkManifestEntry
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("unable to parse json manifest - %w", err)
+	}
+	return entries, nil
+}
+
+func readCsvManifest(r io.Reader) ([]rest.BulkManifestEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse csv manifest header - %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if !slices.Contains(bulkManifestCols, h) && !strings.HasPrefix(h, tagColPrefix) {
+			return nil, fmt.Errorf("unknown csv manifest column %q", h)
+		}
+		cols[h] = i
+	}
+	if _, ok := cols["file"]; !ok {
+		return nil, errors.New("csv manifest has no file column")
+	}
+
+	var entries []rest.BulkManifestEntry
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse csv manifest - %w", err)
+		}
+		col := func(name string) string {
+			if i, ok := cols[name]; ok {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		var tags map[string]string
+		for h := range cols {
+			if k, ok := strings.CutPrefix(h, tagColPrefix); ok && col(h) != "" {
+				if tags == nil {
+					tags = make(map[string]string)
+				}
+				tags[k] = col(h)
+			}
+		}
+		entries = append(entries, rest.BulkManifestEntry{
+			File:           col("file"),
+			OwnerEmail:     col("ownerEmail"),
+			DocTitle:       col("docTitle"),
+			DocDesc:        col("docDesc"),
+			OwnerFirstName: col("ownerFirstName"),
+			OwnerLastName:  col("ownerLastName"),
+			Tags:           tags,
+			ValidFrom:      col("validFrom"),
+			ValidUntil:     col("validUntil"),
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// BatchLimits limits batch verifications of documents
+type BatchLimits struct {
+	// MaxItems is the max number of documents verified in a batch
+	MaxItems int
+	// Concurrency is the max number of documents of a batch verified at once
+	Concurrency int
+}
+
+// VerifyBatch verifies many documents by their digests, as VerifyHash does each one. Results are
+// streamed as ndjson as they are ready when the client accepts it, and sent at once as json otherwise.
+func (d *DocH) VerifyBatch(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("batch verification request received")
+
+	var req rest.BatchVerifyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.BatchVerifyResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	if d.Batch.MaxItems > 0 && len(req.Items) > d.Batch.MaxItems {
+		c.JSON(http.StatusRequestEntityTooLarge, rest.BatchVerifyResp{
+			Error: fmt.Sprintf("req validation failed - batch has more than %d items", d.Batch.MaxItems)})
+		return
+	}
+
+	results := d.verifyBatch(reqCtx(c), req.Items)
+	if c.NegotiateFormat(gin.MIMEJSON, rest.NdjsonContentType) == rest.NdjsonContentType {
+		c.Header("Content-Type", rest.NdjsonContentType)
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		for res := range results {
+			if err := enc.Encode(res); err != nil {
+				logger.Warn("unable to stream batch verification result", zap.Error(err))
+				return
+			}
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	resp := rest.BatchVerifyResp{Items: make([]rest.BatchVerifyResult, len(req.Items))}
+	for res := range results {
+		resp.Items[res.Index] = res
+		switch {
+		case res.Status != http.StatusOK:
+			resp.Failed++
+		case res.Verified:
+			resp.Verified++
+		default:
+			resp.Unverified++
+		}
+	}
+	logger.Info("batch verification completed", zap.Int("verified", resp.Verified),
+		zap.Int("unverified", resp.Unverified), zap.Int("failed", resp.Failed))
+	c.JSON(http.StatusOK, resp)
+}
+
+// anchorRead is a read of what is anchored with a tkn, shared by the documents of a batch verified with it
+type anchorRead struct {
+	done chan struct{}
+	ta   tknAnchor
+}
+
+// verifyBatch verifies the documents of a batch with bounded concurrency, reading what is anchored with
+// a tkn once however many documents are verified with it. Results are sent as they are ready, and the
+// channel is closed once all of them are sent.
+func (d *DocH) verifyBatch(ctx context.Context, items []rest.BatchVerifyItem) <-chan rest.BatchVerifyResult {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, max(d.Batch.Concurrency, 1))
+		// results are buffered so that verifying does not wait on a slow client
+		results = make(chan rest.BatchVerifyResult, len(items))
+		// reads are the anchor reads by tkn, which are the same tkn whatever their case
+		reads = make(map[string]*anchorRead)
+	)
+	readAnchor := func(tkn string) tknAnchor {
+		key := strings.ToLower(tkn)
+		mu.Lock()
+		r, ok := reads[key]
+		if !ok {
+			r = &anchorRead{done: make(chan struct{})}
+			reads[key] = r
+		}
+		mu.Unlock()
+		if !ok {
+			r.ta = d.readAnchor(ctx, tkn)
+			close(r.done)
+		}
+		<-r.done
+		return r.ta
+	}
+
+	go func() {
+		defer close(results)
+		for i, item := range items {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results <- d.verifyBatchItem(ctx, i, item, readAnchor)
+			}()
+		}
+		wg.Wait()
+	}()
+	return results
+}
+
+// verifyBatchItem verifies a document of a batch, with what is anchored with its tkn read by readAnchor
+func (d *DocH) verifyBatchItem(ctx context.Context, idx int, item rest.BatchVerifyItem,
+	readAnchor func(tkn string) tknAnchor) rest.BatchVerifyResult {
+	res := rest.BatchVerifyResult{Index: idx, Id: item.Id}
+	err := binding.Validator.ValidateStruct(&item.VerifyHashReq)
+	if err != nil {
+		err = &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	}
+	var v hashVerification
+	if err == nil {
+		v, err = d.resolveHashReq(ctx, item.VerifyHashReq)
+	}
+	if err != nil {
+		res.Status, res.VerifyResp = errStatus(err), *verifyResp(false, err)
+		return res
+	}
+	status, resp := d.verifyAnchored(ctx, readAnchor(v.tkn), v.algo, v.digest, v.ownerEmailMd5Hash)
+	res.Status, res.VerifyResp = status, *resp
+	return res
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// countingBc counts the reads of each tkn
+type countingBc struct {
+	anchorBc
+	mu    sync.Mutex
+	reads map[string]int
+}
+
+func (b *countingBc) GetDocTkn(ctx context.Context, tknId string) (bc.Anchor, error) {
+	b.mu.Lock()
+	b.reads[tknId]++
+	b.mu.Unlock()
+	return b.anchorBc.GetDocTkn(ctx, tknId)
+}
+
+func TestDocH_VerifyBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		owner  = "john.doe@example.com"
+		docMd5 = "9a0364b9e99bb480dd25e1f0284c8555"
+		other  = "5eb63bbbe01eeed093cb22bb8f5acdc3"
+	)
+	ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader(owner))
+	require.NoError(t, err)
+	db := newSagaStore()
+	db.docs[docMd5] = dbtx.DocMeta{DocId: "doc-1", OwnerEmail: owner, DocMd5Hash: docMd5, BcTknId: "tkn-1"}
+	b := &countingBc{reads: map[string]int{}, anchorBc: anchorBc{anchors: map[string]bc.Anchor{
+		"tkn-1": {DocHash: docMd5, OwnerEmailMd5Hash: ownerMd5}}}}
+	d := &DocH{Db: db, Bc: b, H: hash.Md5{}, Batch: BatchLimits{MaxItems: 10, Concurrency: 2}}
+
+	items := []rest.BatchVerifyItem{
+		{Id: "a", VerifyHashReq: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5, TokenId: "tkn-1"}},
+		{Id: "b", VerifyHashReq: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: other, TokenId: "tkn-1",
+			OwnerEmail: owner}},
+		{VerifyHashReq: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5}},
+		{VerifyHashReq: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5, TokenId: "tkn-2"}},
+		{VerifyHashReq: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: other}},
+		{VerifyHashReq: rest.VerifyHashReq{Algorithm: "sha1", Digest: docMd5}},
+	}
+	batch := func(items []rest.BatchVerifyItem, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := jsonCtx(t, w, "/svc/v1/doc/verify/batch", rest.BatchVerifyReq{Items: items})
+		if accept != "" {
+			c.Request.Header.Set("Accept", accept)
+		}
+		d.VerifyBatch(c)
+		return w
+	}
+
+	w := batch(items, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp rest.BatchVerifyResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Verified)
+	assert.Equal(t, 2, resp.Unverified)
+	assert.Equal(t, 2, resp.Failed)
+	require.Len(t, resp.Items, len(items))
+	for i, res := range resp.Items {
+		assert.Equal(t, i, res.Index)
+		assert.Equal(t, items[i].Id, res.Id)
+	}
+	assert.True(t, resp.Items[0].Verified)
+	assert.Equal(t, []string{rest.ContentMismatch}, resp.Items[1].Reasons)
+	assert.True(t, resp.Items[2].Verified)
+	assert.Equal(t, []string{rest.TokenNotFound}, resp.Items[3].Reasons)
+	assert.Equal(t, http.StatusNotFound, resp.Items[4].Status)
+	assert.Equal(t, http.StatusBadRequest, resp.Items[5].Status)
+	// each tkn is read once, however many docs are verified with it
+	assert.Equal(t, map[string]int{"tkn-1": 1, "tkn-2": 1}, b.reads)
+
+	w = batch(items, rest.NdjsonContentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, rest.NdjsonContentType, w.Header().Get("Content-Type"))
+	seen := map[int]rest.BatchVerifyResult{}
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var res rest.BatchVerifyResult
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &res))
+		seen[res.Index] = res
+	}
+	require.Len(t, seen, len(items))
+	assert.True(t, seen[0].Verified)
+	assert.Equal(t, "b", seen[1].Id)
+
+	w = batch(make([]rest.BatchVerifyItem, 11), "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	w = batch(nil, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
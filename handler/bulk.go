@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// BulkLimits limits bulk uploads of documents in an archive
+type BulkLimits struct {
+	// MaxSize is the largest archive, along with its manifest, accepted in bytes
+	MaxSize int64
+	// MaxEntries is the max number of documents in an archive
+	MaxEntries int
+	// Concurrency is the max number of documents of an archive minted and saved at once
+	Concurrency int
+}
+
+// bulkPrefix is where the archives of bulk uploads are staged in blob store
+const bulkPrefix = "bulk/"
+
+// errTooManyEntries stops reading an archive with more documents than allowed
+var errTooManyEntries = &stepErr{http.StatusRequestEntityTooLarge, errors.New("archive has too many documents")}
+
+// BulkUpload uploads the documents of a zip, tar or gzipped tar archive, as described by a csv or json
+// manifest. Each document goes through the upload pipeline, and the outcome of each one is reported.
+func (d *DocH) BulkUpload(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("bulk upload request received")
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, d.Bulk.MaxSize)
+	manifest, archive, err := d.bulkForm(c)
+	if err != nil {
+		c.JSON(errStatus(err), rest.BulkUploadResp{Error: err.Error()})
+		return
+	}
+	defer d.deleteBlob(context.WithoutCancel(reqCtx(c)), archive.objName)
+
+	// a bulk upload retried with an Idempotency-Key gets the response of the first one
+	fingerprint, err := bulkFingerprint(manifest, archive.digest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.BulkUploadResp{Error: err.Error()})
+		return
+	}
+	errResp := func(err error) any { return rest.BulkUploadResp{Error: err.Error()} }
+	d.idempotent(c, manifestOwners(manifest), fingerprint, errResp, func() {
+		ctx := reqCtx(c)
+		entries, err := d.bulkIngest(ctx, &blobReaderAt{ctx: ctx, b: d.Blob, objName: archive.objName,
+			size: archive.size}, archive.size, manifest)
+		resp := rest.BulkUploadResp{Entries: entries}
+		for _, e := range entries {
+			switch e.Result {
+			case rest.BulkCreated:
+				resp.Created++
+			case rest.BulkDuplicate:
+				resp.Duplicates++
+			default:
+				resp.Failed++
+			}
+		}
+		logger.Info("bulk upload completed", zap.Int("created", resp.Created), zap.Int("duplicates", resp.Duplicates),
+			zap.Int("failed", resp.Failed), zap.Error(err))
+		if err != nil {
+			resp.Error = err.Error()
+			c.JSON(errStatus(err), resp)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// stagedArchive is the archive of a bulk upload, staged in blob store while its documents are uploaded
+type stagedArchive struct {
+	objName string
+	size    int64
+	// digest is the sha256 hex digest of the archive
+	digest string
+}
+
+// bulkForm reads the manifest of a bulk upload, sent as a file or as a form field, and streams the archive
+// to blob store part by part instead of buffering the form on local disk. The staged archive is removed when
+// the form can not be read.
+func (d *DocH) bulkForm(c *gin.Context) (map[string]rest.BulkManifestEntry, stagedArchive, error) {
+	invalid := func(status int, err error) error {
+		return &stepErr{status, fmt.Errorf("req validation failed - %w", err)}
+	}
+	var (
+		manifest map[string]rest.BulkManifestEntry
+		archive  stagedArchive
+	)
+	err := func() error {
+		mr, err := c.Request.MultipartReader()
+		if err != nil {
+			return invalid(http.StatusBadRequest, err)
+		}
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return invalid(formErrStatus(err), err)
+			}
+			switch part.FormName() {
+			case "manifest":
+				cr := &countingReader{r: part}
+				if manifest, err = readManifest(cr); err != nil {
+					if cr.err != nil {
+						err = cr.err
+					}
+					return invalid(formErrStatus(err), err)
+				}
+			case "archive":
+				if archive.objName != "" {
+					return invalid(http.StatusBadRequest, errors.New("archive is sent more than once"))
+				}
+				archive.objName = bulkPrefix + uuid.New().String()
+				h := sha256.New()
+				cr := &countingReader{r: io.TeeReader(part, h)}
+				if err = d.Blob.PutAt(c, archive.objName, cr, -1); err != nil {
+					if cr.err != nil {
+						return invalid(formErrStatus(cr.err), fmt.Errorf("unable to read archive - %w", cr.err))
+					}
+					return &stepErr{http.StatusInternalServerError,
+						fmt.Errorf("unable to stage archive in blob store - %w", err)}
+				}
+				archive.size, archive.digest = cr.n, hex.EncodeToString(h.Sum(nil))
+			}
+			_ = part.Close()
+		}
+	}()
+	switch {
+	case err != nil:
+	case manifest == nil:
+		err = invalid(http.StatusBadRequest, errors.New("manifest is required"))
+	case archive.objName == "":
+		err = invalid(http.StatusBadRequest, errors.New("unable to read archive - archive is required"))
+	}
+	if err != nil {
+		if archive.objName != "" {
+			d.deleteBlob(context.WithoutCancel(reqCtx(c)), archive.objName)
+		}
+		return nil, stagedArchive{}, err
+	}
+	return manifest, archive, nil
+}
+
+// bulkFingerprint tells bulk uploads apart by their manifest and the digest of their archive
+func bulkFingerprint(manifest map[string]rest.BulkManifestEntry, digest string) (string, error) {
+	m, err := json.Marshal(manifest)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal manifest - %w", err)
+	}
+	return string(m) + "\n" + digest, nil
+}
+
+// manifestOwners are the owners of the documents of a bulk upload, which is made for all of them
+func manifestOwners(manifest map[string]rest.BulkManifestEntry) string {
+	owners := make([]string, 0, len(manifest))
+	for _, e := range manifest {
+		owners = append(owners, strings.ToLower(e.OwnerEmail))
+	}
+	slices.Sort(owners)
+	return strings.Join(slices.Compact(owners), ",")
+}
+
+// formErrStatus is the status of a request whose multipart form could not be read
+func formErrStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// bulkIngest streams the documents of an archive to blob store one by one, and runs them through the
+// rest of the upload pipeline with bounded concurrency. Documents with the same content as an earlier one
+// in the archive are duplicates, manifest entries which are not in the archive are failed.
+func (d *DocH) bulkIngest(ctx context.Context, archive io.ReaderAt, size int64,
+	manifest map[string]rest.BulkManifestEntry) ([]rest.BulkEntryResult, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []rest.BulkEntryResult
+		sem     = make(chan struct{}, max(d.Bulk.Concurrency, 1))
+		// seen are the archive entries by content hash
+		seen = make(map[string]string)
+	)
+	setResult := func(idx int, res rest.BulkEntryResult) {
+		mu.Lock()
+		results[idx] = res
+		mu.Unlock()
+	}
+	failed := func(file string, err error) rest.BulkEntryResult {
+		return rest.BulkEntryResult{File: file, Result: rest.BulkFailed, Status: errStatus(err), Error: err.Error()}
+	}
+
+	err := walkArchive(archive, size, func(e archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mu.Lock()
+		idx := len(results)
+		results = append(results, rest.BulkEntryResult{File: e.name})
+		mu.Unlock()
+		if d.Bulk.MaxEntries > 0 && idx >= d.Bulk.MaxEntries {
+			delete(manifest, e.name)
+			setResult(idx, failed(e.name, errTooManyEntries))
+			return errTooManyEntries
+		}
+
+		entry, ok := manifest[e.name]
+		if !ok {
+			setResult(idx, failed(e.name, &stepErr{http.StatusBadRequest, errors.New("not in manifest")}))
+			return nil
+		}
+		delete(manifest, e.name)
+		req, src, err := d.bulkEntryReq(ctx, entry, e)
+		if err != nil {
+			setResult(idx, failed(e.name, err))
+			return nil
+		}
+		if other, dup := seen[req.DocMd5Hash]; dup {
+			d.deleteBlob(ctx, src.stored)
+			setResult(idx, rest.BulkEntryResult{File: e.name, Result: rest.BulkDuplicate, Status: http.StatusOK,
+				Error: "same content as " + other})
+			return nil
+		}
+		seen[req.DocMd5Hash] = e.name
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			logger := log.GetLogger(ctx).With(zap.String("bulkFile", e.name))
+			res, err := d.ingest(ctx, req, src, false)
+			var dup *duplicateErr
+			if errors.As(err, &dup) {
+				setResult(idx, rest.BulkEntryResult{File: e.name, Result: rest.BulkDuplicate, Status: errStatus(err),
+					Error: err.Error(), Duplicate: dup.dup})
+				return
+			}
+			if err != nil {
+				logger.Warn("bulk upload entry failed", zap.Error(err))
+				setResult(idx, failed(e.name, err))
+				return
+			}
+			result := rest.BulkCreated
+			if res.exists {
+				result = rest.BulkDuplicate
+			}
+			setResult(idx, rest.BulkEntryResult{File: e.name, Result: result, Doc: &res.doc, Status: http.StatusOK})
+		}()
+		return nil
+	})
+	wg.Wait()
+
+	// documents which were processed are reported along with an archive which could not be read to its end
+	switch {
+	case errors.Is(err, errTooManyEntries):
+		err = nil
+		for file := range manifest {
+			results = append(results, failed(file, errTooManyEntries))
+		}
+	case err != nil:
+		err = &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	default:
+		for file := range manifest {
+			results = append(results, failed(file, &stepErr{http.StatusBadRequest, errors.New("not found in archive")}))
+		}
+	}
+	return results, err
+}
+
+// bulkEntryReq validates the manifest entry of an archive document, and streams the document to blob store
+func (d *DocH) bulkEntryReq(ctx context.Context, entry rest.BulkManifestEntry,
+	e archiveEntry) (*rest.UploadReq, docSource, error) {
+	req := entry.UploadReq()
+	err := binding.Validator.ValidateStruct(&req)
+	if err == nil {
+		err = parseDocMeta(&req)
+	}
+	if err != nil {
+		return nil, docSource{}, &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	}
+	req.OwnerEmailMd5Hash, err = d.H.Hash(ctx, strings.NewReader(req.OwnerEmail))
+	if err != nil {
+		return nil, docSource{}, fmt.Errorf("unable to generate hash. emailHashErr - %w", err)
+	}
+	var hashed rest.UploadReq
+	src, err := d.streamDoc(ctx, e.r, path.Base(e.name), &hashed)
+	if err != nil {
+		return nil, docSource{}, err
+	}
+	req.DocMd5Hash, req.DocHashAlgo = hashed.DocMd5Hash, hashed.DocHashAlgo
+	return &req, src, nil
+}
+
+// blobReaderAt reads an object of blob store at offsets. The range last read is kept open as long as reads
+// follow on from each other, as they do for tar archives and mostly for zip ones.
+type blobReaderAt struct {
+	ctx     context.Context
+	b       blob.OpsIf
+	objName string
+	size    int64
+
+	mu  sync.Mutex
+	r   io.Reader
+	off int64
+}
+
+func (b *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	if b.r == nil || off != b.off {
+		b.close()
+		r, err := b.b.Get(b.ctx, b.objName, &blob.ByteRange{Start: off, End: b.size - 1})
+		if err != nil {
+			return 0, fmt.Errorf("unable to read archive from blob store - %w", err)
+		}
+		b.r, b.off = r, off
+	}
+	n, err := io.ReadFull(b.r, p)
+	b.off += int64(n)
+	if err != nil {
+		b.close()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// close closes the range being read
+func (b *blobReaderAt) close() {
+	if c, ok := b.r.(io.Closer); ok {
+		_ = c.Close()
+	}
+	b.r = nil
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+type archiveFile struct {
+	name, body string
+}
+
+func zipArchive(t *testing.T, files ...archiveFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, f.body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, gzipped bool, files ...archiveFile) []byte {
+	var buf bytes.Buffer
+	w := io.Writer(&buf)
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644,
+			Size: int64(len(f.body))}))
+		_, err := io.WriteString(tw, f.body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+func Test_walkArchive(t *testing.T) {
+	files := []archiveFile{{"docs/a.txt", "first doc"}, {"./docs/b.txt", "second doc"}}
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"zip", zipArchive(t, files...)},
+		{"tar", tarArchive(t, false, files...)},
+		{"tar.gz", tarArchive(t, true, files...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []archiveFile
+			err := walkArchive(bytes.NewReader(tt.archive), int64(len(tt.archive)), func(e archiveEntry) error {
+				b, err := io.ReadAll(e.r)
+				got = append(got, archiveFile{e.name, string(b)})
+				return err
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []archiveFile{{"docs/a.txt", "first doc"}, {"docs/b.txt", "second doc"}}, got)
+		})
+	}
+
+	t.Run("not an archive", func(t *testing.T) {
+		in := []byte(strings.Repeat("not an archive ", 64))
+		err := walkArchive(bytes.NewReader(in), int64(len(in)), func(archiveEntry) error { return nil })
+		assert.Error(t, err)
+	})
+}
+
+func Test_readManifest(t *testing.T) {
+	want := map[string]rest.BulkManifestEntry{
+		"docs/a.txt": {File: "docs/a.txt", OwnerEmail: "john.doe@example.com", DocTitle: "First doc",
+			OwnerFirstName: "John", OwnerLastName: "Doe"},
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		want     map[string]rest.BulkManifestEntry
+		wantErr  bool
+	}{
+		{
+			name: "csv",
+			manifest: "\ufefffile,ownerEmail,docTitle,ownerFirstName,ownerLastName\n" +
+				"./docs/a.txt, john.doe@example.com,First doc,John,Doe\n",
+			want: want,
+		},
+		{
+			name: "json",
+			manifest: ` [{"file":"docs/a.txt","ownerEmail":"john.doe@example.com","docTitle":"First doc",
+				"ownerFirstName":"John","ownerLastName":"Doe"}]`,
+			want: want,
+		},
+		{
+			name:     "csv tag columns",
+			manifest: "file,tag.issuer,tag.courseId\na.txt,acme,\n",
+			want:     map[string]rest.BulkManifestEntry{"a.txt": {File: "a.txt", Tags: map[string]string{"issuer": "acme"}}},
+		},
+		{
+			name:     "csv validity columns",
+			manifest: "file,validUntil\na.txt,2027-01-01T00:00:00Z\n",
+			want:     map[string]rest.BulkManifestEntry{"a.txt": {File: "a.txt", ValidUntil: "2027-01-01T00:00:00Z"}},
+		},
+		{name: "unknown csv column", manifest: "file,owner\na.txt,john\n", wantErr: true},
+		{name: "no file column", manifest: "ownerEmail\njohn.doe@example.com\n", wantErr: true},
+		{name: "unknown json field", manifest: `[{"file":"a.txt","owner":"john"}]`, wantErr: true},
+		{name: "entry without file", manifest: `[{"ownerEmail":"john.doe@example.com"}]`, wantErr: true},
+		{name: "duplicate file", manifest: "file\na.txt\n./a.txt\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readManifest(strings.NewReader(tt.manifest))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDocH_bulkIngest(t *testing.T) {
+	entry := func(file string) rest.BulkManifestEntry {
+		return rest.BulkManifestEntry{File: file, OwnerEmail: "john.doe@example.com", DocTitle: "Bulk doc",
+			OwnerFirstName: "John", OwnerLastName: "Doe"}
+	}
+	manifest := map[string]rest.BulkManifestEntry{
+		"a.txt":       entry("a.txt"),
+		"b.txt":       entry("b.txt"),
+		"missing.txt": entry("missing.txt"),
+		"invalid.txt": {File: "invalid.txt", OwnerEmail: "not an email"},
+	}
+	archive := zipArchive(t,
+		archiveFile{"a.txt", "first doc"},
+		archiveFile{"b.txt", "first doc"},
+		archiveFile{"c.txt", "third doc"},
+		archiveFile{"invalid.txt", "fourth doc"},
+	)
+
+	store, mb := newSagaStore(), memBlob{}
+	// the fakes are not safe for concurrent use, documents are still ingested alongside reading the archive
+	d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, H: hash.Md5{}, Bulk: BulkLimits{Concurrency: 1}}
+	got, err := d.bulkIngest(context.Background(), bytes.NewReader(archive), int64(len(archive)), manifest)
+	require.NoError(t, err)
+	require.Len(t, got, 5)
+
+	assert.Equal(t, rest.BulkCreated, got[0].Result)
+	require.NotNil(t, got[0].Doc)
+	assert.Equal(t, dbtx.SagaSaved, store.only(t).State)
+	assert.Equal(t, *got[0].Doc, store.docs[got[0].Doc.DocMd5Hash])
+
+	assert.Equal(t, rest.BulkDuplicate, got[1].Result)
+	assert.Equal(t, "same content as a.txt", got[1].Error)
+	assert.Len(t, mb, 1)
+
+	assert.Equal(t, rest.BulkFailed, got[2].Result)
+	assert.Equal(t, "not in manifest", got[2].Error)
+	assert.Equal(t, rest.BulkFailed, got[3].Result)
+	assert.Equal(t, http.StatusBadRequest, got[3].Status)
+	assert.Equal(t, rest.BulkEntryResult{File: "missing.txt", Result: rest.BulkFailed, Status: http.StatusBadRequest,
+		Error: "not found in archive"}, got[4])
+
+	t.Run("too many documents", func(t *testing.T) {
+		d := &DocH{Db: newSagaStore(), Blob: memBlob{}, Bc: fakeBc{}, H: hash.Md5{},
+			Bulk: BulkLimits{MaxEntries: 1, Concurrency: 1}}
+		manifest := map[string]rest.BulkManifestEntry{"a.txt": entry("a.txt"), "b.txt": entry("b.txt")}
+		got, err := d.bulkIngest(context.Background(), bytes.NewReader(archive), int64(len(archive)), manifest)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, rest.BulkCreated, got[0].Result)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, got[1].Status)
+	})
+}
+
+func TestDocH_BulkUpload(t *testing.T) {
+	manifest := `[{"file":"a.txt","ownerEmail":"john.doe@example.com","docTitle":"Bulk doc",` +
+		`"ownerFirstName":"John","ownerLastName":"Doe"}]`
+	bulkCtx := func(archive []byte) (*gin.Context, *httptest.ResponseRecorder) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		// the manifest sent after the archive is read once the archive is staged
+		fw, err := mw.CreateFormFile("archive", "docs.tar.gz")
+		require.NoError(t, err)
+		_, err = fw.Write(archive)
+		require.NoError(t, err)
+		require.NoError(t, mw.WriteField("manifest", manifest))
+		require.NoError(t, mw.Close())
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/bulk", &body)
+		c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+		return c, w
+	}
+	staged := func(mb memBlob) []string {
+		var names []string
+		for name := range mb {
+			if strings.HasPrefix(name, bulkPrefix) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	t.Run("archive streamed to blob store", func(t *testing.T) {
+		mb, puts := memBlob{}, []string{}
+		d := &DocH{Db: newSagaStore(), Blob: putsBlob{mb, &puts}, Bc: fakeBc{}, H: hash.Md5{},
+			Bulk: BulkLimits{MaxSize: 1 << 20, Concurrency: 1}}
+		c, w := bulkCtx(tarArchive(t, true, archiveFile{"a.txt", "first doc"}))
+		d.BulkUpload(c)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp rest.BulkUploadResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Created)
+		require.NotEmpty(t, puts)
+		assert.True(t, strings.HasPrefix(puts[0], bulkPrefix))
+		assert.Empty(t, staged(mb), "staged archive removed")
+	})
+
+	t.Run("archive larger than allowed", func(t *testing.T) {
+		mb := memBlob{}
+		d := &DocH{Db: newSagaStore(), Blob: mb, Bc: fakeBc{}, H: hash.Md5{},
+			Bulk: BulkLimits{MaxSize: 64, Concurrency: 1}}
+		c, w := bulkCtx(bytes.Repeat([]byte("x"), 1024))
+		d.BulkUpload(c)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+		assert.Empty(t, staged(mb), "staged archive removed")
+	})
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// DuplicatePolicy decides what uploaders of a document owned by someone else are told of it. They get a conflict
+// without the owner details whichever the policy.
+type DuplicatePolicy string
+
+const (
+	// DuplicateConflict tells them the id of the document
+	DuplicateConflict DuplicatePolicy = "conflict"
+	// DuplicateConceal tells them nothing but that the document was uploaded before
+	DuplicateConceal DuplicatePolicy = "conceal"
+	// DuplicateClaim tells them the id of the document, and makes a claim of co-ownership on it for them
+	DuplicateClaim DuplicatePolicy = "claim"
+)
+
+// ClaimNotice notifies owners of documents of claims of co-ownership on them, with a doc.claimed event carrying
+// the one-time token a claim is decided with. The event is posted to a webhook, it is logged without its token.
+type ClaimNotice struct {
+	// WebhookUrl receives the events as json, claims are not made without it
+	WebhookUrl string
+	Client     *http.Client
+}
+
+// duplicateErr is returned for an upload of a document which another owner uploaded before,
+// it carries only what can be shared with the uploader, nothing when the document is concealed
+type duplicateErr struct {
+	dup *rest.DuplicateDoc
+}
+
+func (e *duplicateErr) Error() string {
+	return "doc was already uploaded by another owner"
+}
+
+// duplicate decides the outcome of uploading a document which exists already. Its owner, and co-owners
+// with an accepted claim, get the document back. Anyone else gets a conflict, as the duplicate policy says.
+func (d *DocH) duplicate(ctx context.Context, req *rest.UploadReq, doc dbtx.DocMeta) (*ingestResult, error) {
+	if strings.EqualFold(doc.OwnerEmail, req.OwnerEmail) {
+		return &ingestResult{doc: doc, exists: true}, nil
+	}
+
+	var dup *rest.DuplicateDoc
+	switch d.Duplicates {
+	case DuplicateConceal:
+	case DuplicateClaim:
+		// a claim whose token can not be delivered to the owner could never be decided
+		if d.Claims == nil || d.Claims.WebhookUrl == "" {
+			return nil, &stepErr{http.StatusServiceUnavailable, errors.New("claims of documents can not be delivered")}
+		}
+		dup = &rest.DuplicateDoc{DocId: doc.DocId}
+		token, tokenHash, err := decisionToken()
+		if err != nil {
+			return nil, &stepErr{http.StatusInternalServerError, err}
+		}
+		claim, err := d.Db.ClaimDoc(ctx, dbtx.DocClaim{
+			ClaimId:           uuid.New().String(),
+			DocId:             doc.DocId,
+			ClaimantEmail:     req.OwnerEmail,
+			FirstName:         req.OwnerFirstName,
+			LastName:          req.OwnerLastName,
+			DecisionTokenHash: tokenHash,
+		})
+		if err != nil {
+			return nil, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+		}
+		if claim.Status == dbtx.ClaimAccepted {
+			doc.OwnerEmail, doc.OwnerFirstName, doc.OwnerLastName = claim.ClaimantEmail, claim.FirstName, claim.LastName
+			return &ingestResult{doc: doc, exists: true}, nil
+		}
+		// the owner is told of a claim once, when its token is issued
+		if claim.DecisionTokenHash == tokenHash {
+			d.notifyClaim(ctx, doc, claim, token)
+		}
+		dup.ClaimId, dup.ClaimStatus = claim.ClaimId, claim.Status
+	default:
+		dup = &rest.DuplicateDoc{DocId: doc.DocId}
+	}
+	log.GetLogger(ctx).Info("doc was uploaded by another owner", zap.String("docId", doc.DocId),
+		zap.String("policy", string(d.Duplicates)))
+	return nil, &stepErr{http.StatusConflict, &duplicateErr{dup}}
+}
+
+// decisionToken issues a one-time token for deciding a claim, along with the sha256 hex digest it is kept as
+func decisionToken() (token, tokenHash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("unable to generate claim decision token - %w", err)
+	}
+	token = hex.EncodeToString(b)
+	return token, tokenDigest(token), nil
+}
+
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// notifyClaim tells the owner of a document of a claim on it, with the token to decide it with. A claim whose
+// owner could not be told stays pending, claims are not rolled back for it.
+func (d *DocH) notifyClaim(ctx context.Context, doc dbtx.DocMeta, claim dbtx.DocClaim, token string) {
+	logger := log.GetLogger(ctx)
+	ev := rest.DocClaimedEvent{
+		Type:          rest.DocClaimedEventType,
+		ClaimId:       claim.ClaimId,
+		DocId:         doc.DocId,
+		DocTitle:      doc.DocTitle,
+		OwnerEmail:    doc.OwnerEmail,
+		DecisionToken: token,
+		ClaimedAt:     claim.CreatedAt,
+	}
+	// the token is never logged, it lets anyone reading the logs decide the claim
+	logger.Info("doc claimed", zap.String("event", ev.Type), zap.String("claimId", ev.ClaimId),
+		zap.String("docId", ev.DocId), zap.String("ownerEmail", ev.OwnerEmail))
+	if err := postEvent(ctx, d.Claims.Client, d.Claims.WebhookUrl, ev); err != nil {
+		logger.Error("unable to notify owner of doc claim", zap.String("claimId", ev.ClaimId), zap.Error(err))
+	}
+}
+
+// Claim reports the status of a claim of co-ownership on a document
+func (d *DocH) Claim(c *gin.Context) {
+	claim, ok := d.docClaim(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, claimResp(claim))
+}
+
+// DecideClaim lets the owner of a document accept or reject a pending claim of co-ownership on it, with the
+// one-time token they were sent when the claim was made. Co-owners with an accepted claim get the document back
+// when they upload it.
+func (d *DocH) DecideClaim(c *gin.Context) {
+	logger := log.GetLogger(c)
+	claim, ok := d.docClaim(c)
+	if !ok {
+		return
+	}
+	var req rest.ClaimDecisionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.ClaimResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	if claim.DecisionTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(tokenDigest(req.DecisionToken)), []byte(claim.DecisionTokenHash)) != 1 {
+		c.JSON(http.StatusForbidden, rest.ClaimResp{Error: "decision token does not match the claim"})
+		return
+	}
+	doc, err := d.Db.GetDocMeta(c, claim.DocId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.ClaimResp{Error: "unable to find doc in db - " + err.Error()})
+		return
+	}
+
+	// a decision retried with an Idempotency-Key gets the response of the first one
+	errResp := func(err error) any { return rest.ClaimResp{Error: err.Error()} }
+	d.idempotent(c, doc.OwnerEmail, claim.ClaimId+"\n"+req.Status, errResp, func() {
+		err := d.Db.DecideDocClaim(c, claim.ClaimId, req.Status)
+		if errors.Is(err, dbtx.ErrClaimDecided) {
+			c.JSON(http.StatusConflict, rest.ClaimResp{Error: "claim was already decided"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, rest.ClaimResp{Error: "unable to persist to db - " + err.Error()})
+			return
+		}
+		logger.Info("doc claim decided", zap.String("claimId", claim.ClaimId), zap.String("status", req.Status))
+		claim.Status = req.Status
+		c.JSON(http.StatusOK, claimResp(claim))
+	})
+}
+
+// docClaim loads the claim addressed by the request, responding 404 for unknown claims
+// and when duplicates are not claimed
+func (d *DocH) docClaim(c *gin.Context) (dbtx.DocClaim, bool) {
+	var req rest.ClaimReq
+	if err := c.BindUri(&req); err != nil {
+		c.JSON(http.StatusNotFound, rest.ClaimResp{Error: "claim not found - " + err.Error()})
+		return dbtx.DocClaim{}, false
+	}
+	if d.Duplicates != DuplicateClaim {
+		c.JSON(http.StatusNotFound, rest.ClaimResp{Error: "claim not found"})
+		return dbtx.DocClaim{}, false
+	}
+	claim, err := d.Db.GetDocClaim(c, req.ClaimId)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, rest.ClaimResp{Error: "claim not found"})
+		return claim, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.ClaimResp{Error: "unable to find claim in db - " + err.Error()})
+		return claim, false
+	}
+	return claim, true
+}
+
+// claimResp reports a claim without the claimant details
+func claimResp(claim dbtx.DocClaim) rest.ClaimResp {
+	return rest.ClaimResp{
+		ClaimId:   claim.ClaimId,
+		DocId:     claim.DocId,
+		Status:    claim.Status,
+		CreatedAt: &claim.CreatedAt,
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// claimStore keeps docs and claims on them in memory
+type claimStore struct {
+	dbtx.MockStore
+	docs   map[string]dbtx.DocMeta
+	claims map[string]*dbtx.DocClaim
+}
+
+func (s *claimStore) GetDocMeta(_ context.Context, docId string) (dbtx.DocMeta, error) {
+	doc, ok := s.docs[docId]
+	if !ok {
+		return doc, sql.ErrNoRows
+	}
+	return doc, nil
+}
+
+func (s *claimStore) ClaimDoc(_ context.Context, in dbtx.DocClaim) (dbtx.DocClaim, error) {
+	for _, c := range s.claims {
+		if c.DocId == in.DocId && c.ClaimantEmail == in.ClaimantEmail {
+			return *c, nil
+		}
+	}
+	in.Status = dbtx.ClaimPending
+	s.claims[in.ClaimId] = &in
+	return in, nil
+}
+
+func (s *claimStore) GetDocClaim(_ context.Context, claimId string) (dbtx.DocClaim, error) {
+	c, ok := s.claims[claimId]
+	if !ok {
+		return dbtx.DocClaim{}, sql.ErrNoRows
+	}
+	return *c, nil
+}
+
+func (s *claimStore) DecideDocClaim(_ context.Context, claimId, status string) error {
+	if s.claims[claimId].Status != dbtx.ClaimPending {
+		return dbtx.ErrClaimDecided
+	}
+	s.claims[claimId].Status = status
+	return nil
+}
+
+func TestDocH_duplicate(t *testing.T) {
+	doc := dbtx.DocMeta{DocId: "doc-1", OwnerEmail: "john.doe@example.com", OwnerFirstName: "John",
+		OwnerLastName: "Doe", BcTknId: "tkn-1"}
+	other := &rest.UploadReq{OwnerEmail: "jane.roe@example.com", OwnerFirstName: "Jane", OwnerLastName: "Roe"}
+
+	t.Run("owner gets the doc", func(t *testing.T) {
+		d := &DocH{Db: &claimStore{}}
+		res, err := d.duplicate(context.Background(), &rest.UploadReq{OwnerEmail: "John.Doe@example.com"}, doc)
+		require.NoError(t, err)
+		assert.True(t, res.exists)
+		assert.Equal(t, doc, res.doc)
+	})
+
+	t.Run("another owner gets a conflict", func(t *testing.T) {
+		d := &DocH{Db: &claimStore{}}
+		_, err := d.duplicate(context.Background(), other, doc)
+		assert.Equal(t, http.StatusConflict, errStatus(err))
+		assert.Equal(t, &rest.DuplicateDoc{DocId: "doc-1"}, uploadResp(nil, err).Duplicate)
+	})
+
+	t.Run("another owner is told nothing of a concealed doc", func(t *testing.T) {
+		d := &DocH{Db: &claimStore{}, Duplicates: DuplicateConceal}
+		_, err := d.duplicate(context.Background(), other, doc)
+		assert.Equal(t, http.StatusConflict, errStatus(err))
+		assert.Nil(t, uploadResp(nil, err).Duplicate)
+	})
+
+	t.Run("another owner claims the doc", func(t *testing.T) {
+		var got []rest.DocClaimedEvent
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ev rest.DocClaimedEvent
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+			got = append(got, ev)
+		}))
+		defer webhook.Close()
+
+		store := &claimStore{claims: make(map[string]*dbtx.DocClaim)}
+		d := &DocH{Db: store, Duplicates: DuplicateClaim, Claims: &ClaimNotice{WebhookUrl: webhook.URL}}
+		_, err := d.duplicate(context.Background(), other, doc)
+		assert.Equal(t, http.StatusConflict, errStatus(err))
+		dup := uploadResp(nil, err).Duplicate
+		require.NotNil(t, dup)
+		assert.Equal(t, dbtx.ClaimPending, dup.ClaimStatus)
+		require.Contains(t, store.claims, dup.ClaimId)
+		tokenHash := store.claims[dup.ClaimId].DecisionTokenHash
+		assert.Len(t, tokenHash, 64)
+
+		// the owner is sent the token the claim is decided with
+		require.Len(t, got, 1)
+		assert.Equal(t, dup.ClaimId, got[0].ClaimId)
+		assert.Equal(t, "john.doe@example.com", got[0].OwnerEmail)
+		assert.Equal(t, tokenHash, tokenDigest(got[0].DecisionToken))
+
+		// uploading it again returns the same claim with the same token, until it is accepted
+		_, err = d.duplicate(context.Background(), other, doc)
+		assert.Equal(t, dup, uploadResp(nil, err).Duplicate)
+		assert.Equal(t, tokenHash, store.claims[dup.ClaimId].DecisionTokenHash)
+		assert.Len(t, got, 1)
+		store.claims[dup.ClaimId].Status = dbtx.ClaimAccepted
+		res, err := d.duplicate(context.Background(), other, doc)
+		require.NoError(t, err)
+		assert.Equal(t, "jane.roe@example.com", res.doc.OwnerEmail)
+		assert.Equal(t, "Roe", res.doc.OwnerLastName)
+		assert.Equal(t, "tkn-1", res.doc.BcTknId)
+	})
+
+	t.Run("no claim made when its token can not be delivered", func(t *testing.T) {
+		store := &claimStore{claims: make(map[string]*dbtx.DocClaim)}
+		d := &DocH{Db: store, Duplicates: DuplicateClaim}
+		_, err := d.duplicate(context.Background(), other, doc)
+		assert.Equal(t, http.StatusServiceUnavailable, errStatus(err))
+		assert.Empty(t, store.claims)
+	})
+}
+
+func TestDocH_DecideClaim(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	claimId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	token, tokenHash, err := decisionToken()
+	require.NoError(t, err)
+	store := &claimStore{
+		docs: map[string]dbtx.DocMeta{"doc-1": {DocId: "doc-1", OwnerEmail: "john.doe@example.com"}},
+		claims: map[string]*dbtx.DocClaim{claimId: {ClaimId: claimId, DocId: "doc-1",
+			ClaimantEmail: "jane.roe@example.com", Status: dbtx.ClaimPending, DecisionTokenHash: tokenHash}},
+	}
+	d := &DocH{Db: store, Duplicates: DuplicateClaim}
+
+	decide := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/svc/v1/doc/claims/"+claimId, strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "claimId", Value: claimId}}
+		d.DecideClaim(c)
+		return w
+	}
+
+	// knowing the owner email is not enough to decide a claim
+	w := decide(`{"ownerEmail":"john.doe@example.com","status":"ACCEPTED"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = decide(`{"decisionToken":"` + tokenHash + `","status":"ACCEPTED"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, dbtx.ClaimPending, store.claims[claimId].Status)
+
+	w = decide(`{"decisionToken":"` + token + `","status":"MAYBE"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = decide(`{"decisionToken":"` + token + `","status":"ACCEPTED"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"ACCEPTED"`)
+	assert.NotContains(t, w.Body.String(), "jane.roe@example.com")
+	assert.Equal(t, dbtx.ClaimAccepted, store.claims[claimId].Status)
+
+	w = decide(`{"decisionToken":"` + token + `","status":"REJECTED"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	t.Run("claims not enabled", func(t *testing.T) {
+		d.Duplicates = DuplicateConflict
+		defer func() { d.Duplicates = DuplicateClaim }()
+		w := decide(`{"decisionToken":"` + token + `","status":"ACCEPTED"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
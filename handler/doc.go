@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// GetDoc returns the metadata of a document by its docId, along with the state of its blockchain anchor
+func (d *DocH) GetDoc(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("get document request received")
+
+	var uri rest.DownloadReq
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, rest.DocResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	var req rest.DocReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.DocResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	meta, err := d.Db.GetDocMeta(c, uri.DocId)
+	d.docResp(c, req, meta, err)
+}
+
+// GetDocByTkn returns the metadata of a document by the blockchain token it was minted with, along with
+// the state of its anchor
+func (d *DocH) GetDocByTkn(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("get document by tkn request received")
+
+	var uri rest.DocByTknReq
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, rest.DocResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	var req rest.DocReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.DocResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	// tkns are the hex tx hashes docs were minted in, which are kept in lower case
+	meta, err := d.Db.GetDocMetaByTkn(c, strings.ToLower(uri.TokenId))
+	d.docResp(c, req, meta, err)
+}
+
+// docResp responds with a document looked up for a caller, the owner of the document is redacted
+// for callers other than the owner. Callers are not authenticated, a caller is taken as the owner when the
+// ownerEmail it sends is the owner's. This hides the owner from callers who do not know their email, it does
+// not keep the owner details from callers who do.
+func (d *DocH) docResp(c *gin.Context, req rest.DocReq, meta dbtx.DocMeta, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, rest.DocResp{Error: "doc not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.DocResp{Error: "unable to find file meta - " + err.Error()})
+		return
+	}
+	resp := rest.DocResp{Anchor: d.anchorStatus(c, meta)}
+	if req.OwnerEmail == "" || !strings.EqualFold(meta.OwnerEmail, req.OwnerEmail) {
+		resp.Redacted = redactOwner(&meta)
+	}
+	resp.Doc = &meta
+	c.JSON(http.StatusOK, resp)
+}
+
+// anchorStatus reads the blockchain anchor of a document, and tells whether it anchors the hashes of the doc.
+// A blockchain which can not be reached leaves the anchor unavailable, the doc is still returned.
+func (d *DocH) anchorStatus(ctx context.Context, meta dbtx.DocMeta) *rest.AnchorStatus {
+	if meta.BcTknId == "" {
+		return &rest.AnchorStatus{State: rest.AnchorPending}
+	}
+	a, err := d.Bc.GetDocTkn(ctx, meta.BcTknId)
+	if errors.Is(err, bc.ErrTknNotFound) {
+		return &rest.AnchorStatus{State: rest.TokenNotFound}
+	}
+	if err != nil {
+		log.GetLogger(ctx).Warn("unable to read doc anchor", zap.String("docId", meta.DocId), zap.Error(err))
+		return &rest.AnchorStatus{State: rest.AnchorUnavailable, Error: "unable to read anchor from blockchain - " +
+			err.Error()}
+	}
+	ownerEmailMd5Hash, err := d.H.Hash(ctx, strings.NewReader(meta.OwnerEmail))
+	if err != nil {
+		return &rest.AnchorStatus{State: rest.AnchorUnavailable, Error: "unable to generate hash - " + err.Error()}
+	}
+
+	s := &rest.AnchorStatus{State: rest.Anchored, Anchor: restAnchor(a)}
+	if a.DocHash != meta.DocMd5Hash || a.OwnerEmailMd5Hash != ownerEmailMd5Hash {
+		s.State = rest.AnchorMismatch
+	}
+	if !a.Validity.IsZero() {
+		s.Validity = validityState(a.Validity, time.Now())
+	}
+	return s
+}
+
+// redactOwner masks the email of the owner of a doc and leaves out their name, it returns the fields redacted
+func redactOwner(meta *dbtx.DocMeta) []string {
+	meta.OwnerEmail = maskEmail(meta.OwnerEmail)
+	meta.OwnerFirstName, meta.OwnerLastName = "", ""
+	return []string{"ownerEmail", "ownerFirstName", "ownerLastName"}
+}
+
+// ListDocs lists the documents of an owner newest first, a page at a time. Anyone may list the documents of an
+// owner, so the owner details are always redacted.
+func (d *DocH) ListDocs(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("list documents request received")
+
+	var req rest.ListDocsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.ListDocsResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	if !req.UploadedBefore.IsZero() && !req.UploadedAfter.Before(req.UploadedBefore) {
+		c.JSON(http.StatusBadRequest,
+			rest.ListDocsResp{Error: "req validation failed - uploadedAfter must be before uploadedBefore"})
+		return
+	}
+	tags, err := tagFilters(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rest.ListDocsResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	f := dbtx.DocFilter{
+		OwnerEmail:     req.Owner,
+		UploadedAfter:  req.UploadedAfter,
+		UploadedBefore: req.UploadedBefore,
+		Status:         req.Status,
+		Tags:           tags,
+		Limit:          req.Limit,
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultSearchLimit
+	}
+	if req.Cursor != "" {
+		after, err := decodeDocCursor(req.Cursor, f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, rest.ListDocsResp{Error: "req validation failed - " + err.Error()})
+			return
+		}
+		f.After = &after
+	}
+
+	docs, next, err := d.Db.ListDocs(c, f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError,
+			rest.ListDocsResp{Error: fmt.Errorf("unable to list docs in db - %w", err).Error()})
+		return
+	}
+	resp := rest.ListDocsResp{Owner: req.Owner, Docs: docs}
+	for i := range resp.Docs {
+		resp.Redacted = redactOwner(&resp.Docs[i])
+	}
+	if next != nil {
+		resp.NextCursor = encodeDocCursor(*next, f)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// docCursor is where a page of listed docs ended, along with the filters the docs were listed with
+type docCursor struct {
+	UploadedAt int64  `json:"t"`
+	Id         int64  `json:"i"`
+	Filter     string `json:"f"`
+}
+
+// encodeDocCursor encodes where a page of listed docs ended as an opaque cursor, which only lists the
+// next page with the same filters
+func encodeDocCursor(after dbtx.DocCursor, f dbtx.DocFilter) string {
+	// uploaded times are kept in microseconds
+	b, _ := json.Marshal(docCursor{UploadedAt: after.UploadedAt.UnixMicro(), Id: after.Id, Filter: docFilterId(f)})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeDocCursor decodes where the previous page of listed docs ended
+func decodeDocCursor(cursor string, f dbtx.DocFilter) (dbtx.DocCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	var dc docCursor
+	if err == nil {
+		err = json.Unmarshal(b, &dc)
+	}
+	if err != nil || dc.Id <= 0 {
+		return dbtx.DocCursor{}, errors.New("invalid cursor")
+	}
+	if dc.Filter != docFilterId(f) {
+		return dbtx.DocCursor{}, errors.New("cursor was issued for other filters")
+	}
+	return dbtx.DocCursor{UploadedAt: time.UnixMicro(dc.UploadedAt).UTC(), Id: dc.Id}, nil
+}
+
+// docFilterId identifies the filters docs are listed with, the page size is not one of them
+func docFilterId(f dbtx.DocFilter) string {
+	// json orders the keys of tags, so the same tags are always identified alike
+	tags, _ := json.Marshal(f.Tags)
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s|%s", strings.ToLower(f.OwnerEmail),
+		f.UploadedAfter.UnixMicro(), f.UploadedBefore.UnixMicro(), f.Status, tags)))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// tknStore finds the docs of a claimStore by their tkn
+type tknStore struct {
+	*claimStore
+}
+
+func (s tknStore) GetDocMetaByTkn(_ context.Context, bcTknId string) (dbtx.DocMeta, error) {
+	for _, doc := range s.docs {
+		if doc.BcTknId == bcTknId {
+			return doc, nil
+		}
+	}
+	return dbtx.DocMeta{}, sql.ErrNoRows
+}
+
+func TestDocH_GetDoc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const owner = "john.doe@example.com"
+	ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader(owner))
+	require.NoError(t, err)
+	docId, pendingId, forgedId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a", "1c8f4f5d-4b50-4c5f-8e66-5b4d204060fb",
+		"2d905f6e-5c61-4d60-9f77-6c5e31517100"
+	doc := dbtx.DocMeta{DocId: docId, DocName: "doc.txt", DocMd5Hash: contentMd5, BcTknId: "0xabc",
+		OwnerEmail: owner, OwnerFirstName: "John", OwnerLastName: "Doe"}
+	d := &DocH{
+		Db: tknStore{&claimStore{docs: map[string]dbtx.DocMeta{
+			docId:     doc,
+			pendingId: {DocId: pendingId, OwnerEmail: owner},
+			forgedId:  {DocId: forgedId, DocMd5Hash: contentMd5, BcTknId: "0xdef", OwnerEmail: "jane@example.com"},
+		}}},
+		Bc: anchorBc{anchors: map[string]bc.Anchor{
+			"0xabc": {DocHash: contentMd5, OwnerEmailMd5Hash: ownerMd5, TokenId: "7", BlockNumber: 42},
+			"0xdef": {DocHash: contentMd5, OwnerEmailMd5Hash: ownerMd5, TokenId: "8"},
+		}},
+		H: hash.Md5{},
+	}
+	get := func(handler gin.HandlerFunc, path string, params gin.Params) (int, rest.DocResp) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+		c.Params = params
+		handler(c)
+		var resp rest.DocResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+	byId := func(id, query string) (int, rest.DocResp) {
+		return get(d.GetDoc, "/svc/v1/doc/"+id+query, gin.Params{{Key: "docId", Value: id}})
+	}
+
+	status, resp := byId(docId, "?ownerEmail=John.Doe@example.com")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, doc, *resp.Doc)
+	assert.Empty(t, resp.Redacted)
+	assert.Equal(t, rest.Anchored, resp.Anchor.State)
+	assert.Equal(t, &rest.Anchor{TokenId: "7", BlockNumber: 42}, resp.Anchor.Anchor)
+
+	for _, query := range []string{"", "?ownerEmail=jane@example.com"} {
+		status, resp = byId(docId, query)
+		require.Equal(t, http.StatusOK, status, query)
+		assert.Equal(t, "j***@example.com", resp.Doc.OwnerEmail, query)
+		assert.Empty(t, resp.Doc.OwnerFirstName, query)
+		assert.Empty(t, resp.Doc.OwnerLastName, query)
+		assert.Equal(t, contentMd5, resp.Doc.DocMd5Hash, query)
+		assert.Equal(t, []string{"ownerEmail", "ownerFirstName", "ownerLastName"}, resp.Redacted, query)
+	}
+
+	status, resp = get(d.GetDocByTkn, "/svc/v1/doc/by-token/0xABC", gin.Params{{Key: "tokenId", Value: "0xABC"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, docId, resp.Doc.DocId)
+	assert.Equal(t, rest.Anchored, resp.Anchor.State)
+
+	_, resp = byId(pendingId, "")
+	assert.Equal(t, rest.AnchorPending, resp.Anchor.State)
+	_, resp = byId(forgedId, "")
+	assert.Equal(t, rest.AnchorMismatch, resp.Anchor.State)
+
+	d.Bc = fakeBc{verifyErr: errors.New("chain is down")}
+	status, resp = byId(docId, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, rest.AnchorUnavailable, resp.Anchor.State)
+	assert.Contains(t, resp.Anchor.Error, "chain is down")
+	d.Bc = fakeBc{verifyErr: bc.ErrTknNotFound}
+	_, resp = byId(docId, "")
+	assert.Equal(t, rest.TokenNotFound, resp.Anchor.State)
+
+	status, _ = byId("3ea16f7f-6d72-4e71-8088-7d6f42628211", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = get(d.GetDocByTkn, "/svc/v1/doc/by-token/0x123", gin.Params{{Key: "tokenId", Value: "0x123"}})
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = byId("not-a-doc", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = byId(docId, "?ownerEmail=john")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+// listStore lists docs newest first, as they are ordered by their upload time and id
+type listStore struct {
+	dbtx.MockStore
+	docs []dbtx.DocMeta
+	ids  []int64
+	got  []dbtx.DocFilter
+}
+
+func (s *listStore) ListDocs(_ context.Context, f dbtx.DocFilter) ([]dbtx.DocMeta, *dbtx.DocCursor, error) {
+	s.got = append(s.got, f)
+	out, last := []dbtx.DocMeta{}, 0
+	for i, doc := range s.docs {
+		if doc.OwnerEmail != f.OwnerEmail || f.Status == dbtx.DocRevoked && doc.RevokedAt == nil ||
+			!hasTags(doc, f.Tags) {
+			continue
+		}
+		if a := f.After; a != nil && (doc.UploadedAt.After(a.UploadedAt) ||
+			doc.UploadedAt.Equal(a.UploadedAt) && s.ids[i] >= a.Id) {
+			continue
+		}
+		if len(out) == f.Limit {
+			return out, &dbtx.DocCursor{UploadedAt: *s.docs[last].UploadedAt, Id: s.ids[last]}, nil
+		}
+		out, last = append(out, doc), i
+	}
+	return out, nil, nil
+}
+
+// hasTags tells whether a doc has all the given tags
+func hasTags(doc dbtx.DocMeta, tags map[string]string) bool {
+	for k, v := range tags {
+		if got, ok := doc.Tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDocH_ListDocs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const owner = "john.doe@example.com"
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	db := &listStore{}
+	// docs are listed newest first, the last two were uploaded at the same time
+	for i, at := range []time.Time{now, now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(-3 * time.Minute),
+		now.Add(-3 * time.Minute)} {
+		doc := dbtx.DocMeta{DocId: fmt.Sprintf("doc-%d", i), OwnerEmail: owner, UploadedAt: &at}
+		if i%2 == 1 {
+			doc.RevokedAt = &at
+		}
+		if i >= 2 {
+			doc.Tags = map[string]string{"dept": "hr", "year": fmt.Sprint(2020 + i)}
+		}
+		db.docs, db.ids = append(db.docs, doc), append(db.ids, int64(10-i))
+	}
+	db.docs = append(db.docs, dbtx.DocMeta{DocId: "other", OwnerEmail: "jane@example.com", UploadedAt: &now})
+	db.ids = append(db.ids, 1)
+	d := &DocH{Db: db}
+	list := func(query url.Values) (int, rest.ListDocsResp) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/svc/v1/doc?"+query.Encode(), nil)
+		d.ListDocs(c)
+		var resp rest.ListDocsResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	var ids []string
+	query := url.Values{"owner": {owner}, "limit": {"2"}}
+	for page := 0; page < 5; page++ {
+		status, resp := list(query)
+		require.Equal(t, http.StatusOK, status)
+		for _, doc := range resp.Docs {
+			ids = append(ids, doc.DocId)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", resp.NextCursor)
+	}
+	assert.Equal(t, []string{"doc-0", "doc-1", "doc-2", "doc-3", "doc-4"}, ids)
+	require.Len(t, db.got, 3)
+	assert.Equal(t, &dbtx.DocCursor{UploadedAt: now.Add(-time.Minute), Id: 9}, db.got[1].After)
+
+	// the owner details are redacted, even for a caller claiming to be the owner
+	status, resp := list(url.Values{"owner": {owner}, "limit": {"1"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "j***@example.com", resp.Docs[0].OwnerEmail)
+	assert.Equal(t, []string{"ownerEmail", "ownerFirstName", "ownerLastName"}, resp.Redacted)
+	status, resp = list(url.Values{"owner": {owner}, "ownerEmail": {owner}, "limit": {"1"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "j***@example.com", resp.Docs[0].OwnerEmail)
+	assert.Empty(t, resp.Docs[0].OwnerFirstName)
+	assert.Equal(t, []string{"ownerEmail", "ownerFirstName", "ownerLastName"}, resp.Redacted)
+
+	status, resp = list(url.Values{"owner": {owner}, "status": {"revoked"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, resp.Docs, 2)
+	assert.Empty(t, resp.NextCursor)
+	assert.Equal(t, defaultSearchLimit, db.got[len(db.got)-1].Limit)
+
+	after, before := now.Add(-time.Hour), now.Add(time.Hour)
+	status, _ = list(url.Values{"owner": {owner}, "uploadedAfter": {after.Format(time.RFC3339)},
+		"uploadedBefore": {before.Format(time.RFC3339)}})
+	require.Equal(t, http.StatusOK, status)
+	got := db.got[len(db.got)-1]
+	assert.True(t, got.UploadedAfter.Equal(after.Truncate(time.Second)))
+	assert.True(t, got.UploadedBefore.Equal(before.Truncate(time.Second)))
+
+	// docs are listed by tags, and the cursor of a page lists the next page with the same tags
+	query = url.Values{"owner": {owner}, "tag": {"year:2023", "dept:hr"}, "limit": {"1"}}
+	status, resp = list(query)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"dept": "hr", "year": "2023"}, db.got[len(db.got)-1].Tags)
+	require.Len(t, resp.Docs, 1)
+	assert.Equal(t, "doc-3", resp.Docs[0].DocId)
+	assert.Empty(t, resp.NextCursor)
+	query = url.Values{"owner": {owner}, "tag": {"dept:hr"}, "limit": {"2"}}
+	_, resp = list(query)
+	assert.Len(t, resp.Docs, 2)
+	tagCursor := resp.NextCursor
+	require.NotEmpty(t, tagCursor)
+	query.Set("cursor", tagCursor)
+	status, resp = list(query)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp.Docs, 1)
+	assert.Equal(t, "doc-4", resp.Docs[0].DocId)
+
+	_, resp = list(url.Values{"owner": {owner}, "limit": {"2"}})
+	cursor := resp.NextCursor
+	for name, query := range map[string]url.Values{
+		"no owner":        {"limit": {"2"}},
+		"unknown status":  {"owner": {owner}, "status": {"lost"}},
+		"limit too large": {"owner": {owner}, "limit": {"1000"}},
+		"not a time":      {"owner": {owner}, "uploadedAfter": {"yesterday"}},
+		"empty time window": {"owner": {owner}, "uploadedAfter": {before.Format(time.RFC3339)},
+			"uploadedBefore": {after.Format(time.RFC3339)}},
+		"not a cursor":       {"owner": {owner}, "cursor": {"page-2"}},
+		"cursor of a filter": {"owner": {owner}, "status": {"active"}, "cursor": {cursor}},
+		"cursor of an owner": {"owner": {"jane@example.com"}, "cursor": {cursor}},
+		"not a tag filter":   {"owner": {owner}, "tag": {"dept"}},
+		"cursor of no tags":  {"owner": {owner}, "tag": {"dept:hr"}, "cursor": {cursor}},
+		"cursor of tags":     {"owner": {owner}, "tag": {"dept:it"}, "cursor": {tagCursor}},
+	} {
+		status, resp := list(query)
+		assert.Equal(t, http.StatusBadRequest, status, name)
+		assert.NotEmpty(t, resp.Error, name)
+	}
+}
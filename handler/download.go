@@ -1,11 +1,19 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
 	"github.com/vposham/trustdoc/log"
 	"github.com/vposham/trustdoc/pkg/rest"
 )
@@ -17,21 +25,128 @@ func (d *DocH) Download(c *gin.Context) {
 	var req rest.DownloadReq
 	err := c.BindUri(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "req validation failed - " + err.Error()})
+		problem(c, http.StatusBadRequest, "req validation failed - "+err.Error())
+		return
+	}
+	// the doc can also be checked against its blockchain anchor before it is sent
+	verify, err := strconv.ParseBool(c.DefaultQuery("verify", "false"))
+	if err != nil {
+		problem(c, http.StatusBadRequest, "req validation failed - invalid verify - "+err.Error())
 		return
 	}
 
-	doc, err := d.Blob.Get(c, req.DocId)
+	// the doc is looked up before its content, which can only fail once the response is under way
+	meta, err := d.Db.GetDocMeta(c, req.DocId)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, "doc not found")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to find file" + err.Error()})
+		problem(c, http.StatusInternalServerError, "unable to find file meta - "+err.Error())
 		return
 	}
+	if gone := docGone(meta); gone != "" {
+		problem(c, http.StatusGone, gone)
+		return
+	}
+	info, err := d.Blob.Stat(c, req.DocId)
+	if errors.Is(err, blob.ErrNotFound) {
+		// a doc which is still served has lost its content, only docs which were removed are gone
+		go d.raiseIntegrityAlert(detach(c), rest.DocIntegrityEvent{Check: rest.PresenceCheck, DocId: meta.DocId,
+			DocHash: meta.DocMd5Hash, BcTknId: meta.BcTknId})
+		problem(c, http.StatusInternalServerError, "doc is missing from store")
+		return
+	}
+	if err != nil {
+		problem(c, http.StatusServiceUnavailable, "unable to reach blob store - "+err.Error())
+		return
+	}
+	// the doc hash is a strong etag, which lets caches validate their copy and clients resume downloads
+	etag := docETag(meta)
+	c.Header("ETag", etag)
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Accept-Ranges", "bytes")
+	if notModified(c.Request, etag, info.LastModified) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	rng, err := docRange(c.Request, etag, info.LastModified, info.Size)
+	if err != nil {
+		c.Header("Content-Range", "bytes */"+strconv.FormatInt(info.Size, 10))
+		problem(c, http.StatusRequestedRangeNotSatisfiable, err.Error())
+		return
+	}
+	if verify && !d.verifyAnchor(c, meta) {
+		return
+	}
+
+	doc, err := d.Blob.Get(c, req.DocId, rng)
+	if err != nil {
+		problem(c, http.StatusServiceUnavailable, "unable to reach blob store - "+err.Error())
+		return
+	}
+	if rc, ok := doc.(io.Closer); ok {
+		defer func() { _ = rc.Close() }()
+	}
+
+	contentType := meta.MimeType
+	if contentType == "" {
+		contentType = dbtx.DefaultMimeType
+	}
+	c.Header("Content-Disposition", contentDisposition(meta.DocName))
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	status, length := http.StatusOK, info.Size
+	if rng != nil {
+		status, length = http.StatusPartialContent, rng.Len()
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.Start, rng.End, info.Size))
+	}
+	// a whole doc is hashed as it is sent, its sha-256 digests follow it as trailers for clients which take them.
+	// http/1.1 only sends trailers after a chunked body, which has no Content-Length.
+	h := contentHasher(meta)
+	trailers := rng == nil && h != nil && acceptsTrailers(c.Request)
+	if trailers {
+		c.Header("Trailer", "Repr-Digest, Content-Digest")
+	}
+	if !trailers || c.Request.ProtoMajor >= 2 {
+		c.Header("Content-Length", strconv.FormatInt(length, 10))
+	}
+	c.Status(status)
 
-	c.Header("Content-Disposition", "attachment; filename="+req.DocId)
-	c.Header("Content-Type", c.Request.Header.Get("Content-Type"))
-	_, err = io.Copy(c.Writer, doc)
+	var digest string
+	if rng != nil || h == nil {
+		_, err = io.Copy(c.Writer, doc)
+	} else {
+		digest, err = verifiedCopy(c, c.Writer, doc, h, meta.DocMd5Hash)
+	}
+	var ie *integrityErr
+	if errors.As(err, &ie) {
+		// the doc is cut short of its Content-Length, so the client does not take it as complete
+		c.Abort()
+		go d.raiseIntegrityAlert(detach(c), rest.DocIntegrityEvent{Check: rest.ContentCheck, DocId: meta.DocId,
+			DocHash: meta.DocMd5Hash, ContentHash: ie.contentHash, BcTknId: meta.BcTknId})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to find file" + err.Error()})
+		// headers are already sent, so the client only sees a truncated body
+		logger.Error("unable to send file", zap.String("docId", req.DocId), zap.Error(err))
 		return
 	}
+	if trailers {
+		c.Writer.Header().Set("Repr-Digest", digest)
+		c.Writer.Header().Set("Content-Digest", digest)
+	}
+}
+
+// docGone tells why a document which is no longer served was removed, it is empty for a served doc
+func docGone(meta dbtx.DocMeta) string {
+	switch {
+	case meta.RevokedAt != nil:
+		return "doc was revoked at " + meta.RevokedAt.UTC().Format(time.RFC3339)
+	case meta.DeletedAt != nil:
+		return "doc was deleted at " + meta.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return ""
 }
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// contentMd5 is the md5 hash of 0123456789, whose sha-256 Repr-Digest is contentDigest
+const (
+	contentMd5    = "781e5e245d69b566979b86e28d23f2c7"
+	contentDigest = "sha-256=:hNiYd/DUBB77a/kaFvAkjy/Vc+avBcGflr7bn4gveII=:"
+)
+
+func TestDocH_Download(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	d := &DocH{
+		Db: &claimStore{docs: map[string]dbtx.DocMeta{docId: {DocId: docId, DocName: "doc.txt",
+			DocMd5Hash: contentMd5, MimeType: "text/plain"}}},
+		Blob: memBlob{docId: []byte("0123456789")},
+	}
+	download := func(hdr map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/svc/v1/doc/download/"+docId, nil)
+		for k, v := range hdr {
+			c.Request.Header.Set(k, v)
+		}
+		c.Params = gin.Params{{Key: "docId", Value: docId}}
+		d.Download(c)
+		return w
+	}
+
+	w := download(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, `"`+contentMd5+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Result().Trailer)
+
+	// the sha-256 digests of a whole doc follow it as trailers, for clients taking them
+	w = download(map[string]string{"TE": "trailers"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	trailer := w.Result().Trailer
+	assert.Equal(t, contentDigest, trailer.Get("Repr-Digest"))
+	assert.Equal(t, contentDigest, trailer.Get("Content-Digest"))
+
+	w = download(map[string]string{"Range": "bytes=2-4", "TE": "trailers"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "234", w.Body.String())
+	assert.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+	assert.Equal(t, "3", w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Result().Trailer)
+
+	w = download(map[string]string{"Range": "bytes=2-4", "If-Range": `"hash-2"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	w = download(map[string]string{"If-None-Match": `"` + contentMd5 + `"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, `"`+contentMd5+`"`, w.Header().Get("ETag"))
+
+	w = download(map[string]string{"Range": "bytes=10-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */10", w.Header().Get("Content-Range"))
+}
+
+func TestDocH_DownloadTrailers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	d := &DocH{
+		Db:   &claimStore{docs: map[string]dbtx.DocMeta{docId: {DocId: docId, DocMd5Hash: contentMd5}}},
+		Blob: memBlob{docId: []byte("0123456789")},
+	}
+	r := gin.New()
+	r.GET("/svc/v1/doc/download/:docId", d.Download)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/svc/v1/doc/download/"+docId, nil)
+	require.NoError(t, err)
+	req.Header.Set("TE", "trailers")
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(body))
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.Equal(t, contentDigest, resp.Trailer.Get("Repr-Digest"))
+	assert.Equal(t, contentDigest, resp.Trailer.Get("Content-Digest"))
+}
+
+// downBlob is a blob store which can not be reached
+type downBlob struct{ memBlob }
+
+func (downBlob) Stat(context.Context, string) (blob.ObjInfo, error) {
+	return blob.ObjInfo{}, errors.New("connection refused")
+}
+
+func TestDocH_DownloadNotServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored, revoked, deleted, missing, unknown := uuid.NewString(), uuid.NewString(), uuid.NewString(),
+		uuid.NewString(), uuid.NewString()
+	removedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	docs := map[string]dbtx.DocMeta{
+		stored:  {DocId: stored, DocMd5Hash: contentMd5},
+		revoked: {DocId: revoked, DocMd5Hash: contentMd5, RevokedAt: &removedAt},
+		deleted: {DocId: deleted, DocMd5Hash: contentMd5, DeletedAt: &removedAt},
+		missing: {DocId: missing, DocMd5Hash: contentMd5},
+	}
+	content := memBlob{stored: []byte("0123456789"), revoked: []byte("0123456789"), deleted: []byte("0123456789")}
+
+	tests := []struct {
+		name   string
+		docId  string
+		blob   blob.OpsIf
+		status int
+		detail string
+	}{
+		{name: "unknown doc", docId: unknown, blob: content, status: http.StatusNotFound, detail: "doc not found"},
+		{name: "revoked doc", docId: revoked, blob: content, status: http.StatusGone,
+			detail: "doc was revoked at 2024-05-01T00:00:00Z"},
+		{name: "deleted doc", docId: deleted, blob: content, status: http.StatusGone,
+			detail: "doc was deleted at 2024-05-01T00:00:00Z"},
+		{name: "doc missing from blob store", docId: missing, blob: content,
+			status: http.StatusInternalServerError, detail: "doc is missing from store"},
+		{name: "blob store down", docId: stored, blob: downBlob{content}, status: http.StatusServiceUnavailable,
+			detail: "unable to reach blob store - connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DocH{Db: &claimStore{docs: docs}, Blob: tt.blob}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/svc/v1/doc/download/"+tt.docId, nil)
+			c.Params = gin.Params{{Key: "docId", Value: tt.docId}}
+			d.Download(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, rest.ProblemContentType, w.Header().Get("Content-Type"))
+			var p rest.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, rest.Problem{Type: "about:blank", Title: http.StatusText(tt.status), Status: tt.status,
+				Detail: tt.detail, Instance: "/svc/v1/doc/download/" + tt.docId}, p)
+		})
+	}
+}
+
+func TestDocH_DownloadIntegrity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	alerts := make(chan rest.DocIntegrityEvent, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev rest.DocIntegrityEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		alerts <- ev
+	}))
+	defer webhook.Close()
+
+	meta := dbtx.DocMeta{DocId: docId, DocName: "doc.txt", DocMd5Hash: contentMd5, BcTknId: "tkn-1",
+		OwnerEmail: "john.doe@example.com"}
+	newDocH := func(content string, b fakeBc) *DocH {
+		return &DocH{
+			Db:        &claimStore{docs: map[string]dbtx.DocMeta{docId: meta}},
+			Blob:      memBlob{docId: []byte(content)},
+			Bc:        b,
+			H:         hash.Md5{},
+			Integrity: &IntegrityAlert{WebhookUrl: webhook.URL},
+		}
+	}
+	download := func(d *DocH, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/svc/v1/doc/download/"+docId+query, nil)
+		c.Params = gin.Params{{Key: "docId", Value: docId}}
+		d.Download(c)
+		return w
+	}
+	alerted := func() rest.DocIntegrityEvent {
+		select {
+		case ev := <-alerts:
+			return ev
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "integrity alert not raised")
+			return rest.DocIntegrityEvent{}
+		}
+	}
+
+	t.Run("corrupt doc is cut short", func(t *testing.T) {
+		w := download(newDocH("0123456780", fakeBc{}), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "10", w.Header().Get("Content-Length"))
+		assert.Equal(t, "012345678", w.Body.String())
+		ev := alerted()
+		assert.Equal(t, rest.DocIntegrityEventType, ev.Type)
+		assert.Equal(t, rest.ContentCheck, ev.Check)
+		assert.Equal(t, docId, ev.DocId)
+		assert.Equal(t, contentMd5, ev.DocHash)
+		assert.NotEqual(t, contentMd5, ev.ContentHash)
+	})
+
+	t.Run("doc missing from blob store", func(t *testing.T) {
+		d := newDocH("", fakeBc{})
+		d.Blob = memBlob{}
+		w := download(d, "")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		ev := alerted()
+		assert.Equal(t, rest.PresenceCheck, ev.Check)
+		assert.Equal(t, docId, ev.DocId)
+		assert.Equal(t, contentMd5, ev.DocHash)
+	})
+
+	t.Run("doc matching its anchor", func(t *testing.T) {
+		w := download(newDocH("0123456789", fakeBc{}), "?verify=true")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "0123456789", w.Body.String())
+	})
+
+	t.Run("doc not matching its anchor", func(t *testing.T) {
+		w := download(newDocH("0123456789", fakeBc{verifyErr: bc.ErrTknMismatch}), "?verify=true")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		ev := alerted()
+		assert.Equal(t, rest.AnchorCheck, ev.Check)
+		assert.Equal(t, "tkn-1", ev.BcTknId)
+	})
+
+	t.Run("blockchain unavailable", func(t *testing.T) {
+		w := download(newDocH("0123456789", fakeBc{verifyErr: errors.New("node down")}), "?verify=true")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// ExpiryNotice announces documents whose validity ends soon. Each document is announced once,
+// with a doc.expiring event which is logged and posted to a webhook when one is set.
+type ExpiryNotice struct {
+	// Interval is how often documents about to expire are looked for
+	Interval time.Duration
+	// Within is how long before the end of its validity a document is announced
+	Within time.Duration
+	// BatchSize is the max number of documents announced at once
+	BatchSize int
+	// WebhookUrl receives the events as json when it is set
+	WebhookUrl string
+	Client     *http.Client
+}
+
+// NotifyExpiring periodically announces documents about to expire, until ctx is done
+func (d *DocH) NotifyExpiring(ctx context.Context) {
+	if d.Expiry == nil {
+		return
+	}
+	t := time.NewTicker(d.Expiry.Interval)
+	defer t.Stop()
+	for {
+		d.notifyExpiring(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// notifyExpiring announces a batch of documents about to expire and returns how many were announced.
+// A document which could not be announced is released, so it is announced by a later run.
+func (d *DocH) notifyExpiring(ctx context.Context) int {
+	logger := log.GetLogger(ctx)
+	docs, err := d.Db.ClaimExpiringDocs(ctx, d.Expiry.Within, d.Expiry.BatchSize)
+	if err != nil {
+		logger.Error("unable to find docs about to expire", zap.Error(err))
+		return 0
+	}
+	notified := 0
+	for _, doc := range docs {
+		if err = d.Expiry.emit(ctx, expiringEvent(doc)); err != nil {
+			logger.Warn("unable to announce doc about to expire", zap.String("docId", doc.DocId), zap.Error(err))
+			if err = d.Db.ReleaseDocExpiryNotice(ctx, doc.DocId); err != nil {
+				logger.Error("unable to release doc expiry notice", zap.String("docId", doc.DocId), zap.Error(err))
+			}
+			continue
+		}
+		notified++
+	}
+	if len(docs) > 0 {
+		logger.Info("announced docs about to expire", zap.Int("expiring", len(docs)), zap.Int("notified", notified))
+	}
+	return notified
+}
+
+func expiringEvent(doc dbtx.DocMeta) rest.DocExpiringEvent {
+	ev := rest.DocExpiringEvent{
+		Type:       rest.DocExpiringEventType,
+		DocId:      doc.DocId,
+		DocTitle:   doc.DocTitle,
+		OwnerEmail: doc.OwnerEmail,
+		BcTknId:    doc.BcTknId,
+	}
+	if doc.ValidUntil != nil {
+		ev.ValidUntil = *doc.ValidUntil
+	}
+	return ev
+}
+
+// emit logs an event and posts it to the webhook, which has to accept it with a 2xx status
+func (n *ExpiryNotice) emit(ctx context.Context, ev rest.DocExpiringEvent) error {
+	log.GetLogger(ctx).Info("doc about to expire", zap.String("event", ev.Type), zap.String("docId", ev.DocId),
+		zap.String("ownerEmail", ev.OwnerEmail), zap.Time("validUntil", ev.ValidUntil))
+	if n.WebhookUrl == "" {
+		return nil
+	}
+	return postEvent(ctx, n.Client, n.WebhookUrl, ev)
+}
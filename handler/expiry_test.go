@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// expiryStore keeps docs about to expire in memory, and which of them were claimed for a notice
+type expiryStore struct {
+	dbtx.MockStore
+	expiring []dbtx.DocMeta
+	claimed  map[string]bool
+	claimErr error
+}
+
+func (s *expiryStore) ClaimExpiringDocs(_ context.Context, _ time.Duration, maxDocs int) ([]dbtx.DocMeta, error) {
+	if s.claimErr != nil {
+		return nil, s.claimErr
+	}
+	var out []dbtx.DocMeta
+	for _, doc := range s.expiring {
+		if !s.claimed[doc.DocId] && len(out) < maxDocs {
+			s.claimed[doc.DocId] = true
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+func (s *expiryStore) ReleaseDocExpiryNotice(_ context.Context, docId string) error {
+	delete(s.claimed, docId)
+	return nil
+}
+
+func TestDocH_notifyExpiring(t *testing.T) {
+	until := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &expiryStore{
+		expiring: []dbtx.DocMeta{
+			{DocId: "doc-1", DocTitle: "Diploma", OwnerEmail: "john.doe@example.com", BcTknId: "tkn-1", ValidUntil: &until},
+			{DocId: "doc-2", DocTitle: "Passport", OwnerEmail: "jane.roe@example.com", BcTknId: "tkn-2", ValidUntil: &until},
+		},
+		claimed: make(map[string]bool),
+	}
+
+	var got []rest.DocExpiringEvent
+	failDoc := "doc-2"
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev rest.DocExpiringEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		if ev.DocId == failDoc {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		got = append(got, ev)
+	}))
+	defer webhook.Close()
+
+	d := &DocH{Db: store, Expiry: &ExpiryNotice{Within: 24 * time.Hour, BatchSize: 10, WebhookUrl: webhook.URL}}
+	assert.Equal(t, 1, d.notifyExpiring(context.Background()))
+	assert.Equal(t, []rest.DocExpiringEvent{{Type: rest.DocExpiringEventType, DocId: "doc-1", DocTitle: "Diploma",
+		OwnerEmail: "john.doe@example.com", BcTknId: "tkn-1", ValidUntil: until}}, got)
+
+	// a doc the webhook did not accept is announced again, announced docs are not
+	failDoc = ""
+	assert.Equal(t, 1, d.notifyExpiring(context.Background()))
+	require.Len(t, got, 2)
+	assert.Equal(t, "doc-2", got[1].DocId)
+	assert.Equal(t, 0, d.notifyExpiring(context.Background()))
+
+	t.Run("docs can not be found", func(t *testing.T) {
+		d := &DocH{Db: &expiryStore{claimErr: errors.New("db down")}, Expiry: &ExpiryNotice{BatchSize: 10}}
+		assert.Equal(t, 0, d.notifyExpiring(context.Background()))
+	})
+}
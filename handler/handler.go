@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"time"
+
 	"github.com/vposham/trustdoc/internal/bc"
 	"github.com/vposham/trustdoc/internal/blob"
 	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
 	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/internal/policy"
+	"github.com/vposham/trustdoc/internal/scan"
 )
 
 // DocH will have all the dependencies this handler will have
@@ -13,4 +17,66 @@ type DocH struct {
 	Blob blob.OpsIf
 	Bc   bc.OpsIf
 	H    hash.Md5
+
+	// Tree is set when document content is hashed as a chunked merkle tree instead of md5
+	Tree hash.TreeHasher
+
+	// Similar is set when image documents are checked for visually similar documents
+	Similar *Similarity
+
+	// Policy decides the documents accepted for upload
+	Policy *policy.Policy
+
+	// Scanner is set when documents are scanned for malware
+	Scanner scan.Scanner
+
+	// Duplicates decides what uploaders of a document owned by someone else are told of it
+	Duplicates DuplicatePolicy
+
+	// Claims notifies owners of claims of co-ownership on their documents, when duplicates are claimed
+	Claims *ClaimNotice
+
+	// Recovery is set when interrupted uploads are finished or rolled back in background
+	Recovery *UploadRecovery
+
+	// DirectCleanup is set when direct uploads which expired are cleaned up in background
+	DirectCleanup *DirectCleanup
+
+	// Expiry is set when documents about to expire are announced in background
+	Expiry *ExpiryNotice
+
+	// Receipts is set when verifications are answered with a signed receipt
+	Receipts *Receipts
+
+	// Integrity raises alerts for documents found corrupt or not matching their blockchain anchor
+	Integrity *IntegrityAlert
+
+	// TusMaxSize is the largest document accepted through resumable uploads
+	TusMaxSize int64
+	// FinishTimeout is how long an upload is left finishing before it is finished again, as the instance which
+	// was finishing it went down
+	FinishTimeout time.Duration
+	// IdempotencyTTL is how long responses of requests sent with an Idempotency-Key are kept for replay
+	IdempotencyTTL time.Duration
+	// IdempotencyLease is how long the Idempotency-Key of a request in progress is held without being renewed,
+	// before a retry takes it over
+	IdempotencyLease time.Duration
+	// Bulk limits bulk uploads of documents in an archive
+	Bulk BulkLimits
+	// Batch limits batch verifications of documents
+	Batch BatchLimits
+	// PageBaseUrl is the public url verification pages are linked at, the host of the request when empty,
+	// in which case qr codes are kept out of shared caches
+	PageBaseUrl string
+	// PresignGetTTL and PresignPutTTL are how long presigned download and direct upload urls are valid for
+	PresignGetTTL time.Duration
+	PresignPutTTL time.Duration
+}
+
+// docHasher returns the hasher configured for document content
+func (d *DocH) docHasher() hash.Hasher {
+	if d.Tree != nil {
+		return d.Tree
+	}
+	return d.H
 }
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+)
+
+const (
+	// idempotencyKeyHdr is sent by clients to make a mutating request safe to retry
+	idempotencyKeyHdr = "Idempotency-Key"
+	// idempotentReplayedHdr is set on responses replayed for a retried request
+	idempotentReplayedHdr = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotent runs fn, which writes the response, at most once for the Idempotency-Key of the request.
+// Keys are scoped to the caller, the owner the request is made for, so that the same key sent by another
+// caller is another request. A retry with the same key and fingerprint gets the stored response, while
+// reusing a key for another request is unprocessable. Server errors and conflicts, which do not last, release
+// the key, so the request can be retried. The key is leased while fn runs, a retry takes over the key of
+// a request whose instance went down once its lease lapses. It returns false when the response was written
+// without running fn.
+func (d *DocH) idempotent(c *gin.Context, caller, fingerprint string, errResp func(error) any, fn func()) bool {
+	header := c.GetHeader(idempotencyKeyHdr)
+	if header == "" {
+		fn()
+		return true
+	}
+	logger := log.GetLogger(c).With(zap.String("idempotencyKey", header))
+	if len(header) > maxIdempotencyKeyLen {
+		c.JSON(http.StatusBadRequest, errResp(fmt.Errorf("req validation failed - %s is longer than %d",
+			idempotencyKeyHdr, maxIdempotencyKeyLen)))
+		return false
+	}
+	key := callerKey(caller, header)
+
+	// the same key can not be used with another endpoint
+	sum := sha256.Sum256([]byte(c.Request.Method + " " + c.FullPath() + "\n" + fingerprint))
+	fp := hex.EncodeToString(sum[:])
+	existing, claimed, err := d.Db.ClaimIdempotencyKey(c, key, fp, d.IdempotencyTTL, d.IdempotencyLease)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errResp(fmt.Errorf("unable to claim idempotency key - %w", err)))
+		return false
+	}
+	if !claimed {
+		switch {
+		case existing.Fingerprint != fp:
+			logger.Warn("idempotency key reused for another request")
+			c.JSON(http.StatusUnprocessableEntity,
+				errResp(errors.New("idempotency key was already used for another request")))
+		case existing.Response == nil:
+			c.JSON(http.StatusConflict, errResp(errors.New("request with this idempotency key is in progress")))
+		default:
+			logger.Info("replaying response of idempotent request", zap.Int("status", existing.Response.Status))
+			for k, v := range existing.Response.Headers {
+				c.Writer.Header()[k] = v
+			}
+			c.Header(idempotentReplayedHdr, "true")
+			c.Data(existing.Response.Status, existing.Response.Headers.Get("Content-Type"), existing.Response.Body)
+		}
+		return false
+	}
+
+	rec := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = rec
+	completed := false
+	stopRenewal := d.renewIdempotencyLease(c.Copy(), key)
+	defer func() {
+		stopRenewal()
+		c.Writer = rec.ResponseWriter
+		// a panic, server error or conflict leaves the request unprocessed, so it can be retried with the same key
+		if completed {
+			return
+		}
+		if err := d.Db.ReleaseIdempotencyKey(c, key); err != nil {
+			logger.Error("unable to release idempotency key", zap.Error(err))
+		}
+	}()
+	fn()
+
+	if rec.Status() >= http.StatusInternalServerError || rec.Status() == http.StatusConflict {
+		return true
+	}
+	resp := dbtx.IdemResponse{Status: rec.Status(), Headers: rec.Header().Clone(), Body: rec.body.Bytes()}
+	if err = d.Db.CompleteIdempotencyKey(c, key, resp); err != nil {
+		logger.Error("unable to store response of idempotent request", zap.Error(err))
+		return true
+	}
+	completed = true
+	return true
+}
+
+// renewIdempotencyLease renews the lease of a claimed idempotency key every third of the lease, until
+// the returned func is called
+func (d *DocH) renewIdempotencyLease(ctx context.Context, key string) func() {
+	if d.IdempotencyLease <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(d.IdempotencyLease / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := d.Db.ExtendIdempotencyKey(ctx, key, d.IdempotencyLease); err != nil {
+					log.GetLogger(ctx).Warn("unable to renew idempotency key lease", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// callerKey is the idempotency key of a caller as it is stored, which fits the stored key whatever the length
+// of the caller
+func callerKey(caller, key string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(caller) + "\n" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordingWriter keeps a copy of the response body written through it
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+)
+
+// idemStore keeps idempotency keys in memory
+type idemStore struct {
+	dbtx.MockStore
+	keys     map[string]dbtx.IdemKey
+	extended atomic.Int32
+}
+
+func (s *idemStore) ClaimIdempotencyKey(_ context.Context, key, fingerprint string,
+	ttl, lease time.Duration) (dbtx.IdemKey, bool, error) {
+	now := time.Now()
+	if k, ok := s.keys[key]; ok && k.ExpiresAt.After(now) &&
+		(k.Response != nil || k.LockedUntil.After(now) || k.Fingerprint != fingerprint) {
+		return k, false, nil
+	}
+	s.keys[key] = dbtx.IdemKey{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl), LockedUntil: now.Add(lease)}
+	return s.keys[key], true, nil
+}
+
+func (s *idemStore) ExtendIdempotencyKey(_ context.Context, _ string, _ time.Duration) error {
+	s.extended.Add(1)
+	return nil
+}
+
+func (s *idemStore) CompleteIdempotencyKey(_ context.Context, key string, resp dbtx.IdemResponse) error {
+	k := s.keys[key]
+	k.Response = &resp
+	s.keys[key] = k
+	return nil
+}
+
+func (s *idemStore) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	delete(s.keys, key)
+	return nil
+}
+
+func TestDocH_idempotent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &idemStore{keys: make(map[string]dbtx.IdemKey)}
+	d := &DocH{Db: store, IdempotencyTTL: time.Hour, IdempotencyLease: time.Minute}
+
+	runs := 0
+	status := http.StatusCreated
+	caller := "john.doe@example.com"
+	call := func(key, fingerprint string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/upload", nil)
+		if key != "" {
+			c.Request.Header.Set(idempotencyKeyHdr, key)
+		}
+		d.idempotent(c, caller, fingerprint, func(err error) any { return gin.H{"error": err.Error()} }, func() {
+			runs++
+			c.Header("Location", "/uploads/1")
+			c.JSON(status, gin.H{"run": runs})
+		})
+		return w
+	}
+
+	w := call("key-1", "doc")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"run":1}`, w.Body.String())
+
+	// a retry replays the stored response without running again
+	w = call("key-1", "doc")
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"run":1}`, w.Body.String())
+	assert.Equal(t, "/uploads/1", w.Header().Get("Location"))
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayedHdr))
+
+	// the key can not be reused for another request
+	w = call("key-1", "other doc")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, runs)
+
+	// a request still in progress is not run twice
+	fp := store.keys[callerKey(caller, "key-1")].Fingerprint
+	store.keys[callerKey(caller, "key-2")] = dbtx.IdemKey{Key: callerKey(caller, "key-2"), Fingerprint: fp,
+		ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(time.Minute)}
+	w = call("key-2", "doc")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 1, runs)
+
+	// a request whose lease lapsed, as its instance went down, is taken over by a retry
+	store.keys[callerKey(caller, "key-2")] = dbtx.IdemKey{Key: callerKey(caller, "key-2"), Fingerprint: fp,
+		ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(-time.Second)}
+	w = call("key-2", "doc")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, runs)
+
+	// an expired key is used again
+	k := store.keys[callerKey(caller, "key-1")]
+	k.ExpiresAt = time.Now().Add(-time.Second)
+	store.keys[callerKey(caller, "key-1")] = k
+	w = call("key-1", "doc")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHdr))
+	assert.Equal(t, 3, runs)
+
+	// server errors release the key for a retry
+	status = http.StatusInternalServerError
+	w = call("key-3", "doc")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, store.keys, callerKey(caller, "key-3"))
+	status = http.StatusCreated
+	w = call("key-3", "doc")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 5, runs)
+
+	// requests without a key are always run
+	call("", "doc")
+	call("", "doc")
+	assert.Equal(t, 7, runs)
+
+	// keys are scoped to the caller, the same key sent by another caller is another request
+	caller = "JOHN.DOE@example.com"
+	w = call("key-1", "doc")
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayedHdr))
+	caller = "jane.doe@example.com"
+	w = call("key-1", "doc")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHdr))
+	assert.Equal(t, 8, runs)
+}
+
+func TestDocH_renewIdempotencyLease(t *testing.T) {
+	store := &idemStore{keys: make(map[string]dbtx.IdemKey)}
+	d := &DocH{Db: store, IdempotencyLease: 30 * time.Millisecond}
+
+	stop := d.renewIdempotencyLease(context.Background(), "key-1")
+	time.Sleep(45 * time.Millisecond)
+	stop()
+	renewed := store.extended.Load()
+	assert.GreaterOrEqual(t, renewed, int32(2))
+
+	// the lease is no longer renewed once the request is done
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, renewed, store.extended.Load())
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// IntegrityAlert raises alerts for documents failing an integrity check, with a doc.integrity.failed
+// event which is logged and posted to a webhook when one is set
+type IntegrityAlert struct {
+	// WebhookUrl receives the events as json when it is set
+	WebhookUrl string
+	Client     *http.Client
+}
+
+// integrityErr is returned when the content of a document does not hash to the document hash
+type integrityErr struct {
+	docHash     string
+	contentHash string
+}
+
+func (e *integrityErr) Error() string {
+	return fmt.Sprintf("doc content hashes to %s instead of %s", e.contentHash, e.docHash)
+}
+
+// raiseIntegrityAlert reports a document which failed an integrity check, it is never served as authentic
+func (d *DocH) raiseIntegrityAlert(ctx context.Context, ev rest.DocIntegrityEvent) {
+	logger := log.GetLogger(ctx)
+	ev.Type, ev.DetectedAt = rest.DocIntegrityEventType, time.Now().UTC()
+	logger.Error("doc failed integrity check", zap.String("event", ev.Type), zap.String("check", ev.Check),
+		zap.String("docId", ev.DocId), zap.String("docHash", ev.DocHash), zap.String("contentHash", ev.ContentHash))
+	if d.Integrity == nil || d.Integrity.WebhookUrl == "" {
+		return
+	}
+	if err := postEvent(ctx, d.Integrity.Client, d.Integrity.WebhookUrl, ev); err != nil {
+		logger.Error("unable to raise integrity alert", zap.String("docId", ev.DocId), zap.Error(err))
+	}
+}
+
+// verifyAnchor checks that the hash of a document is the one anchored in blockchain with its tkn,
+// responding 409 when it is not and 503 when blockchain can not be reached
+func (d *DocH) verifyAnchor(c *gin.Context, meta dbtx.DocMeta) bool {
+	if meta.BcTknId == "" {
+		problem(c, http.StatusConflict, "doc is not anchored in blockchain")
+		return false
+	}
+	ownerEmailMd5Hash, err := d.H.Hash(c, strings.NewReader(meta.OwnerEmail))
+	if err != nil {
+		problem(c, http.StatusInternalServerError, "unable to generate hash - "+err.Error())
+		return false
+	}
+	_, err = d.Bc.VerifyDocTkn(c, meta.BcTknId, meta.DocMd5Hash, ownerEmailMd5Hash)
+	if errors.Is(err, bc.ErrTknMismatch) {
+		go d.raiseIntegrityAlert(detach(c), rest.DocIntegrityEvent{Check: rest.AnchorCheck, DocId: meta.DocId,
+			DocHash: meta.DocMd5Hash, BcTknId: meta.BcTknId})
+		problem(c, http.StatusConflict, "doc does not match its blockchain anchor")
+		return false
+	}
+	if err != nil {
+		problem(c, http.StatusServiceUnavailable, "unable to verify in blockchain - "+err.Error())
+		return false
+	}
+	return true
+}
+
+// contentHasher is the hasher a document was hashed with on upload, nil when its algorithm is unknown
+func contentHasher(meta dbtx.DocMeta) hash.Hasher {
+	switch meta.HashAlgo {
+	case hash.AlgoMd5, "":
+		return hash.Md5{}
+	case hash.AlgoMerkleSha256:
+		return hash.Merkle{ChunkSize: meta.ChunkSize}
+	default:
+		return nil
+	}
+}
+
+// acceptsTrailers tells if a client takes trailer fields after the body, which http/2 clients always do
+// and http/1.1 clients do when they send TE: trailers
+func acceptsTrailers(r *http.Request) bool {
+	if r.ProtoMajor >= 2 {
+		return true
+	}
+	if !r.ProtoAtLeast(1, 1) {
+		return false
+	}
+	for _, te := range strings.Split(r.Header.Get("TE"), ",") {
+		if t, _, _ := strings.Cut(te, ";"); strings.EqualFold(strings.TrimSpace(t), "trailers") {
+			return true
+		}
+	}
+	return false
+}
+
+// sha256Digest is the RFC 9530 sha-256 digest of the content read from r
+func sha256Digest(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
+
+// verifiedCopy sends a whole document to w and hashes it as it is sent, with h and with sha-256. The last byte
+// is only sent once the content hashes to docHash, so that a corrupt document never reaches a client complete.
+// It returns the RFC 9530 sha-256 digest of the content sent.
+func verifiedCopy(ctx context.Context, w io.Writer, doc io.Reader, h hash.Hasher, docHash string) (string, error) {
+	var contentHash, digest string
+	f := newFanOut(func(r io.Reader) error {
+		var err error
+		contentHash, err = h.Hash(ctx, r)
+		return err
+	}, func(r io.Reader) error {
+		var err error
+		digest, err = sha256Digest(r)
+		return err
+	})
+	hw := &holdBackWriter{w: w}
+	_, err := io.Copy(io.MultiWriter(hw, f), doc)
+	errs := f.finish(err)
+	if err != nil {
+		return "", err
+	}
+	if err = errors.Join(errs...); err != nil {
+		return "", fmt.Errorf("unable to hash doc - %w", err)
+	}
+	if contentHash != docHash {
+		return "", &integrityErr{docHash: docHash, contentHash: contentHash}
+	}
+	return digest, hw.flush()
+}
+
+// holdBackWriter writes all but the last byte written to it, until it is flushed
+type holdBackWriter struct {
+	w    io.Writer
+	last []byte
+}
+
+func (h *holdBackWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if err := h.flush(); err != nil {
+		return 0, err
+	}
+	if _, err := h.w.Write(b[:len(b)-1]); err != nil {
+		return 0, err
+	}
+	h.last = []byte{b[len(b)-1]}
+	return len(b), nil
+}
+
+func (h *holdBackWriter) flush() error {
+	if len(h.last) == 0 {
+		return nil
+	}
+	_, err := h.w.Write(h.last)
+	h.last = nil
+	return err
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/httpsrvr/mwares/reqlogger"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// statuses of an upload job
+const (
+	jobInProgress = "IN_PROGRESS"
+	jobDone       = "DONE"
+	jobFailed     = "FAILED"
+)
+
+// steps of an upload job, in order
+var jobSteps = []string{"HASHING", "STORAGE", "MINTING", "CONFIRMATION", "SAVING"}
+
+// jobStep is the index in jobSteps of the step an upload job is at, for each upload saga state
+var jobStep = map[string]int{
+	dbtx.SagaReceived:  1,
+	dbtx.SagaStored:    2,
+	dbtx.SagaMinted:    3,
+	dbtx.SagaConfirmed: 4,
+	dbtx.SagaSaved:     len(jobSteps),
+}
+
+// Job reports the progress of an upload job, along with its doc once it is done
+func (d *DocH) Job(c *gin.Context) {
+	logger := log.GetLogger(c)
+
+	var req rest.JobReq
+	if err := c.BindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.JobResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	logger.Info("upload job request received", zap.String("jobId", req.JobId))
+
+	s, err := d.Db.GetUploadSaga(c, req.JobId)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, rest.JobResp{JobId: req.JobId, Error: "upload job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.JobResp{JobId: req.JobId, Error: "unable to find upload job - " +
+			err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, jobResp(s))
+}
+
+// jobResp reports an upload saga as an upload job
+func jobResp(s dbtx.UploadSaga) rest.JobResp {
+	resp := rest.JobResp{JobId: s.SagaId, Status: jobInProgress}
+	step, ok := jobStep[s.State]
+	switch {
+	case !ok:
+		resp.Status, resp.Error = jobFailed, s.LastError
+		if resp.Error == "" {
+			resp.Error = "upload was rolled back"
+		}
+		return resp
+	case s.State == dbtx.SagaSaved:
+		resp.Status, resp.Doc = jobDone, &s.Doc
+	default:
+		resp.Step = jobSteps[step]
+	}
+	resp.CompletedSteps = jobSteps[:step]
+	return resp
+}
+
+// jobLocation is the url of an upload job, next to the upload url
+func jobLocation(c *gin.Context, jobId string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, "/"), "/upload") + "/jobs/" + jobId
+}
+
+// detach returns a context carrying the request logger for work outliving the request
+func detach(c *gin.Context) context.Context {
+	return context.WithoutCancel(reqCtx(c))
+}
+
+// reqCtx returns the request context carrying the request logger, which unlike the gin context
+// can be used from other goroutines
+func reqCtx(c *gin.Context) context.Context {
+	return context.WithValue(c.Request.Context(), reqlogger.CorrelationLoggerKeyStr, log.GetLogger(c))
+}
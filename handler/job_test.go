@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+func Test_jobResp(t *testing.T) {
+	tests := []struct {
+		name string
+		saga dbtx.UploadSaga
+		want rest.JobResp
+	}{
+		{
+			name: "minting",
+			saga: dbtx.UploadSaga{SagaId: "j1", State: dbtx.SagaStored},
+			want: rest.JobResp{JobId: "j1", Status: jobInProgress, Step: "MINTING",
+				CompletedSteps: []string{"HASHING", "STORAGE"}},
+		},
+		{
+			name: "confirming",
+			saga: dbtx.UploadSaga{SagaId: "j1", State: dbtx.SagaMinted},
+			want: rest.JobResp{JobId: "j1", Status: jobInProgress, Step: "CONFIRMATION",
+				CompletedSteps: []string{"HASHING", "STORAGE", "MINTING"}},
+		},
+		{
+			name: "done",
+			saga: dbtx.UploadSaga{SagaId: "j1", State: dbtx.SagaSaved, Doc: dbtx.DocMeta{DocId: "d1"}},
+			want: rest.JobResp{JobId: "j1", Status: jobDone, Doc: &dbtx.DocMeta{DocId: "d1"},
+				CompletedSteps: jobSteps},
+		},
+		{
+			name: "rolled back",
+			saga: dbtx.UploadSaga{SagaId: "j1", State: dbtx.SagaRolledBack, LastError: "unable to sign"},
+			want: rest.JobResp{JobId: "j1", Status: jobFailed, Error: "unable to sign"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, jobResp(tt.saga))
+		})
+	}
+}
+
+func TestDocH_ingestAsync(t *testing.T) {
+	store, mb := newSagaStore(), memBlob{"doc-0": []byte("content")}
+	d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}}
+	req := &rest.UploadReq{OwnerEmail: "john.doe@example.com", DocMd5Hash: "hash-1"}
+	src := storedSource(context.Background(), mb, "doc-0", "doc.txt", "text/plain; charset=utf-8", 7)
+
+	res, err := d.ingest(context.Background(), req, src, true)
+	require.NoError(t, err)
+	require.NotNil(t, res.finish)
+	assert.Equal(t, "doc-0", res.doc.DocId)
+	assert.Empty(t, res.doc.BcTknId)
+	assert.Equal(t, dbtx.SagaStored, store.sagas[res.jobId].State)
+
+	res.finish(context.Background())
+	assert.Equal(t, dbtx.SagaSaved, store.sagas[res.jobId].State)
+	assert.Equal(t, "tkn-doc-0", store.docs["hash-1"].BcTknId)
+}
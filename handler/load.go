@@ -2,12 +2,19 @@ package handler
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
 	"sync"
 
+	"github.com/vposham/trustdoc/config"
 	"github.com/vposham/trustdoc/internal/bc"
 	"github.com/vposham/trustdoc/internal/blob"
 	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
 	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/internal/policy"
+	"github.com/vposham/trustdoc/internal/scan"
+	"github.com/vposham/trustdoc/pkg/receipt"
 )
 
 var (
@@ -48,12 +55,125 @@ func loadImpls(ctx context.Context) error {
 			return err
 		}
 
-		concreteImpls[docHandlerImplKey] = &DocH{
-			Db:   dbtx.GetDbStore(),
-			Blob: blob.GetBlobStore(),
-			H:    hash.Md5{},
-			Bc:   bc.GetBc(),
+		// load upload policy
+		if err := policy.Load(ctx); err != nil {
+			return err
+		}
+
+		props := config.GetAll()
+		docH := &DocH{
+			Db:             dbtx.GetDbStore(),
+			Blob:           blob.GetBlobStore(),
+			H:              hash.Md5{},
+			Bc:             bc.GetBc(),
+			Policy:         policy.GetPolicy(),
+			TusMaxSize:     props.MustGetInt64("tus.max.size"),
+			FinishTimeout:  props.MustGetParsedDuration("upload.finish.timeout.dur"),
+			IdempotencyTTL: props.MustGetParsedDuration("idempotency.ttl.dur"),
+			Bulk: BulkLimits{
+				MaxSize:     props.MustGetInt64("bulk.max.archive.size"),
+				MaxEntries:  props.MustGetInt("bulk.max.entries"),
+				Concurrency: props.MustGetInt("bulk.concurrency"),
+			},
+			Batch: BatchLimits{
+				MaxItems:    props.MustGetInt("verify.batch.max.items"),
+				Concurrency: props.MustGetInt("verify.batch.concurrency"),
+			},
+			PresignGetTTL: props.MustGetParsedDuration("presign.download.ttl.dur"),
+			PresignPutTTL: props.MustGetParsedDuration("presign.upload.ttl.dur"),
+		}
+		switch policy := DuplicatePolicy(props.GetString("upload.duplicate.policy", string(DuplicateConflict))); policy {
+		case DuplicateConflict, DuplicateConceal:
+			docH.Duplicates = policy
+		case DuplicateClaim:
+			docH.Duplicates = policy
+			docH.Claims = &ClaimNotice{
+				WebhookUrl: props.GetString("upload.duplicate.claim.webhook.url", ""),
+				Client:     &http.Client{Timeout: props.MustGetParsedDuration("upload.duplicate.claim.webhook.timeout.dur")},
+			}
+			if docH.Claims.WebhookUrl == "" {
+				return fmt.Errorf("upload.duplicate.claim.webhook.url is required by upload.duplicate.policy %q", policy)
+			}
+		default:
+			return fmt.Errorf("unsupported upload.duplicate.policy %q", policy)
+		}
+		docH.PageBaseUrl = props.GetString("doc.verify.page.base.url", "")
+		docH.IdempotencyLease = props.MustGetParsedDuration("idempotency.lease.dur")
+
+		switch algo := props.GetString("doc.hash.algo", hash.AlgoMd5); algo {
+		case hash.AlgoMd5:
+		case hash.AlgoMerkleSha256:
+			docH.Tree = hash.Merkle{
+				ChunkSize: props.MustGetInt64("doc.hash.merkle.chunk.size"),
+				Workers:   props.MustGetInt("doc.hash.merkle.workers"),
+			}
+		default:
+			return fmt.Errorf("unsupported doc.hash.algo %q", algo)
 		}
+
+		if props.MustGetBool("doc.phash.enabled") {
+			docH.Similar = &Similarity{
+				H:           hash.DHash{MaxPixels: props.MustGetInt64("doc.phash.max.image.pixels")},
+				MaxDistance: props.MustGetInt("doc.phash.max.distance"),
+				MaxResults:  props.MustGetInt("doc.phash.max.results"),
+				MaxSize:     props.MustGetInt64("doc.phash.max.image.size"),
+			}
+			if d := docH.Similar.MaxDistance; d < 0 || d > 15 {
+				return fmt.Errorf("unsupported doc.phash.max.distance %d", d)
+			}
+		}
+
+		if props.MustGetBool("upload.recovery.enabled") {
+			docH.Recovery = &UploadRecovery{
+				Interval:    props.MustGetParsedDuration("upload.recovery.interval.dur"),
+				StaleAfter:  props.MustGetParsedDuration("upload.recovery.stale.dur"),
+				BatchSize:   props.MustGetInt("upload.recovery.batch.size"),
+				MaxAttempts: props.MustGetInt("upload.recovery.max.attempts"),
+			}
+		}
+
+		if props.MustGetBool("presign.upload.cleanup.enabled") {
+			docH.DirectCleanup = &DirectCleanup{
+				Interval:  props.MustGetParsedDuration("presign.upload.cleanup.interval.dur"),
+				BatchSize: props.MustGetInt("presign.upload.cleanup.batch.size"),
+			}
+		}
+
+		if props.MustGetBool("doc.expiry.notice.enabled") {
+			docH.Expiry = &ExpiryNotice{
+				Interval:   props.MustGetParsedDuration("doc.expiry.notice.interval.dur"),
+				Within:     props.MustGetParsedDuration("doc.expiry.notice.within.dur"),
+				BatchSize:  props.MustGetInt("doc.expiry.notice.batch.size"),
+				WebhookUrl: props.GetString("doc.expiry.notice.webhook.url", ""),
+				Client:     &http.Client{Timeout: props.MustGetParsedDuration("doc.expiry.notice.webhook.timeout.dur")},
+			}
+		}
+
+		docH.Integrity = &IntegrityAlert{
+			WebhookUrl: props.GetString("doc.integrity.alert.webhook.url", ""),
+			Client:     &http.Client{Timeout: props.MustGetParsedDuration("doc.integrity.alert.webhook.timeout.dur")},
+		}
+
+		if key := props.GetString("doc.receipt.signing.key", ""); key != "" {
+			seed, err := hex.DecodeString(key)
+			if err != nil {
+				return fmt.Errorf("failed to parse receipt signing key: %w", err)
+			}
+			signer, err := receipt.NewSigner(seed)
+			if err != nil {
+				return err
+			}
+			docH.Receipts = &Receipts{Issuer: props.MustGetString("doc.receipt.issuer"), Signer: signer}
+		}
+
+		if props.GetBool("scan.enabled", false) {
+			if err := scan.Load(ctx); err != nil {
+				return err
+			}
+			docH.Scanner = scan.GetScanner()
+		}
+
+		concreteImpls[docHandlerImplKey] = docH
 	}
 	return nil
 }
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/vposham/trustdoc/internal/blob"
+)
+
+// mimeSniffLen is how much of the content is read to detect its type, it is the mimetype default
+const mimeSniffLen = 3072
+
+// sniffMime detects the content type from the start of the content. The returned reader
+// still yields the whole content.
+func sniffMime(in io.Reader) (string, io.Reader, error) {
+	head := make([]byte, mimeSniffLen)
+	n, err := io.ReadFull(in, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", nil, fmt.Errorf("unable to detect content type - %w", err)
+	}
+	head = head[:n]
+	return mimetype.Detect(head).String(), io.MultiReader(bytes.NewReader(head), in), nil
+}
+
+// contentDisposition is the RFC 6266 attachment disposition for a file name. The filename
+// parameter is an ASCII fallback for clients which do not support filename*.
+func contentDisposition(name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	var encoded strings.Builder
+	for _, b := range []byte(name) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+			continue
+		}
+		_, _ = fmt.Fprintf(&encoded, "%%%02X", b)
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, encoded.String())
+}
+
+// isAttrChar reports whether b can appear unencoded in an RFC 5987 ext-value
+func isAttrChar(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// objMeta is the blob store metadata of a document, so it is served with the right headers
+func objMeta(src docSource) blob.ObjMeta {
+	return blob.ObjMeta{ContentType: src.mimeType, ContentDisposition: contentDisposition(src.name)}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sniffMime(t *testing.T) {
+	pdf := "%PDF-1.7\n" + strings.Repeat("x", 2*mimeSniffLen)
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "pdf", content: pdf, want: "application/pdf"},
+		{name: "png", content: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", want: "image/png"},
+		{name: "text", content: "hello", want: "text/plain; charset=utf-8"},
+		{name: "empty", content: "", want: "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, r, err := sniffMime(bytes.NewReader([]byte(tt.content)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			all, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, string(all), "content is not consumed")
+		})
+	}
+}
+
+func Test_contentDisposition(t *testing.T) {
+	assert.Equal(t, `attachment; filename="lease.pdf"; filename*=UTF-8''lease.pdf`, contentDisposition("lease.pdf"))
+	assert.Equal(t, `attachment; filename="my _lease_.pdf"; filename*=UTF-8''my%20%22lease%22.pdf`,
+		contentDisposition(`my "lease".pdf`))
+	assert.Equal(t, `attachment; filename="_t_.pdf"; filename*=UTF-8''%C3%A9t%C3%A9.pdf`, contentDisposition("été.pdf"))
+}
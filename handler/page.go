@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/qr"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// verifyHashPath is where the verification page sends the hash of the document it verifies
+const verifyHashPath = "/svc/v1/doc/verify/hash"
+
+//go:embed templates/verify.html
+var verifyPageHtml string
+
+var verifyPageTmpl = template.Must(template.New("verify").Parse(verifyPageHtml))
+
+// verifyPage is what the verification page of a document shows, Error is set when there is no doc to show
+type verifyPage struct {
+	Nonce     string
+	Error     string
+	DocId     string
+	Title     string
+	Name      string
+	Owner     string
+	Token     string
+	Gone      string
+	HashAlgo  string
+	ChunkSize int64
+	VerifyUrl string
+	QrUrl     string
+}
+
+// VerifyPage renders the public page on which anyone holding a copy of a document verifies it.
+// The copy is hashed in the browser, only its hash is sent to VerifyHash.
+func (d *DocH) VerifyPage(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("verification page request received")
+
+	var req rest.DownloadReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		renderPage(c, http.StatusNotFound, verifyPage{Error: "Document not found"})
+		return
+	}
+	meta, err := d.Db.GetDocMeta(c, req.DocId)
+	if errors.Is(err, sql.ErrNoRows) {
+		renderPage(c, http.StatusNotFound, verifyPage{Error: "Document not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("unable to find doc meta", zap.String("docId", req.DocId), zap.Error(err))
+		renderPage(c, http.StatusInternalServerError, verifyPage{Error: "Document can not be shown"})
+		return
+	}
+
+	title := meta.DocTitle
+	if title == "" {
+		title = meta.DocName
+	}
+	gone := docGone(meta)
+	if gone != "" {
+		// the reason reads as the end of a sentence on the page
+		gone = strings.TrimPrefix(gone, "doc ")
+	}
+	renderPage(c, http.StatusOK, verifyPage{
+		DocId:     meta.DocId,
+		Title:     title,
+		Name:      meta.DocName,
+		Owner:     maskEmail(meta.OwnerEmail),
+		Token:     meta.BcTknId,
+		Gone:      gone,
+		HashAlgo:  docHashAlgo(meta),
+		ChunkSize: meta.ChunkSize,
+		VerifyUrl: verifyHashPath,
+		QrUrl:     "/v/" + meta.DocId + "/qr?format=svg",
+	})
+}
+
+// VerifyPageQr returns a QR code linking to the verification page of a document, as a png or an svg
+func (d *DocH) VerifyPageQr(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("verification page qr code request received")
+
+	var uri rest.DownloadReq
+	if err := c.ShouldBindUri(&uri); err != nil {
+		problem(c, http.StatusNotFound, "doc not found")
+		return
+	}
+	var req rest.QrReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		problem(c, http.StatusBadRequest, "req validation failed - "+err.Error())
+		return
+	}
+	meta, err := d.Db.GetDocMeta(c, uri.DocId)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, "doc not found")
+		return
+	}
+	if err != nil {
+		problem(c, http.StatusInternalServerError, "unable to find file meta - "+err.Error())
+		return
+	}
+
+	code, err := qr.Encode(d.pageUrl(c, meta.DocId))
+	if err != nil {
+		problem(c, http.StatusInternalServerError, "unable to encode qr code - "+err.Error())
+		return
+	}
+	// the page of a doc does not move, so its code is cached for long. a code linking to the host of the
+	// request is only cached by the client, so a forged Host can not poison shared caches
+	cache := "public, max-age=86400"
+	if d.PageBaseUrl == "" {
+		cache = "private, max-age=86400"
+	}
+	c.Header("Cache-Control", cache)
+	if req.Format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", code.SVG())
+		return
+	}
+	scale := req.Scale
+	if scale == 0 {
+		scale = 8
+	}
+	b, err := code.PNG(scale)
+	if err != nil {
+		problem(c, http.StatusInternalServerError, "unable to render qr code - "+err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "image/png", b)
+}
+
+// pageUrl is the public url of the verification page of a document, on the host of the request
+// unless the service is reached at another url
+func (d *DocH) pageUrl(c *gin.Context, docId string) string {
+	base := d.PageBaseUrl
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return strings.TrimSuffix(base, "/") + "/v/" + docId
+}
+
+// renderPage renders the verification page, whose inline script and style are the only ones it runs
+func renderPage(c *gin.Context, status int, page verifyPage) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		c.String(http.StatusInternalServerError, "unable to render page")
+		return
+	}
+	page.Nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	var buf bytes.Buffer
+	if err := verifyPageTmpl.Execute(&buf, page); err != nil {
+		log.GetLogger(c).Error("unable to render verification page", zap.Error(err))
+		c.String(http.StatusInternalServerError, "unable to render page")
+		return
+	}
+	c.Header("Content-Security-Policy", fmt.Sprintf("default-src 'none'; script-src 'nonce-%[1]s'; "+
+		"style-src 'nonce-%[1]s'; img-src 'self'; connect-src 'self'; base-uri 'none'; form-action 'none'; "+
+		"frame-ancestors 'none'", page.Nonce))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "no-cache")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// maskEmail hides the owner of a document on public pages, keeping enough of it to be recognised
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "hidden"
+	}
+	return string([]rune(local)[:1]) + "***@" + domain
+}
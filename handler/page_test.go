@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+)
+
+func TestDocH_VerifyPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	revokedId := "1c8f4f5d-4b50-4c5f-8e66-5b4d204060fb"
+	revokedAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	d := &DocH{Db: &claimStore{docs: map[string]dbtx.DocMeta{
+		docId: {DocId: docId, DocName: "deed.pdf", DocTitle: "Deed <of> sale", OwnerEmail: "john.doe@example.com",
+			BcTknId: "0xabc", HashAlgo: "merkle-sha256", ChunkSize: 1 << 20},
+		revokedId: {DocId: revokedId, DocName: "old.pdf", OwnerEmail: "jane@example.com", RevokedAt: &revokedAt},
+	}}}
+	page := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v/"+id, nil)
+		c.Params = gin.Params{{Key: "docId", Value: id}}
+		d.VerifyPage(c)
+		return w
+	}
+
+	w := page(docId)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "<h1>Deed &lt;of&gt; sale</h1>")
+	assert.Contains(t, body, "j***@example.com")
+	assert.NotContains(t, body, "john.doe")
+	assert.Contains(t, body, `algorithm: "merkle-sha256"`)
+	assert.Contains(t, body, `chunkSize:  1048576 `)
+	assert.Contains(t, body, `tokenId: "0xabc"`)
+	assert.Contains(t, body, `src="/v/`+docId+`/qr?format=svg"`)
+	csp := w.Header().Get("Content-Security-Policy")
+	nonce := strings.TrimPrefix(strings.Split(csp, ";")[1], " script-src 'nonce-")
+	nonce = strings.TrimSuffix(nonce, "'")
+	require.NotEmpty(t, nonce)
+	assert.Contains(t, body, `<script nonce="`+nonce+`">`)
+	assert.NotEqual(t, csp, page(docId).Header().Get("Content-Security-Policy"))
+
+	w = page(revokedId)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "it was revoked at 2024-05-01T10:30:00Z.")
+	assert.Contains(t, w.Body.String(), `algorithm: "md5"`)
+
+	for _, id := range []string{"2d905f6e-5c61-4d60-9f77-6c5e31517100", "not-a-doc"} {
+		w = page(id)
+		assert.Equal(t, http.StatusNotFound, w.Code, id)
+		assert.Contains(t, w.Body.String(), "<h1>Document not found</h1>", id)
+	}
+}
+
+func TestDocH_VerifyPageQr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	d := &DocH{Db: &claimStore{docs: map[string]dbtx.DocMeta{docId: {DocId: docId}}}}
+	qrCode := func(id, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v/"+id+"/qr"+query, nil)
+		c.Params = gin.Params{{Key: "docId", Value: id}}
+		d.VerifyPageQr(c)
+		return w
+	}
+
+	w := qrCode(docId, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "private, max-age=86400", w.Header().Get("Cache-Control"))
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	small, err := png.Decode(bytes.NewReader(qrCode(docId, "?format=png&scale=2").Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, img.Bounds().Dx(), 4*small.Bounds().Dx())
+
+	w = qrCode(docId, "?format=svg")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "<svg"))
+
+	assert.Equal(t, http.StatusBadRequest, qrCode(docId, "?format=gif").Code)
+	assert.Equal(t, http.StatusBadRequest, qrCode(docId, "?scale=100").Code)
+	assert.Equal(t, http.StatusNotFound, qrCode("2d905f6e-5c61-4d60-9f77-6c5e31517100", "").Code)
+
+	d.PageBaseUrl = "https://trustdoc.example.com"
+	assert.Equal(t, "public, max-age=86400", qrCode(docId, "").Header().Get("Cache-Control"))
+}
+
+func TestDocH_pageUrl(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v/doc-1/qr", nil)
+	c.Request.Host = "docs.example.com:8080"
+	assert.Equal(t, "http://docs.example.com:8080/v/doc-1", (&DocH{}).pageUrl(c, "doc-1"))
+	d := &DocH{PageBaseUrl: "https://trustdoc.example.com/"}
+	assert.Equal(t, "https://trustdoc.example.com/v/doc-1", d.pageUrl(c, "doc-1"))
+}
+
+func Test_maskEmail(t *testing.T) {
+	assert.Equal(t, "j***@example.com", maskEmail("john.doe@example.com"))
+	assert.Equal(t, "é***@example.com", maskEmail("éva@example.com"))
+	assert.Equal(t, "hidden", maskEmail("john.doe"))
+	assert.Equal(t, "hidden", maskEmail("@example.com"))
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/internal/policy"
+	"github.com/vposham/trustdoc/internal/scan"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// docSource is the content of a received document. It can be opened more than once,
+// once for hashing it and once for storing it in blob store.
+type docSource struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+
+	// stored is the blob store docId when the content was streamed to blob store on receipt
+	stored string
+	// mimeType is sniffed from the content when it is stored in blob store
+	mimeType string
+	// scanned is the malware scan result, once the content is scanned
+	scanned *scan.Result
+	// tree holds the chunk hashes of content hashed as a merkle tree, once it is hashed
+	tree *hash.Tree
+}
+
+// fileSource is the docSource of a document received as a multipart form file
+func fileSource(fh *multipart.FileHeader) docSource {
+	return docSource{
+		name: fh.Filename,
+		size: fh.Size,
+		open: func() (io.ReadCloser, error) {
+			return fh.Open()
+		},
+	}
+}
+
+// storedSource is the docSource of a document already streamed to blob store
+func storedSource(ctx context.Context, b blob.OpsIf, docId, name, mimeType string, size int64) docSource {
+	return docSource{
+		name:     name,
+		size:     size,
+		stored:   docId,
+		mimeType: mimeType,
+		open: func() (io.ReadCloser, error) {
+			r, err := b.Get(ctx, docId, nil)
+			if err != nil {
+				return nil, err
+			}
+			if rc, ok := r.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return io.NopCloser(r), nil
+		},
+	}
+}
+
+// stagedSource is the docSource of a document staged in blob store under objName, to be stored once it is
+// found fit for the doc store
+func stagedSource(ctx context.Context, b blob.OpsIf, objName, name string, size int64) docSource {
+	src := storedSource(ctx, b, objName, name, "", size)
+	src.stored = ""
+	return src
+}
+
+// storeStaged stores a document staged in blob store as a doc of its own, without it leaving blob store.
+// The staged objects are concatenated in order, and are left for the caller to remove.
+func (d *DocH) storeStaged(ctx context.Context, src *docSource, objNames []string) error {
+	docId, err := d.Blob.Compose(ctx, objNames)
+	if err != nil {
+		return &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to store in blob store - %w", err)}
+	}
+	stored := storedSource(ctx, d.Blob, docId, src.name, src.mimeType, src.size)
+	src.stored, src.open = stored.stored, stored.open
+	return nil
+}
+
+// countingReader counts the bytes read through it and keeps the read error, other than EOF
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.err = err
+	}
+	return n, err
+}
+
+// fanOut feeds the content written to it to consumers, each reading it in its own goroutine
+type fanOut struct {
+	pws  []*io.PipeWriter
+	done []chan error
+	// err is set when a consumer stopped reading before the end of the content
+	err error
+}
+
+func newFanOut(consumers ...func(io.Reader) error) *fanOut {
+	f := &fanOut{}
+	for _, consume := range consumers {
+		pr, pw := io.Pipe()
+		done := make(chan error, 1)
+		go func(consume func(io.Reader) error) {
+			err := consume(pr)
+			// unblock the writer when a consumer gives up early
+			_ = pr.CloseWithError(err)
+			done <- err
+		}(consume)
+		f.pws, f.done = append(f.pws, pw), append(f.done, done)
+	}
+	return f
+}
+
+func (f *fanOut) Write(b []byte) (int, error) {
+	for _, pw := range f.pws {
+		if _, err := pw.Write(b); err != nil {
+			f.err = err
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// finish ends the content, with err when it is incomplete, and returns the error of each consumer
+func (f *fanOut) finish(err error) []error {
+	errs := make([]error, len(f.pws))
+	for i, pw := range f.pws {
+		_ = pw.CloseWithError(err)
+		errs[i] = <-f.done[i]
+	}
+	return errs
+}
+
+// stepErr is an upload pipeline step failure along with the http status it maps to
+type stepErr struct {
+	status int
+	err    error
+}
+
+func (e *stepErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepErr) Unwrap() error {
+	return e.err
+}
+
+// errStatus returns the http status for an upload pipeline error
+func errStatus(err error) int {
+	var se *stepErr
+	if errors.As(err, &se) {
+		return se.status
+	}
+	var v *policy.Violation
+	if errors.As(err, &v) {
+		return v.Status()
+	}
+	return http.StatusInternalServerError
+}
+
+// ingestResult is the outcome of running a document through the upload pipeline
+type ingestResult struct {
+	doc     dbtx.DocMeta
+	similar []dbtx.SimilarDoc
+	// exists is true when a document with the same content was uploaded before
+	exists bool
+
+	// jobId is set for asynchronous uploads, which are finished by running finish
+	jobId  string
+	finish func(ctx context.Context)
+}
+
+// hashReq hashes the document content and the owner email of an upload request
+func (d *DocH) hashReq(ctx context.Context, req *rest.UploadReq, src *docSource) error {
+	f, err := src.open()
+	if err != nil {
+		return fmt.Errorf("unable to open file - %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	// generate hash for the doc and md5 hash for the owner email id
+	tree, e1 := d.hashDoc(ctx, f, req)
+	ownerEmailIdMd5Hash, e2 := d.H.Hash(ctx, strings.NewReader(req.OwnerEmail))
+	if e1 != nil || e2 != nil {
+		return fmt.Errorf("unable to generate hash. docHashErr - %w. emailHashErr - %w", e1, e2)
+	}
+	req.OwnerEmailMd5Hash, src.tree = ownerEmailIdMd5Hash, tree
+	return nil
+}
+
+// ingest runs a hashed document through the rest of the upload pipeline. Documents which
+// already exist are returned to their owners only, new ones are stored in blob store, minted in blockchain
+// and their metadata is persisted in db. Asynchronous uploads of documents streamed to blob store
+// on receipt return once their upload saga is started, minting and saving them is left to finish.
+func (d *DocH) ingest(ctx context.Context, req *rest.UploadReq, src docSource, async bool) (*ingestResult, error) {
+	logger := log.GetLogger(ctx)
+
+	// content streamed to blob store on receipt is removed when the doc is not kept, until an upload saga
+	// takes it over
+	discard := func(err error) (*ingestResult, error) {
+		if src.stored != "" {
+			d.deleteBlob(ctx, src.stored)
+		}
+		return nil, err
+	}
+
+	var exists bool
+	doc, err := d.Db.GetDocMetaByHash(ctx, req.DocMd5Hash)
+	if err == nil {
+		exists = true
+	} else {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return discard(&stepErr{http.StatusInternalServerError, fmt.Errorf("unable to find doc in db - %w", err)})
+		}
+		exists = false
+	}
+	logger.Info("doc exists check", zap.String("docId", doc.DocId), zap.Bool("docExists", exists))
+
+	if exists {
+		// content streamed to blob store on receipt is not needed for a known doc
+		if src.stored != "" {
+			d.deleteBlob(ctx, src.stored)
+		}
+		return d.duplicate(ctx, req, doc)
+	}
+
+	// documents which are against the upload policy are not kept
+	err = d.sniffSource(&src)
+	if err == nil {
+		err = d.checkPolicy(ctx, req.OwnerEmail, src)
+	}
+	if err != nil {
+		return discard(err)
+	}
+
+	// documents carrying malware are never put in blob store, or are removed when streamed there on receipt
+	if err = d.scanSource(ctx, &src); err != nil {
+		return discard(err)
+	}
+
+	// flag visually similar documents, this never fails the upload
+	pHash, similar := d.findSimilar(ctx, src, req.DocMd5Hash)
+
+	doc = dbtx.DocMeta{
+		OwnerEmail:     req.OwnerEmail,
+		DocTitle:       req.DocTitle,
+		DocDesc:        req.DocDesc,
+		DocMd5Hash:     req.DocMd5Hash,
+		DocName:        src.name,
+		OwnerFirstName: req.OwnerFirstName,
+		OwnerLastName:  req.OwnerLastName,
+		HashAlgo:       req.DocHashAlgo,
+		PerceptualHash: pHash,
+		MimeType:       src.mimeType,
+		DocSize:        src.size,
+		Tags:           req.DocTags,
+		ValidFrom:      req.DocValidFrom,
+		ValidUntil:     req.DocValidUntil,
+	}
+	if src.scanned != nil {
+		doc.ScanStatus, doc.ScanEngine = src.scanned.Status(), src.scanned.Engine
+	}
+	if src.tree != nil {
+		doc.ChunkSize = src.tree.ChunkSize
+		doc.ChunkHashes = src.tree.Leaves
+	}
+
+	if async && src.stored != "" {
+		sagaId, doc, err := d.startSaga(ctx, doc, &src)
+		if err != nil {
+			return nil, err
+		}
+		finish := func(ctx context.Context) {
+			// the outcome is reported by the upload job
+			if _, err := d.finishSaga(ctx, sagaId, req, doc, &src, true); err != nil {
+				log.GetLogger(ctx).Warn("upload job failed", zap.String("jobId", sagaId), zap.Error(err))
+			}
+		}
+		return &ingestResult{doc: doc, similar: similar, jobId: sagaId, finish: finish}, nil
+	}
+
+	// store, mint and save the doc, undoing the completed steps when it fails
+	doc, err = d.storeMintSave(ctx, req, doc, &src)
+	if err != nil {
+		return nil, err
+	}
+	return &ingestResult{doc: doc, similar: similar}, nil
+}
+
+// sniffSource detects the mime type of a document, unless it was sniffed on receipt
+func (d *DocH) sniffSource(src *docSource) error {
+	if src.mimeType != "" {
+		return nil
+	}
+	f, err := src.open()
+	if err != nil {
+		return &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to open file - %w", err)}
+	}
+	defer func() { _ = f.Close() }()
+	src.mimeType, _, err = sniffMime(f)
+	if err != nil {
+		return &stepErr{http.StatusInternalServerError, err}
+	}
+	return nil
+}
+
+// storeDoc puts the document in blob store along with its sniffed content type and returns its docId
+func (d *DocH) storeDoc(ctx context.Context, src *docSource) (string, error) {
+	if src.stored != "" {
+		return src.stored, nil
+	}
+	f, err := src.open()
+	if err != nil {
+		return "", &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to open file - %w", err)}
+	}
+	defer func() { _ = f.Close() }()
+	docId, err := d.Blob.Put(ctx, f, src.size, objMeta(*src))
+	if err != nil {
+		return "", &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to store in blob store - %w", err)}
+	}
+	return docId, nil
+}
+
+// deleteBlob removes a document which is no longer needed from blob store, leftovers are only logged
+func (d *DocH) deleteBlob(ctx context.Context, docId string) {
+	if err := d.Blob.Delete(ctx, docId); err != nil {
+		log.GetLogger(ctx).Warn("unable to delete doc from blob store", zap.String("docId", docId), zap.Error(err))
+	}
+}
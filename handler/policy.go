@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+)
+
+// checkPolicy applies the upload policy to a document with a sniffed mime type
+func (d *DocH) checkPolicy(ctx context.Context, ownerEmail string, src docSource) error {
+	if d.Policy == nil {
+		return nil
+	}
+	if err := d.Policy.CheckType(src.mimeType); err != nil {
+		return err
+	}
+	if err := d.Policy.CheckSize(src.mimeType, src.size); err != nil {
+		return err
+	}
+	return d.checkQuota(ctx, ownerEmail, src.size)
+}
+
+// checkQuota rejects a document of size bytes which takes its owner past their quota, so uploads are turned
+// down before they are scanned or stored. The quota is only enforced when the upload saga reserves its usage.
+func (d *DocH) checkQuota(ctx context.Context, ownerEmail string, size int64) error {
+	if d.Policy == nil || (d.Policy.OwnerMaxDocs == 0 && d.Policy.OwnerMaxBytes == 0) {
+		return nil
+	}
+	u, err := d.Db.GetUserUsage(ctx, ownerEmail)
+	if err != nil {
+		return &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to find owner usage in db - %w", err)}
+	}
+	return d.Policy.CheckQuota(u.Docs, u.Bytes, size)
+}
+
+// quota is the per-owner quota of the upload policy
+func (d *DocH) quota() dbtx.Quota {
+	if d.Policy == nil {
+		return dbtx.Quota{}
+	}
+	return dbtx.Quota{MaxDocs: d.Policy.OwnerMaxDocs, MaxBytes: d.Policy.OwnerMaxBytes}
+}
+
+// quotaErr is the policy violation of a document of size bytes whose usage could not be reserved,
+// as concurrent uploads took its owner close to their quota
+func (d *DocH) quotaErr(ctx context.Context, ownerEmail string, size int64, reserveErr error) error {
+	if err := d.checkQuota(ctx, ownerEmail, size); err != nil {
+		return err
+	}
+	return &stepErr{http.StatusTooManyRequests, reserveErr}
+}
+
+// maxSize is the largest document accepted for a mime type, 0 when unlimited
+func (d *DocH) maxSize(mimeType string) int64 {
+	if d.Policy == nil {
+		return 0
+	}
+	return d.Policy.MaxSizeFor(mimeType)
+}
@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// directPrefix is the blob store prefix under which documents put through presigned urls are staged until
+// they are finalised. The presigned url stays valid after finalising, so the doc is a copy out of its reach.
+const directPrefix = "direct/"
+
+// PresignDownload issues a presigned url which downloads a document straight from blob store, for its owner
+func (d *DocH) PresignDownload(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("presigned download request received")
+
+	var uri rest.DownloadReq
+	if err := c.BindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, rest.PresignResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	var req rest.PresignDownloadReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.PresignResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+
+	meta, err := d.Db.GetDocMeta(c, uri.DocId)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, rest.PresignResp{Error: "doc not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.PresignResp{Error: "unable to find file meta - " + err.Error()})
+		return
+	}
+	if !strings.EqualFold(meta.OwnerEmail, req.OwnerEmail) {
+		c.JSON(http.StatusForbidden, rest.PresignResp{Error: "only the doc owner can get a download url"})
+		return
+	}
+	if gone := docGone(meta); gone != "" {
+		c.JSON(http.StatusGone, rest.PresignResp{Error: gone})
+		return
+	}
+
+	// the doc is served as Download serves it, whatever it was stored with
+	contentType := meta.MimeType
+	if contentType == "" {
+		contentType = dbtx.DefaultMimeType
+	}
+	expiresAt := time.Now().Add(d.PresignGetTTL).UTC().Truncate(time.Second)
+	u, err := d.Blob.PresignGet(c, meta.DocId, d.PresignGetTTL,
+		blob.ObjMeta{ContentType: contentType, ContentDisposition: contentDisposition(meta.DocName)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, rest.PresignResp{Error: "unable to presign download - " + err.Error()})
+		return
+	}
+	logger.Info("presigned download issued", zap.String("docId", meta.DocId), zap.Time("expiresAt", expiresAt))
+	c.JSON(http.StatusOK, rest.PresignResp{Url: u, ExpiresAt: &expiresAt})
+}
+
+// DirectUpload creates an upload which the client puts in blob store through a presigned url,
+// offloading the transfer of its content from the service. It is finished by FinaliseDirectUpload.
+func (d *DocH) DirectUpload(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("direct upload creation request received")
+
+	var body rest.DirectUploadReq
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, rest.DirectUploadResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	meta := directUploadMeta(body)
+	req := tusUploadReq(meta)
+	err := binding.Validator.ValidateStruct(&req)
+	if err == nil {
+		err = parseDocMeta(&req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rest.DirectUploadResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+
+	// a retried creation gets the upload created by the first request
+	fingerprint := strings.Join([]string{body.OwnerEmail, body.DocTitle, body.DocDesc, body.OwnerFirstName,
+		body.OwnerLastName, body.Tags, body.ValidFrom, body.ValidUntil, body.FileName,
+		strconv.FormatInt(body.Size, 10)}, "\n")
+	errResp := func(err error) any { return rest.DirectUploadResp{Error: err.Error()} }
+	d.idempotent(c, req.OwnerEmail, fingerprint, errResp, func() {
+		// fail fast on uploads against the upload policy, the type is checked once the upload is finalised
+		if d.Policy != nil {
+			if err = d.Policy.CheckSize("", body.Size); err == nil {
+				err = d.checkQuota(c, req.OwnerEmail, body.Size)
+			}
+			if err != nil {
+				c.JSON(errStatus(err), errResp(err))
+				return
+			}
+		}
+
+		u := dbtx.DirectUpload{
+			UploadId:  uuid.New().String(),
+			Length:    body.Size,
+			Meta:      meta,
+			ExpiresAt: time.Now().Add(d.PresignPutTTL).UTC().Truncate(time.Second),
+		}
+		url, err := d.Blob.PresignPut(c, directObjName(u.UploadId), d.PresignPutTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errResp(fmt.Errorf("unable to presign upload - %w", err)))
+			return
+		}
+		if err = d.Db.CreateDirectUpload(c, u); err != nil {
+			c.JSON(http.StatusInternalServerError, errResp(fmt.Errorf("unable to persist to db - %w", err)))
+			return
+		}
+		logger.Info("direct upload created", zap.String("uploadId", u.UploadId), zap.Int64("uploadLength", u.Length))
+		c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+u.UploadId)
+		c.JSON(http.StatusCreated, rest.DirectUploadResp{UploadId: u.UploadId, Url: url, ExpiresAt: &u.ExpiresAt})
+	})
+}
+
+// FinaliseDirectUpload runs a document put in blob store through a presigned url through the same pipeline
+// as Upload. Its content is hashed again by the service, what the client claims of it is never trusted.
+func (d *DocH) FinaliseDirectUpload(c *gin.Context) {
+	logger := log.GetLogger(c)
+	var uri rest.DirectUploadUriReq
+	if err := c.BindUri(&uri); err != nil {
+		c.JSON(http.StatusNotFound, uploadResp(nil, fmt.Errorf("upload not found - %w", err)))
+		return
+	}
+	u, err := d.Db.GetDirectUpload(c, uri.UploadId)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, uploadResp(nil, errors.New("upload not found")))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to find upload in db - %w", err)))
+		return
+	}
+
+	// a finalise retried with an Idempotency-Key gets the response of the first one
+	errResp := func(err error) any { return uploadResp(nil, err) }
+	d.idempotent(c, u.Meta["ownerEmail"], u.UploadId, errResp, func() {
+		// a retried finalise gets the doc of the first one
+		if u.Status == dbtx.DirectCompleted {
+			doc, err := d.Db.GetDocMeta(c, u.DocId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, errResp(fmt.Errorf("unable to find doc in db - %w", err)))
+				return
+			}
+			c.JSON(http.StatusOK, uploadResp(&doc, nil))
+			return
+		}
+		if directExpired(u) {
+			c.JSON(http.StatusGone, errResp(errors.New("upload expired")))
+			return
+		}
+		claimed, err := d.Db.ClaimDirectUpload(c, u.UploadId, d.FinishTimeout)
+		if errors.Is(err, dbtx.ErrDirectUploadNotCreated) {
+			// the upload may have expired since it was looked up
+			if directExpired(u) {
+				c.JSON(http.StatusGone, errResp(errors.New("upload expired")))
+				return
+			}
+			c.JSON(http.StatusConflict, uploadResp(nil, errors.New("upload is being finalised")))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errResp(fmt.Errorf("unable to persist to db - %w", err)))
+			return
+		}
+		u = claimed
+
+		res, err := d.finishDirect(c, u)
+		if err != nil {
+			// the upload can be finalised again, once the client put the right doc when it was rejected for it
+			if e := d.Db.FinishDirectUpload(c, u.UploadId, dbtx.DirectCreated, ""); e != nil {
+				logger.Error("unable to release direct upload", zap.String("uploadId", u.UploadId), zap.Error(e))
+			}
+			c.JSON(errStatus(err), uploadResp(nil, err))
+			return
+		}
+		logger.Info("direct upload finalised", zap.String("uploadId", u.UploadId),
+			zap.String("docId", res.doc.DocId))
+		resp := uploadResp(&res.doc, nil)
+		resp.SimilarTo = res.similar
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// finishDirect hashes and scans the staged document, and copies it to a doc of its own which it mints and saves.
+// The staged document is kept for retries when finishing fails for reasons other than the document.
+func (d *DocH) finishDirect(ctx context.Context, u dbtx.DirectUpload) (*ingestResult, error) {
+	staged := directObjName(u.UploadId)
+	info, err := d.Blob.Stat(ctx, staged)
+	if err != nil {
+		return nil, &stepErr{http.StatusConflict, fmt.Errorf("doc was not put at the upload url - %w", err)}
+	}
+	if info.Size != u.Length {
+		d.deleteBlob(ctx, staged)
+		return nil, &stepErr{http.StatusBadRequest,
+			fmt.Errorf("doc put at the upload url is %d bytes, not the declared %d bytes", info.Size, u.Length)}
+	}
+
+	req := tusUploadReq(u.Meta)
+	if err = parseDocMeta(&req); err != nil {
+		return nil, &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	}
+	src := stagedSource(ctx, d.Blob, staged, u.Meta["filename"], info.Size)
+	if err = d.hashReq(ctx, &req, &src); err != nil {
+		return nil, err
+	}
+	// the staged doc is scanned before it is copied, so that malware never reaches the doc store
+	if err = d.scanSource(ctx, &src); err == nil {
+		err = d.storeStaged(ctx, &src, []string{staged})
+	}
+	if err != nil {
+		if errStatus(err) < http.StatusInternalServerError {
+			d.deleteBlob(ctx, staged)
+		}
+		return nil, err
+	}
+	res, err := d.ingest(ctx, &req, src, false)
+	if err != nil {
+		if errStatus(err) < http.StatusInternalServerError {
+			d.deleteBlob(ctx, staged)
+		}
+		return nil, err
+	}
+	if err = d.Db.FinishDirectUpload(ctx, u.UploadId, dbtx.DirectCompleted, res.doc.DocId); err != nil {
+		return nil, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+	}
+	d.deleteBlob(ctx, staged)
+	return res, nil
+}
+
+// directUploadMeta keeps the metadata of a direct upload as the metadata of a resumable upload
+func directUploadMeta(req rest.DirectUploadReq) map[string]string {
+	return map[string]string{
+		"ownerEmail":     req.OwnerEmail,
+		"docTitle":       req.DocTitle,
+		"docDesc":        req.DocDesc,
+		"ownerFirstName": req.OwnerFirstName,
+		"ownerLastName":  req.OwnerLastName,
+		"tags":           req.Tags,
+		"validFrom":      req.ValidFrom,
+		"validUntil":     req.ValidUntil,
+		"filename":       req.FileName,
+	}
+}
+
+func directObjName(uploadId string) string {
+	return directPrefix + uploadId
+}
+
+// directExpired tells whether a direct upload can no longer be finalised, as it was not finalised before it
+// expired. An upload being finalised when it expired is still finished.
+func directExpired(u dbtx.DirectUpload) bool {
+	return u.Status == dbtx.DirectExpired || (u.Status == dbtx.DirectCreated && !time.Now().Before(u.ExpiresAt))
+}
+
+// DirectCleanup cleans up direct uploads which were not finalised before they expired, along with the
+// documents put in blob store for them
+type DirectCleanup struct {
+	// Interval is how often expired uploads are looked for. Uploads are cleaned up an interval after they expire,
+	// leaving time for documents put just before their url expired to be stored.
+	Interval time.Duration
+	// BatchSize is the max number of uploads cleaned up at once
+	BatchSize int
+}
+
+// CleanupDirectUploads periodically cleans up expired direct uploads, until ctx is done
+func (d *DocH) CleanupDirectUploads(ctx context.Context) {
+	if d.DirectCleanup == nil {
+		return
+	}
+	t := time.NewTicker(d.DirectCleanup.Interval)
+	defer t.Stop()
+	for {
+		d.cleanupDirectUploads(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// cleanupDirectUploads cleans up a batch of expired direct uploads and returns how many were cleaned up.
+// An upload whose document could not be removed is released, so it is cleaned up by a later run.
+func (d *DocH) cleanupDirectUploads(ctx context.Context) int {
+	logger := log.GetLogger(ctx)
+	uploads, err := d.Db.ClaimExpiredDirectUploads(ctx, d.DirectCleanup.Interval, d.DirectCleanup.BatchSize)
+	if err != nil {
+		logger.Error("unable to find expired direct uploads", zap.Error(err))
+		return 0
+	}
+	cleaned := 0
+	for _, u := range uploads {
+		if err = d.Blob.Delete(ctx, directObjName(u.UploadId)); err != nil {
+			logger.Warn("unable to delete doc of expired direct upload", zap.String("uploadId", u.UploadId),
+				zap.Error(err))
+			if err = d.Db.FinishDirectUpload(ctx, u.UploadId, dbtx.DirectCreated, ""); err != nil {
+				logger.Error("unable to release expired direct upload", zap.String("uploadId", u.UploadId),
+					zap.Error(err))
+			}
+			continue
+		}
+		cleaned++
+	}
+	if len(uploads) > 0 {
+		logger.Info("cleaned up expired direct uploads", zap.Int("expired", len(uploads)),
+			zap.Int("cleaned", cleaned))
+	}
+	return cleaned
+}
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// directStore keeps direct uploads in memory along with the upload sagas and docs of sagaStore
+type directStore struct {
+	*sagaStore
+	uploads map[string]*dbtx.DirectUpload
+	// claimedAt is when uploads were last claimed for finalising
+	claimedAt map[string]time.Time
+}
+
+func (s *directStore) CreateDirectUpload(_ context.Context, in dbtx.DirectUpload) error {
+	in.Status = dbtx.DirectCreated
+	s.uploads[in.UploadId] = &in
+	return nil
+}
+
+func (s *directStore) GetDirectUpload(_ context.Context, uploadId string) (dbtx.DirectUpload, error) {
+	u, ok := s.uploads[uploadId]
+	if !ok {
+		return dbtx.DirectUpload{}, sql.ErrNoRows
+	}
+	return *u, nil
+}
+
+func (s *directStore) ClaimDirectUpload(_ context.Context, uploadId string,
+	staleFor time.Duration) (dbtx.DirectUpload, error) {
+	u := s.uploads[uploadId]
+	created := u.Status == dbtx.DirectCreated && time.Now().Before(u.ExpiresAt)
+	stale := u.Status == dbtx.DirectFinalising && time.Since(s.claimedAt[uploadId]) > staleFor
+	if !created && !stale {
+		return dbtx.DirectUpload{}, dbtx.ErrDirectUploadNotCreated
+	}
+	u.Status, s.claimedAt[uploadId] = dbtx.DirectFinalising, time.Now()
+	return *u, nil
+}
+
+func (s *directStore) ClaimExpiredDirectUploads(_ context.Context, expiredFor time.Duration,
+	maxUploads int) ([]dbtx.DirectUpload, error) {
+	var out []dbtx.DirectUpload
+	for _, u := range s.uploads {
+		if len(out) < maxUploads && u.Status == dbtx.DirectCreated && time.Since(u.ExpiresAt) >= expiredFor {
+			u.Status = dbtx.DirectExpired
+			out = append(out, *u)
+		}
+	}
+	return out, nil
+}
+
+func (s *directStore) FinishDirectUpload(_ context.Context, uploadId, status, docId string) error {
+	s.uploads[uploadId].Status, s.uploads[uploadId].DocId = status, docId
+	return nil
+}
+
+func (s *directStore) GetDocMeta(_ context.Context, docId string) (dbtx.DocMeta, error) {
+	for _, doc := range s.docs {
+		if doc.DocId == docId {
+			return doc, nil
+		}
+	}
+	return dbtx.DocMeta{}, sql.ErrNoRows
+}
+
+func newDirectStore() *directStore {
+	return &directStore{sagaStore: newSagaStore(), uploads: make(map[string]*dbtx.DirectUpload),
+		claimedAt: make(map[string]time.Time)}
+}
+
+// idemDirectStore is a directStore which keeps idempotency keys in memory too
+type idemDirectStore struct {
+	*directStore
+	idem *idemStore
+}
+
+func (s idemDirectStore) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string,
+	ttl, lease time.Duration) (dbtx.IdemKey, bool, error) {
+	return s.idem.ClaimIdempotencyKey(ctx, key, fingerprint, ttl, lease)
+}
+
+func (s idemDirectStore) CompleteIdempotencyKey(ctx context.Context, key string, resp dbtx.IdemResponse) error {
+	return s.idem.CompleteIdempotencyKey(ctx, key, resp)
+}
+
+func (s idemDirectStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return s.idem.ReleaseIdempotencyKey(ctx, key)
+}
+
+func jsonCtx(t *testing.T, w *httptest.ResponseRecorder, path string, body any) *gin.Context {
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestDocH_PresignDownload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docId := "0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a"
+	d := &DocH{
+		Db: &claimStore{docs: map[string]dbtx.DocMeta{docId: {DocId: docId, DocName: "doc.txt",
+			OwnerEmail: "john.doe@example.com", MimeType: "text/plain"}}},
+		Blob:          memBlob{},
+		PresignGetTTL: 15 * time.Minute,
+	}
+	presign := func(docId, ownerEmail string) (*httptest.ResponseRecorder, rest.PresignResp) {
+		w := httptest.NewRecorder()
+		c := jsonCtx(t, w, "/svc/v1/doc/download/"+docId+"/url", rest.PresignDownloadReq{OwnerEmail: ownerEmail})
+		c.Params = gin.Params{{Key: "docId", Value: docId}}
+		d.PresignDownload(c)
+		var resp rest.PresignResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w, resp
+	}
+
+	w, resp := presign(docId, "John.Doe@example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mem://"+docId+"?expiry=15m0s&type=text/plain", resp.Url)
+	require.NotNil(t, resp.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *resp.ExpiresAt, 2*time.Second)
+
+	w, resp = presign(docId, "jane.doe@example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, resp.Url)
+
+	w, _ = presign("5d2a1b8e-9c1e-4f0a-8d7b-2e6f3c4a5b6c", "john.doe@example.com")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDocH_DirectUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newDirectStore()
+	mb := memBlob{}
+	d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, H: hash.Md5{}, PresignPutTTL: time.Hour,
+		IdempotencyTTL: time.Hour, FinishTimeout: time.Minute}
+
+	create := func(size int64) rest.DirectUploadResp {
+		w := httptest.NewRecorder()
+		d.DirectUpload(jsonCtx(t, w, "/svc/v1/doc/direct", rest.DirectUploadReq{
+			OwnerEmail:     "john.doe@example.com",
+			DocTitle:       "lease",
+			OwnerFirstName: "john",
+			OwnerLastName:  "doe",
+			FileName:       "lease.txt",
+			Size:           size,
+		}))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp rest.DirectUploadResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	finalise := func(uploadId string) (*httptest.ResponseRecorder, rest.UploadResp) {
+		w := httptest.NewRecorder()
+		c := jsonCtx(t, w, "/svc/v1/doc/direct/"+uploadId+"/finalise", nil)
+		c.Params = gin.Params{{Key: "uploadId", Value: uploadId}}
+		d.FinaliseDirectUpload(c)
+		var resp rest.UploadResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w, resp
+	}
+
+	t.Run("finalised doc is hashed by the service", func(t *testing.T) {
+		created := create(7)
+		assert.Equal(t, "mem://direct/"+created.UploadId+"?expiry=1h0m0s", created.Url)
+
+		// finalising before the doc is put fails, and can be retried
+		w, _ := finalise(created.UploadId)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, dbtx.DirectCreated, store.uploads[created.UploadId].Status)
+
+		mb[directObjName(created.UploadId)] = []byte("content")
+		w, resp := finalise(created.UploadId)
+		require.Equal(t, http.StatusOK, w.Code, resp.Error)
+		require.NotNil(t, resp.Doc)
+		assert.Equal(t, "9a0364b9e99bb480dd25e1f0284c8555", resp.Doc.DocMd5Hash)
+		assert.Equal(t, "lease.txt", resp.Doc.DocName)
+		assert.Equal(t, "tkn-"+resp.Doc.DocId, resp.Doc.BcTknId)
+		assert.Equal(t, []byte("content"), mb[resp.Doc.DocId])
+		assert.NotContains(t, mb, directObjName(created.UploadId))
+		assert.Equal(t, dbtx.DirectCompleted, store.uploads[created.UploadId].Status)
+
+		// a retried finalise gets the same doc
+		w, again := finalise(created.UploadId)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, resp.Doc.DocId, again.Doc.DocId)
+	})
+
+	t.Run("doc of another size is rejected", func(t *testing.T) {
+		created := create(100)
+		mb[directObjName(created.UploadId)] = []byte("short")
+		w, resp := finalise(created.UploadId)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, resp.Error, "not the declared 100 bytes")
+		assert.NotContains(t, mb, directObjName(created.UploadId))
+		assert.Equal(t, dbtx.DirectCreated, store.uploads[created.UploadId].Status)
+	})
+
+	t.Run("infected doc never copied", func(t *testing.T) {
+		d.Scanner = fakeScanner{}
+		defer func() { d.Scanner = nil }()
+		created := create(int64(len("EICAR content")))
+		mb[directObjName(created.UploadId)] = []byte("EICAR content")
+		before := maps.Clone(mb)
+		delete(before, directObjName(created.UploadId))
+		w, resp := finalise(created.UploadId)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "Eicar-Test-Signature", resp.Malware)
+		assert.Equal(t, before, mb)
+	})
+
+	t.Run("copied doc removed when finalising fails", func(t *testing.T) {
+		created := create(7)
+		mb[directObjName(created.UploadId)] = []byte("content")
+		before := maps.Clone(mb)
+		store.lookupErr = errors.New("db down")
+		defer func() { store.lookupErr = nil }()
+		w, _ := finalise(created.UploadId)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, dbtx.DirectCreated, store.uploads[created.UploadId].Status)
+		assert.Equal(t, before, mb, "the copy is removed and the staged doc is kept for a retry")
+	})
+
+	t.Run("retried with an idempotency key", func(t *testing.T) {
+		d.Db = idemDirectStore{directStore: store, idem: &idemStore{keys: make(map[string]dbtx.IdemKey)}}
+		defer func() { d.Db = store }()
+		createWithKey := func(owner string) rest.DirectUploadResp {
+			w := httptest.NewRecorder()
+			c := jsonCtx(t, w, "/svc/v1/doc/direct", rest.DirectUploadReq{OwnerEmail: owner, DocTitle: "lease",
+				OwnerFirstName: "john", OwnerLastName: "doe", FileName: "lease.txt", Size: 7})
+			c.Request.Header.Set(idempotencyKeyHdr, "key-1")
+			d.DirectUpload(c)
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			var resp rest.DirectUploadResp
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			return resp
+		}
+		first := createWithKey("john.doe@example.com")
+		assert.Equal(t, first.UploadId, createWithKey("john.doe@example.com").UploadId)
+		// the key of another owner is another request
+		assert.NotEqual(t, first.UploadId, createWithKey("jane.doe@example.com").UploadId)
+	})
+
+	t.Run("expired upload", func(t *testing.T) {
+		created := create(7)
+		mb[directObjName(created.UploadId)] = []byte("content")
+		store.uploads[created.UploadId].ExpiresAt = time.Now().Add(-time.Second)
+		w, resp := finalise(created.UploadId)
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Equal(t, "upload expired", resp.Error)
+		assert.Equal(t, dbtx.DirectCreated, store.uploads[created.UploadId].Status)
+	})
+
+	t.Run("upload left finalising is finalised again", func(t *testing.T) {
+		created := create(7)
+		mb[directObjName(created.UploadId)] = []byte("content")
+		store.uploads[created.UploadId].Status = dbtx.DirectFinalising
+		store.claimedAt[created.UploadId] = time.Now()
+		w, _ := finalise(created.UploadId)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// the instance finalising it went down, it is finalised even if it expired since
+		store.claimedAt[created.UploadId] = time.Now().Add(-2 * time.Minute)
+		store.uploads[created.UploadId].ExpiresAt = time.Now().Add(-time.Second)
+		w, resp := finalise(created.UploadId)
+		require.Equal(t, http.StatusOK, w.Code, resp.Error)
+		assert.Equal(t, dbtx.DirectCompleted, store.uploads[created.UploadId].Status)
+	})
+
+	t.Run("unknown upload", func(t *testing.T) {
+		w, _ := finalise("5d2a1b8e-9c1e-4f0a-8d7b-2e6f3c4a5b6c")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestDocH_cleanupDirectUploads(t *testing.T) {
+	store, mb := newDirectStore(), memBlob{}
+	d := &DocH{Db: store, Blob: mb, DirectCleanup: &DirectCleanup{Interval: time.Minute, BatchSize: 10}}
+	for id, expiresAt := range map[string]time.Time{
+		"expired":         time.Now().Add(-time.Hour),
+		"just-expired":    time.Now().Add(-time.Second),
+		"not-yet-expired": time.Now().Add(time.Hour),
+	} {
+		store.uploads[id] = &dbtx.DirectUpload{UploadId: id, Status: dbtx.DirectCreated, ExpiresAt: expiresAt}
+		mb[directObjName(id)] = []byte("content")
+	}
+
+	assert.Equal(t, 1, d.cleanupDirectUploads(context.Background()))
+	assert.Equal(t, dbtx.DirectExpired, store.uploads["expired"].Status)
+	assert.NotContains(t, mb, directObjName("expired"))
+	// uploads are cleaned up an interval after they expire, a put started before is let finish
+	assert.Equal(t, dbtx.DirectCreated, store.uploads["just-expired"].Status)
+	assert.Contains(t, mb, directObjName("just-expired"))
+	assert.Contains(t, mb, directObjName("not-yet-expired"))
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// problem responds with the problem details of a failed request
+func problem(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", rest.ProblemContentType)
+	c.AbortWithStatusJSON(status, rest.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+)
+
+// errRangeNotSatisfiable is returned for a range which does not overlap the document
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// docETag is the strong entity tag of a document, its content hash
+func docETag(meta dbtx.DocMeta) string {
+	return `"` + meta.DocMd5Hash + `"`
+}
+
+// notModified reports whether the cached copy a client validates with If-None-Match or If-Modified-Since
+// is current. If-Modified-Since is ignored when If-None-Match is sent, as in RFC 9110.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatch(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	return err == nil && !modified.Truncate(time.Second).After(t)
+}
+
+// etagListMatch reports whether a list of entity tags, or *, matches etag in a weak comparison
+func etagListMatch(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// docRange resolves the Range of a request for a document of the given size. It returns nil when the whole
+// document is to be sent: without a Range, when If-Range does not match the document, and for ranges
+// which are not a single valid byte range, which RFC 9110 allows to be ignored.
+func docRange(r *http.Request, etag string, modified time.Time, size int64) (*blob.ByteRange, error) {
+	h := r.Header.Get("Range")
+	if h == "" || !ifRangeMatch(r.Header.Get("If-Range"), etag, modified) {
+		return nil, nil
+	}
+	spec, ok := strings.CutPrefix(h, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	// a suffix range is the last bytes of the document
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		switch {
+		case err != nil || n < 0:
+			return nil, nil
+		case n == 0 || size == 0:
+			return nil, errRangeNotSatisfiable
+		}
+		return &blob.ByteRange{Start: max(size-n, 0), End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return nil, nil
+		}
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	return &blob.ByteRange{Start: start, End: min(end, size-1)}, nil
+}
+
+// ifRangeMatch reports whether a Range applies to the document, If-Range is a strong entity tag
+// or the exact modification time of the representation the client has part of
+func ifRangeMatch(ifRange, etag string, modified time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return ifRange == etag
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !modified.IsZero() && modified.Truncate(time.Second).Equal(t)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vposham/trustdoc/internal/blob"
+)
+
+func Test_docRange(t *testing.T) {
+	etag := `"hash-1"`
+	modified := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		hdr     map[string]string
+		want    *blob.ByteRange
+		wantErr bool
+	}{
+		{name: "no range"},
+		{name: "range", hdr: map[string]string{"Range": "bytes=2-5"}, want: &blob.ByteRange{Start: 2, End: 5}},
+		{name: "open range", hdr: map[string]string{"Range": "bytes=4-"}, want: &blob.ByteRange{Start: 4, End: 9}},
+		{name: "range past end", hdr: map[string]string{"Range": "bytes=8-20"}, want: &blob.ByteRange{Start: 8, End: 9}},
+		{name: "suffix range", hdr: map[string]string{"Range": "bytes=-3"}, want: &blob.ByteRange{Start: 7, End: 9}},
+		{name: "suffix longer than doc", hdr: map[string]string{"Range": "bytes=-30"},
+			want: &blob.ByteRange{Start: 0, End: 9}},
+		{name: "start past end", hdr: map[string]string{"Range": "bytes=10-"}, wantErr: true},
+		{name: "empty suffix", hdr: map[string]string{"Range": "bytes=-0"}, wantErr: true},
+		{name: "multiple ranges", hdr: map[string]string{"Range": "bytes=0-1,4-5"}},
+		{name: "invalid range", hdr: map[string]string{"Range": "bytes=5-2"}},
+		{name: "other unit", hdr: map[string]string{"Range": "items=0-1"}},
+		{name: "if-range etag", hdr: map[string]string{"Range": "bytes=0-1", "If-Range": etag},
+			want: &blob.ByteRange{Start: 0, End: 1}},
+		{name: "if-range other etag", hdr: map[string]string{"Range": "bytes=0-1", "If-Range": `"hash-2"`}},
+		{name: "if-range weak etag", hdr: map[string]string{"Range": "bytes=0-1", "If-Range": "W/" + etag}},
+		{name: "if-range date", hdr: map[string]string{"Range": "bytes=0-1", "If-Range": modified.Format(http.TimeFormat)},
+			want: &blob.ByteRange{Start: 0, End: 1}},
+		{name: "if-range older date", hdr: map[string]string{"Range": "bytes=0-1",
+			"If-Range": modified.Add(-time.Hour).Format(http.TimeFormat)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.hdr {
+				r.Header.Set(k, v)
+			}
+			got, err := docRange(r, etag, modified, 10)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errRangeNotSatisfiable)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_notModified(t *testing.T) {
+	etag := `"hash-1"`
+	modified := time.Date(2026, 1, 1, 10, 0, 0, 500, time.UTC)
+	tests := []struct {
+		name string
+		hdr  map[string]string
+		want bool
+	}{
+		{name: "unconditional"},
+		{name: "etag matches", hdr: map[string]string{"If-None-Match": `"hash-2", W/"hash-1"`}, want: true},
+		{name: "any etag", hdr: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "etag differs", hdr: map[string]string{"If-None-Match": `"hash-2"`}},
+		{name: "not modified since", hdr: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			want: true},
+		{name: "modified since", hdr: map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(
+			http.TimeFormat)}},
+		{name: "etag takes precedence", hdr: map[string]string{"If-None-Match": `"hash-2"`,
+			"If-Modified-Since": modified.Format(http.TimeFormat)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.hdr {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, notModified(r, etag, modified))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/receipt"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// Receipts signs receipts of verifications, which third parties validate with the published keys
+type Receipts struct {
+	Issuer string
+	Signer *receipt.Signer
+}
+
+// Jwks publishes the public keys verification receipts are signed with
+func (d *DocH) Jwks(c *gin.Context) {
+	keys := receipt.JWKS{Keys: []receipt.JWK{}}
+	if d.Receipts != nil {
+		keys = d.Receipts.Signer.Keys()
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, keys)
+}
+
+// ValidateReceipt validates a verification receipt with the keys of the service, as receipt.Validate does offline
+func (d *DocH) ValidateReceipt(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("receipt validation request received")
+
+	var req rest.ValidateReceiptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.ValidateReceiptResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	keys := receipt.JWKS{}
+	if d.Receipts != nil {
+		keys = d.Receipts.Signer.Keys()
+	}
+	claims, err := receipt.Validate(req.Receipt, keys)
+	if err != nil {
+		c.JSON(http.StatusOK, rest.ValidateReceiptResp{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rest.ValidateReceiptResp{Valid: true, Claims: &claims})
+}
+
+// signReceipt attaches a signed receipt of a verification to its response, when receipts are issued
+func (d *DocH) signReceipt(ctx context.Context, resp *rest.VerifyResp, claims receipt.Claims) (int, *rest.VerifyResp) {
+	if d.Receipts == nil {
+		return http.StatusOK, resp
+	}
+	claims.Id, claims.Issuer, claims.IssuedAt = uuid.NewString(), d.Receipts.Issuer, time.Now().Unix()
+	claims.Verified, claims.Reasons = resp.Verified, resp.Reasons
+	r, err := d.Receipts.Signer.Sign(claims)
+	if err != nil {
+		return http.StatusInternalServerError, verifyResp(false, fmt.Errorf("unable to sign receipt - %w", err))
+	}
+	log.GetLogger(ctx).Info("verification receipt issued", zap.String("receiptId", claims.Id))
+	resp.Receipt = r
+	return http.StatusOK, resp
+}
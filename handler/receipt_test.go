@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/receipt"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+func TestDocH_Receipts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const owner = "john.doe@example.com"
+	ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader(owner))
+	require.NoError(t, err)
+	signer, err := receipt.NewSigner(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	require.NoError(t, err)
+	db := newSagaStore()
+	db.docs[contentMd5] = dbtx.DocMeta{DocId: "doc-1", OwnerEmail: owner, DocMd5Hash: contentMd5, BcTknId: "tkn-1"}
+	d := &DocH{Db: db, H: hash.Md5{}, Receipts: &Receipts{Issuer: "trustdoc", Signer: signer},
+		Bc: anchorBc{anchors: map[string]bc.Anchor{"tkn-1": {DocHash: contentMd5, OwnerEmailMd5Hash: ownerMd5,
+			TokenId: "7", ContractAddress: "0xdef", ChainId: 1337}}}}
+	jwks := func(d *DocH) receipt.JWKS {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		d.Jwks(c)
+		require.Equal(t, http.StatusOK, w.Code)
+		var keys receipt.JWKS
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+		return keys
+	}
+	validate := func(r string) rest.ValidateReceiptResp {
+		w := httptest.NewRecorder()
+		d.ValidateReceipt(jsonCtx(t, w, "/svc/v1/doc/receipts/validate", rest.ValidateReceiptReq{Receipt: r}))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp rest.ValidateReceiptResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := httptest.NewRecorder()
+	d.VerifyHash(jsonCtx(t, w, "/svc/v1/doc/verify/hash", rest.VerifyHashReq{Algorithm: hash.AlgoMd5,
+		Digest: contentMd5}))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp rest.VerifyResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.True(t, resp.Verified)
+	require.NotEmpty(t, resp.Receipt)
+
+	// the receipt is validated offline with the published keys
+	claims, err := receipt.Validate(resp.Receipt, jwks(d))
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims.Id)
+	assert.NotZero(t, claims.IssuedAt)
+	claims.Id, claims.IssuedAt = "", 0
+	assert.Equal(t, receipt.Claims{Issuer: "trustdoc", DocId: "doc-1", DocHash: contentMd5, HashAlgo: hash.AlgoMd5,
+		BcTknId: "tkn-1", TokenId: "7", ContractAddress: "0xdef", ChainId: 1337, Verified: true}, claims)
+
+	valid := validate(resp.Receipt)
+	assert.True(t, valid.Valid)
+	assert.Equal(t, contentMd5, valid.Claims.DocHash)
+
+	parts := strings.Split(resp.Receipt, ".")
+	forged := validate(parts[0] + ".eyJ2ZXJpZmllZCI6dHJ1ZX0." + parts[2])
+	assert.False(t, forged.Valid)
+	assert.Nil(t, forged.Claims)
+	assert.NotEmpty(t, forged.Error)
+
+	// no receipts are issued without a signing key
+	d.Receipts = nil
+	assert.Empty(t, jwks(d).Keys)
+	w = httptest.NewRecorder()
+	d.VerifyHash(jsonCtx(t, w, "/svc/v1/doc/verify/hash", rest.VerifyHashReq{Algorithm: hash.AlgoMd5,
+		Digest: contentMd5}))
+	var unsigned rest.VerifyResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &unsigned))
+	assert.True(t, unsigned.Verified)
+	assert.Empty(t, unsigned.Receipt)
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// UploadRecovery finishes or rolls back uploads interrupted by a failure or a crash
+type UploadRecovery struct {
+	// Interval is how often interrupted uploads are looked for
+	Interval time.Duration
+	// StaleAfter is how long an upload makes no progress before it is taken as interrupted.
+	// It has to be well above the time an upload takes, so uploads in progress are not rolled back.
+	StaleAfter time.Duration
+	// BatchSize is the max number of interrupted uploads recovered at once
+	BatchSize int
+	// MaxAttempts is how many times saving a minted upload is attempted before it is failed
+	MaxAttempts int
+}
+
+// storeMintSave puts a received document in blob store, mints a tkn for it and saves its metadata.
+// Its progress is persisted as an upload saga. A doc which could not be minted is deleted from blob store,
+// while a minted doc which could not be saved is kept, so saving it is retried by the upload recovery.
+func (d *DocH) storeMintSave(ctx context.Context, req *rest.UploadReq, doc dbtx.DocMeta,
+	src *docSource) (dbtx.DocMeta, error) {
+	sagaId, doc, err := d.startSaga(ctx, doc, src)
+	if err != nil {
+		return doc, err
+	}
+	return d.finishSaga(ctx, sagaId, req, doc, src, false)
+}
+
+// startSaga records the upload saga of a received document and returns its sagaId, the document is
+// already stored when it was streamed to blob store on receipt. The saga reserves the usage of the
+// document in the quota of its owner.
+func (d *DocH) startSaga(ctx context.Context, doc dbtx.DocMeta, src *docSource) (string, dbtx.DocMeta, error) {
+	sagaId := uuid.New().String()
+	state := dbtx.SagaReceived
+	if src.stored != "" {
+		state, doc.DocId = dbtx.SagaStored, src.stored
+	}
+	err := d.Db.StartUploadSaga(ctx, dbtx.UploadSaga{SagaId: sagaId, State: state, Doc: doc}, d.quota())
+	if err != nil {
+		if src.stored != "" {
+			d.deleteBlob(ctx, src.stored)
+		}
+		if errors.Is(err, dbtx.ErrQuotaExceeded) {
+			return "", doc, d.quotaErr(ctx, doc.OwnerEmail, doc.DocSize, err)
+		}
+		return "", doc, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+	}
+	return sagaId, doc, nil
+}
+
+// finishSaga takes a started upload saga through storing, minting and saving the document. Minted tkns
+// are confirmed in blockchain before saving when confirm is set, which can take tens of seconds.
+func (d *DocH) finishSaga(ctx context.Context, sagaId string, req *rest.UploadReq, doc dbtx.DocMeta,
+	src *docSource, confirm bool) (dbtx.DocMeta, error) {
+	logger := log.GetLogger(ctx).With(zap.String("sagaId", sagaId))
+
+	// store the file in blob store, unless it was streamed there on receipt
+	if doc.DocId == "" {
+		docId, err := d.storeDoc(ctx, src)
+		if err != nil {
+			_ = d.failSagaStep(ctx, sagaId, err)
+			_ = d.rollBack(ctx, sagaId, dbtx.SagaReceived, "")
+			return doc, err
+		}
+		doc.DocId = docId
+		if err = d.Db.AdvanceUploadSaga(ctx, sagaId, dbtx.SagaReceived, dbtx.SagaStored, doc); err != nil {
+			d.deleteBlob(ctx, docId)
+			return doc, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+		}
+	}
+
+	// mint a new tkn in blockchain
+	bcTknId, err := d.Bc.MintDocTkn(ctx, doc.DocId, req.DocMd5Hash, req.OwnerEmailMd5Hash, docValidity(doc))
+	if err != nil {
+		err = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to sign in blockchain - %w", err)}
+		_ = d.failSagaStep(ctx, sagaId, err)
+		_ = d.rollBack(ctx, sagaId, dbtx.SagaStored, doc.DocId)
+		return doc, err
+	}
+	doc.BcTknId = bcTknId
+	if err = d.Db.AdvanceUploadSaga(ctx, sagaId, dbtx.SagaStored, dbtx.SagaMinted, doc); err != nil {
+		// the upload is rolled back by the recovery, leaving the tkn behind
+		logger.Error("unable to record minted tkn of upload", zap.String("bcTknId", bcTknId), zap.Error(err))
+		return doc, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+	}
+
+	// a tkn which could not be confirmed in time is saved by the recovery
+	state := dbtx.SagaMinted
+	if confirm {
+		if err = d.Bc.ConfirmDocTkn(ctx, bcTknId); err != nil {
+			err = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to confirm in blockchain - %w", err)}
+			return doc, d.failSagaStep(ctx, sagaId, err)
+		}
+		if err = d.Db.AdvanceUploadSaga(ctx, sagaId, dbtx.SagaMinted, dbtx.SagaConfirmed, dbtx.DocMeta{}); err != nil {
+			return doc, &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+		}
+		state = dbtx.SagaConfirmed
+	}
+
+	// store the metadata in db
+	if err = d.Db.SaveUploadSagaDoc(ctx, sagaId, state, doc); err != nil {
+		err = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to persist to db - %w", err)}
+		return doc, d.failSagaStep(ctx, sagaId, err)
+	}
+	return doc, nil
+}
+
+// rollBack compensates the completed steps of an upload which can not be finished, by deleting
+// the doc from blob store. A failed delete is recorded, and the rollback is retried by the recovery.
+func (d *DocH) rollBack(ctx context.Context, sagaId, from, docId string) error {
+	logger := log.GetLogger(ctx).With(zap.String("sagaId", sagaId), zap.String("docId", docId))
+	if docId != "" {
+		if err := d.Blob.Delete(ctx, docId); err != nil {
+			logger.Warn("unable to delete doc of rolled back upload from blob store", zap.Error(err))
+			return d.failSagaStep(ctx, sagaId, fmt.Errorf("unable to delete doc from blob store - %w", err))
+		}
+	}
+	if err := d.Db.AdvanceUploadSaga(ctx, sagaId, from, dbtx.SagaRolledBack, dbtx.DocMeta{}); err != nil {
+		logger.Error("unable to roll back upload", zap.Error(err))
+		return err
+	}
+	logger.Info("upload rolled back", zap.String("fromState", from))
+	return nil
+}
+
+// failSagaStep records a failed step of an upload, so it is retried by the recovery
+func (d *DocH) failSagaStep(ctx context.Context, sagaId string, err error) error {
+	if ferr := d.Db.FailUploadSagaStep(ctx, sagaId, err); ferr != nil {
+		log.GetLogger(ctx).Error("unable to record failed upload step", zap.String("sagaId", sagaId),
+			zap.NamedError("stepErr", err), zap.Error(ferr))
+	}
+	return err
+}
+
+// RecoverUploads periodically finishes or rolls back interrupted uploads, until ctx is done
+func (d *DocH) RecoverUploads(ctx context.Context) {
+	if d.Recovery == nil {
+		return
+	}
+	t := time.NewTicker(d.Recovery.Interval)
+	defer t.Stop()
+	for {
+		d.recoverUploads(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// recoverUploads recovers a batch of interrupted uploads and returns how many of them were recovered
+func (d *DocH) recoverUploads(ctx context.Context) int {
+	logger := log.GetLogger(ctx)
+	sagas, err := d.Db.ClaimStaleUploadSagas(ctx, d.Recovery.StaleAfter, d.Recovery.BatchSize)
+	if err != nil {
+		logger.Error("unable to find interrupted uploads", zap.Error(err))
+		return 0
+	}
+	recovered := 0
+	for _, s := range sagas {
+		if err = d.recoverUpload(ctx, s); err != nil {
+			logger.Warn("unable to recover interrupted upload", zap.String("sagaId", s.SagaId), zap.Error(err))
+			continue
+		}
+		recovered++
+	}
+	if len(sagas) > 0 {
+		logger.Info("recovered interrupted uploads", zap.Int("interrupted", len(sagas)), zap.Int("recovered", recovered))
+	}
+	return recovered
+}
+
+// recoverUpload saves a minted upload, and rolls back an upload which was not minted. A doc might have
+// been minted when its upload was interrupted, but that tkn is not recorded and can not be used.
+func (d *DocH) recoverUpload(ctx context.Context, s dbtx.UploadSaga) error {
+	logger := log.GetLogger(ctx).With(zap.String("sagaId", s.SagaId), zap.String("state", s.State),
+		zap.String("docId", s.Doc.DocId))
+	switch s.State {
+	case dbtx.SagaReceived, dbtx.SagaStored:
+		return d.rollBack(ctx, s.SagaId, s.State, s.Doc.DocId)
+	case dbtx.SagaMinted, dbtx.SagaConfirmed:
+	default:
+		return fmt.Errorf("upload in state %s can not be recovered", s.State)
+	}
+
+	if s.Attempts >= d.Recovery.MaxAttempts {
+		logger.Error("minted upload could not be saved, it needs to be resolved manually",
+			zap.String("bcTknId", s.Doc.BcTknId), zap.Int("attempts", s.Attempts), zap.String("lastErr", s.LastError))
+		return d.Db.AdvanceUploadSaga(ctx, s.SagaId, s.State, dbtx.SagaFailed, dbtx.DocMeta{})
+	}
+
+	// the doc is not kept when it was uploaded again meanwhile, only its tkn is left behind
+	existing, err := d.Db.GetDocMetaByHash(ctx, s.Doc.DocMd5Hash)
+	switch {
+	case err == nil:
+		logger.Warn("doc of interrupted upload was uploaded again", zap.String("existingDocId", existing.DocId),
+			zap.String("bcTknId", s.Doc.BcTknId))
+		return d.rollBack(ctx, s.SagaId, s.State, s.Doc.DocId)
+	case !errors.Is(err, sql.ErrNoRows):
+		return d.failSagaStep(ctx, s.SagaId, fmt.Errorf("unable to find doc in db - %w", err))
+	}
+
+	if err = d.Db.SaveUploadSagaDoc(ctx, s.SagaId, s.State, s.Doc); err != nil {
+		return d.failSagaStep(ctx, s.SagaId, err)
+	}
+	logger.Info("interrupted upload saved", zap.String("bcTknId", s.Doc.BcTknId))
+	return nil
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/policy"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// sagaStore keeps upload sagas, saved docs and owner usage in memory, saving docs fails while saveErr is set
+// and looking them up by hash while lookupErr is set
+type sagaStore struct {
+	dbtx.MockStore
+	sagas     map[string]*dbtx.UploadSaga
+	docs      map[string]dbtx.DocMeta
+	usage     map[string]dbtx.Usage
+	saveErr   error
+	lookupErr error
+}
+
+func newSagaStore() *sagaStore {
+	return &sagaStore{sagas: make(map[string]*dbtx.UploadSaga), docs: make(map[string]dbtx.DocMeta),
+		usage: make(map[string]dbtx.Usage)}
+}
+
+func (s *sagaStore) StartUploadSaga(_ context.Context, in dbtx.UploadSaga, quota dbtx.Quota) error {
+	u := s.usage[in.Doc.OwnerEmail]
+	u.Docs, u.Bytes = u.Docs+1, u.Bytes+in.Doc.DocSize
+	if (quota.MaxDocs > 0 && u.Docs > quota.MaxDocs) || (quota.MaxBytes > 0 && u.Bytes > quota.MaxBytes) {
+		return dbtx.ErrQuotaExceeded
+	}
+	s.usage[in.Doc.OwnerEmail] = u
+	s.sagas[in.SagaId] = &in
+	return nil
+}
+
+func (s *sagaStore) GetUserUsage(_ context.Context, email string) (dbtx.Usage, error) {
+	return s.usage[email], nil
+}
+
+func (s *sagaStore) AdvanceUploadSaga(_ context.Context, sagaId, from, to string, doc dbtx.DocMeta) error {
+	saga := s.sagas[sagaId]
+	if saga.State != from {
+		return dbtx.ErrSagaStateConflict
+	}
+	if to == dbtx.SagaRolledBack || to == dbtx.SagaFailed {
+		u := s.usage[saga.Doc.OwnerEmail]
+		s.usage[saga.Doc.OwnerEmail] = dbtx.Usage{Docs: u.Docs - 1, Bytes: u.Bytes - saga.Doc.DocSize}
+	}
+	saga.State, saga.LastError = to, ""
+	if doc.DocId != "" {
+		saga.Doc.DocId = doc.DocId
+	}
+	if doc.BcTknId != "" {
+		saga.Doc.BcTknId = doc.BcTknId
+	}
+	return nil
+}
+
+func (s *sagaStore) FailUploadSagaStep(_ context.Context, sagaId string, stepErr error) error {
+	s.sagas[sagaId].Attempts++
+	s.sagas[sagaId].LastError = stepErr.Error()
+	return nil
+}
+
+func (s *sagaStore) SaveUploadSagaDoc(ctx context.Context, sagaId, from string, in dbtx.DocMeta) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	if err := s.AdvanceUploadSaga(ctx, sagaId, from, dbtx.SagaSaved, dbtx.DocMeta{}); err != nil {
+		return err
+	}
+	s.docs[in.DocMd5Hash] = in
+	return nil
+}
+
+func (s *sagaStore) GetDocMetaByHash(_ context.Context, docMd5Hash string) (dbtx.DocMeta, error) {
+	if s.lookupErr != nil {
+		return dbtx.DocMeta{}, s.lookupErr
+	}
+	doc, ok := s.docs[docMd5Hash]
+	if !ok {
+		return doc, sql.ErrNoRows
+	}
+	return doc, nil
+}
+
+func (s *sagaStore) GetDocMetaByTkn(_ context.Context, bcTknId string) (dbtx.DocMeta, error) {
+	for _, doc := range s.docs {
+		if doc.BcTknId == bcTknId {
+			return doc, nil
+		}
+	}
+	return dbtx.DocMeta{}, sql.ErrNoRows
+}
+
+func (s *sagaStore) ClaimStaleUploadSagas(_ context.Context, _ time.Duration, _ int) ([]dbtx.UploadSaga, error) {
+	var out []dbtx.UploadSaga
+	for _, saga := range s.sagas {
+		switch saga.State {
+		case dbtx.SagaReceived, dbtx.SagaStored, dbtx.SagaMinted:
+			out = append(out, *saga)
+		}
+	}
+	return out, nil
+}
+
+func (s *sagaStore) only(t *testing.T) *dbtx.UploadSaga {
+	require.Len(t, s.sagas, 1)
+	for _, saga := range s.sagas {
+		return saga
+	}
+	return nil
+}
+
+// fakeBc mints, confirms and verifies tkns unless mintErr, confirmErr or verifyErr is set. The validity
+// of minted tkns is kept in minted when it is set, and verified tkns have the given validity.
+type fakeBc struct {
+	mintErr    error
+	confirmErr error
+	verifyErr  error
+	minted     map[string]bc.Validity
+	validity   bc.Validity
+}
+
+func (b fakeBc) MintDocTkn(_ context.Context, docId, _, _ string, validity bc.Validity) (string, error) {
+	if b.minted != nil && b.mintErr == nil {
+		b.minted["tkn-"+docId] = validity
+	}
+	return "tkn-" + docId, b.mintErr
+}
+
+func (b fakeBc) ConfirmDocTkn(_ context.Context, _ string) error {
+	return b.confirmErr
+}
+
+func (b fakeBc) VerifyDocTkn(_ context.Context, _, _, _ string) (bc.Validity, error) {
+	return b.validity, b.verifyErr
+}
+
+func (b fakeBc) GetDocTkn(_ context.Context, _ string) (bc.Anchor, error) {
+	return bc.Anchor{Validity: b.validity}, b.verifyErr
+}
+
+func TestDocH_storeMintSave(t *testing.T) {
+	req := &rest.UploadReq{OwnerEmail: "john.doe@example.com", DocMd5Hash: "hash-1"}
+	doc := dbtx.DocMeta{OwnerEmail: req.OwnerEmail, DocMd5Hash: req.DocMd5Hash}
+	newSrc := func() *docSource {
+		return &docSource{name: "doc.txt", size: 7, open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("content")), nil
+		}}
+	}
+	recovery := &UploadRecovery{MaxAttempts: 2}
+
+	t.Run("saved", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{}
+		d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, Recovery: recovery}
+		got, err := d.storeMintSave(context.Background(), req, doc, newSrc())
+		require.NoError(t, err)
+		assert.Equal(t, "tkn-"+got.DocId, got.BcTknId)
+		assert.Equal(t, dbtx.SagaSaved, store.only(t).State)
+		assert.Contains(t, mb, got.DocId)
+		assert.Equal(t, got, store.docs["hash-1"])
+	})
+
+	t.Run("validity anchored with tkn", func(t *testing.T) {
+		until := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+		store, minted := newSagaStore(), make(map[string]bc.Validity)
+		d := &DocH{Db: store, Blob: memBlob{}, Bc: fakeBc{minted: minted}, Recovery: recovery}
+		valid := doc
+		valid.ValidUntil = &until
+		got, err := d.storeMintSave(context.Background(), req, valid, newSrc())
+		require.NoError(t, err)
+		assert.Equal(t, bc.Validity{Until: until}, minted[got.BcTknId])
+		assert.Equal(t, &until, store.docs["hash-1"].ValidUntil)
+	})
+
+	t.Run("stored doc deleted when minting fails", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{}
+		d := &DocH{Db: store, Blob: mb, Bc: fakeBc{mintErr: errors.New("node down")}, Recovery: recovery}
+		_, err := d.storeMintSave(context.Background(), req, doc, newSrc())
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, errStatus(err))
+		assert.Equal(t, dbtx.SagaRolledBack, store.only(t).State)
+		assert.Empty(t, mb)
+	})
+
+	t.Run("usage reserved in quota", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{}
+		d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, Recovery: recovery,
+			Policy: &policy.Policy{OwnerMaxDocs: 2, OwnerMaxBytes: 10}}
+		sized := doc
+		sized.DocSize = 7
+		_, err := d.storeMintSave(context.Background(), req, sized, newSrc())
+		require.NoError(t, err)
+		assert.Equal(t, dbtx.Usage{Docs: 1, Bytes: 7}, store.usage[req.OwnerEmail])
+
+		// a stored doc which does not fit is not kept
+		stored := newSrc()
+		stored.stored = "streamed-1"
+		mb["streamed-1"] = []byte("content")
+		sized.DocMd5Hash = "hash-2"
+		_, err = d.storeMintSave(context.Background(), req, sized, stored)
+		var v *policy.Violation
+		require.ErrorAs(t, err, &v)
+		assert.Equal(t, policy.CodeBytesQuotaExceeded, v.Code)
+		assert.Len(t, store.sagas, 1)
+		assert.NotContains(t, mb, "streamed-1")
+	})
+
+	t.Run("usage released when rolled back", func(t *testing.T) {
+		store := newSagaStore()
+		d := &DocH{Db: store, Blob: memBlob{}, Bc: fakeBc{mintErr: errors.New("node down")}, Recovery: recovery,
+			Policy: &policy.Policy{OwnerMaxDocs: 1}}
+		sized := doc
+		sized.DocSize = 7
+		_, err := d.storeMintSave(context.Background(), req, sized, newSrc())
+		require.Error(t, err)
+		assert.Equal(t, dbtx.SagaRolledBack, store.only(t).State)
+		assert.Equal(t, dbtx.Usage{}, store.usage[req.OwnerEmail])
+	})
+
+	t.Run("minted doc saved by recovery", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{}
+		store.saveErr = errors.New("db down")
+		d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, Recovery: recovery}
+		_, err := d.storeMintSave(context.Background(), req, doc, newSrc())
+		require.Error(t, err)
+		saga := store.only(t)
+		assert.Equal(t, dbtx.SagaMinted, saga.State)
+		assert.Equal(t, 1, saga.Attempts)
+		assert.Contains(t, mb, saga.Doc.DocId)
+
+		store.saveErr = nil
+		assert.Equal(t, 1, d.recoverUploads(context.Background()))
+		assert.Equal(t, dbtx.SagaSaved, saga.State)
+		assert.Equal(t, saga.Doc.BcTknId, store.docs["hash-1"].BcTknId)
+	})
+
+	t.Run("minted doc failed after max attempts", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{}
+		store.saveErr = errors.New("constraint violated")
+		d := &DocH{Db: store, Blob: mb, Bc: fakeBc{}, Recovery: recovery}
+		_, err := d.storeMintSave(context.Background(), req, doc, newSrc())
+		require.Error(t, err)
+
+		assert.Equal(t, 0, d.recoverUploads(context.Background()))
+		assert.Equal(t, 2, store.only(t).Attempts)
+		assert.Equal(t, 1, d.recoverUploads(context.Background()))
+		assert.Equal(t, dbtx.SagaFailed, store.only(t).State)
+	})
+}
+
+func TestDocH_recoverUpload(t *testing.T) {
+	recovery := &UploadRecovery{MaxAttempts: 3}
+
+	t.Run("stored doc rolled back", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{"doc-0": []byte("content")}
+		store.sagas["s1"] = &dbtx.UploadSaga{SagaId: "s1", State: dbtx.SagaStored, Doc: dbtx.DocMeta{DocId: "doc-0"}}
+		d := &DocH{Db: store, Blob: mb, Recovery: recovery}
+		require.NoError(t, d.recoverUpload(context.Background(), *store.sagas["s1"]))
+		assert.Equal(t, dbtx.SagaRolledBack, store.sagas["s1"].State)
+		assert.Empty(t, mb)
+	})
+
+	t.Run("minted doc rolled back when uploaded again", func(t *testing.T) {
+		store, mb := newSagaStore(), memBlob{"doc-0": []byte("content")}
+		store.docs["hash-1"] = dbtx.DocMeta{DocId: "doc-1", DocMd5Hash: "hash-1"}
+		store.sagas["s1"] = &dbtx.UploadSaga{SagaId: "s1", State: dbtx.SagaMinted,
+			Doc: dbtx.DocMeta{DocId: "doc-0", DocMd5Hash: "hash-1", BcTknId: "tkn-0"}}
+		d := &DocH{Db: store, Blob: mb, Recovery: recovery}
+		require.NoError(t, d.recoverUpload(context.Background(), *store.sagas["s1"]))
+		assert.Equal(t, dbtx.SagaRolledBack, store.sagas["s1"].State)
+		assert.Empty(t, mb)
+		assert.Equal(t, "doc-1", store.docs["hash-1"].DocId)
+	})
+}
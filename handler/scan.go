@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/vposham/trustdoc/internal/scan"
+)
+
+// quarantinePrefix is the blob store prefix under which documents streamed on receipt are kept until they
+// are found clean, so that malware never reaches the doc store
+const quarantinePrefix = "quarantine/"
+
+// scanSource scans a document for malware, unless it was scanned on receipt
+func (d *DocH) scanSource(ctx context.Context, src *docSource) error {
+	if d.Scanner == nil || src.scanned != nil {
+		return nil
+	}
+	f, err := src.open()
+	if err != nil {
+		return &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to open file - %w", err)}
+	}
+	defer func() { _ = f.Close() }()
+	res, err := d.Scanner.Scan(ctx, f)
+	if err = scanVerdict(res, err); err != nil {
+		return err
+	}
+	src.scanned = &res
+	return nil
+}
+
+// scanVerdict turns a malware scan into an upload pipeline error. Documents are not accepted
+// unless they are known to be clean, so scan failures fail the upload too.
+func scanVerdict(res scan.Result, err error) error {
+	if err != nil {
+		return &stepErr{http.StatusServiceUnavailable, fmt.Errorf("unable to scan for malware - %w", err)}
+	}
+	if res.Infected {
+		return &stepErr{http.StatusUnprocessableEntity, &scan.InfectedError{Signature: res.Signature}}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/log"
+)
+
+// Similarity configures the near-duplicate detection of image documents
+type Similarity struct {
+	H           hash.Hasher
+	MaxDistance int
+	MaxResults  int
+
+	// MaxSize is the largest image which is decoded for perceptual hashing
+	MaxSize int64
+}
+
+// findSimilar perceptually hashes image documents and looks up existing documents which are
+// visually similar, ignoring documents with exactly the same content hash.
+// Near-duplicate detection is best effort, so failures are only logged.
+func (d *DocH) findSimilar(ctx context.Context, src docSource,
+	docHash string) (pHash string, similar []dbtx.SimilarDoc) {
+	if d.Similar == nil || src.size > d.Similar.MaxSize {
+		return "", nil
+	}
+	logger := log.GetLogger(ctx)
+
+	f, err := src.open()
+	if err != nil {
+		logger.Warn("unable to open file for perceptual hash", zap.Error(err))
+		return "", nil
+	}
+	defer func() { _ = f.Close() }()
+
+	br := bufio.NewReader(f)
+	if !isImage(br) {
+		return "", nil
+	}
+	pHash, err = d.Similar.H.Hash(ctx, br)
+	if err != nil {
+		logger.Warn("unable to generate perceptual hash", zap.Error(err))
+		return "", nil
+	}
+	similar, err = d.Db.GetSimilarDocs(ctx, pHash, docHash, d.Similar.MaxDistance, d.Similar.MaxResults)
+	if err != nil {
+		logger.Warn("unable to look up similar documents", zap.Error(err))
+		return pHash, nil
+	}
+	if len(similar) > 0 {
+		logger.Info("visually similar documents found", zap.Any("similarDocs", similar))
+	}
+	return pHash, similar
+}
+
+// isImage sniffs the start of the content without consuming it
+func isImage(br *bufio.Reader) bool {
+	head, _ := br.Peek(512)
+	return strings.HasPrefix(http.DetectContentType(head), "image/")
+}
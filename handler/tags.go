@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// limits of the tags of a document
+const (
+	maxTags        = 50
+	maxTagValueLen = 256
+)
+
+// defaultSearchLimit is the max number of documents a search returns when no limit is asked for
+const defaultSearchLimit = 20
+
+// tagKeyRe matches tag keys such as issuer, courseId or employee.no
+var tagKeyRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,63}$`)
+
+// parseTags reads the tags of an upload request, sent as a json object of strings
+func parseTags(req *rest.UploadReq) error {
+	if strings.TrimSpace(req.Tags) == "" {
+		return checkTags(req.DocTags)
+	}
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(req.Tags), &tags); err != nil {
+		return fmt.Errorf("tags is not a json object of strings - %w", err)
+	}
+	req.DocTags = tags
+	return checkTags(tags)
+}
+
+// checkTags validates the keys and values of document tags
+func checkTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("doc can have at most %d tags", maxTags)
+	}
+	for k, v := range tags {
+		if !tagKeyRe.MatchString(k) {
+			return fmt.Errorf("invalid tag key %q", k)
+		}
+		if len(v) > maxTagValueLen {
+			return fmt.Errorf("tag %s is longer than %d characters", k, maxTagValueLen)
+		}
+	}
+	return nil
+}
+
+// tagFilters reads key:value tag filters into the tags documents need to have
+func tagFilters(filters []string) (map[string]string, error) {
+	tags := make(map[string]string, len(filters))
+	for _, f := range filters {
+		k, v, ok := strings.Cut(f, ":")
+		if !ok {
+			return nil, fmt.Errorf("tag filter %q is not key:value", f)
+		}
+		tags[k] = v
+	}
+	return tags, checkTags(tags)
+}
+
+// Search finds the documents of an owner by their tags, latest first. Anyone may search the documents of
+// an owner, so the owner details are always redacted.
+func (d *DocH) Search(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("search request received")
+
+	var req rest.SearchReq
+	err := c.ShouldBindUri(&req)
+	if err == nil {
+		err = c.ShouldBindQuery(&req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rest.SearchResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	tags, err := tagFilters(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rest.SearchResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+
+	docs, err := d.Db.SearchDocs(c, req.Email, tags, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError,
+			rest.SearchResp{Error: fmt.Errorf("unable to search docs in db - %w", err).Error()})
+		return
+	}
+	logger.Info("search completed", zap.Int("tags", len(tags)), zap.Int("docs", len(docs)))
+	resp := rest.SearchResp{Email: req.Email, Docs: docs}
+	for i := range resp.Docs {
+		resp.Redacted = redactOwner(&resp.Docs[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+func Test_parseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "no tags"},
+		{name: "tags", tags: `{"issuer":"acme","courseId":"cs-101"}`,
+			want: map[string]string{"issuer": "acme", "courseId": "cs-101"}},
+		{name: "not an object", tags: `["acme"]`, wantErr: true},
+		{name: "not strings", tags: `{"employeeNo":42}`, wantErr: true},
+		{name: "invalid key", tags: `{"employee no":"42"}`, wantErr: true},
+		{name: "long value", tags: `{"issuer":"` + strings.Repeat("a", maxTagValueLen+1) + `"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &rest.UploadReq{Tags: tt.tags}
+			err := parseTags(req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, req.DocTags)
+		})
+	}
+}
+
+// searchStore finds docs of an owner which have all the searched tags
+type searchStore struct {
+	dbtx.MockStore
+	docs []dbtx.DocMeta
+}
+
+func (s *searchStore) SearchDocs(_ context.Context, ownerEmail string, tags map[string]string,
+	maxResults int) ([]dbtx.DocMeta, error) {
+	out := []dbtx.DocMeta{}
+	for _, doc := range s.docs {
+		match := doc.OwnerEmail == ownerEmail
+		for k, v := range tags {
+			match = match && doc.Tags[k] == v
+		}
+		if match && len(out) < maxResults {
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+func TestDocH_Search(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := &DocH{Db: &searchStore{docs: []dbtx.DocMeta{
+		{DocId: "doc-1", OwnerEmail: "john.doe@example.com", OwnerFirstName: "John", OwnerLastName: "Doe",
+			Tags: map[string]string{"issuer": "acme", "year": "2024"}},
+		{DocId: "doc-2", OwnerEmail: "john.doe@example.com", OwnerFirstName: "John", OwnerLastName: "Doe",
+			Tags: map[string]string{"issuer": "acme", "year": "2025"}},
+		{DocId: "doc-3", OwnerEmail: "jane.roe@example.com", Tags: map[string]string{"issuer": "acme"}},
+		{DocId: "doc-4", OwnerEmail: "john.doe@example.com"},
+	}}}
+	search := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/svc/v1/users/john.doe@example.com/docs?"+query, nil)
+		c.Params = gin.Params{{Key: "email", Value: "john.doe@example.com"}}
+		d.Search(c)
+		return w
+	}
+
+	w := search("tag=issuer:acme&tag=year:2025")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"docId":"doc-2"`)
+	assert.NotContains(t, w.Body.String(), `"docId":"doc-1"`)
+
+	// the owner details are redacted for anyone searching
+	var resp rest.SearchResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Docs, 1)
+	assert.Equal(t, maskEmail("john.doe@example.com"), resp.Docs[0].OwnerEmail)
+	assert.Empty(t, resp.Docs[0].OwnerFirstName)
+	assert.Empty(t, resp.Docs[0].OwnerLastName)
+	assert.Equal(t, []string{"ownerEmail", "ownerFirstName", "ownerLastName"}, resp.Redacted)
+	assert.NotContains(t, w.Body.String(), "Doe")
+
+	w = search("tag=issuer:acme&limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"docId"`))
+
+	// without tag filters every doc of the owner is found, the ones without tags too
+	w = search("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, strings.Count(w.Body.String(), `"docId"`))
+	assert.Contains(t, w.Body.String(), `"docId":"doc-4"`)
+
+	w = search("tag=issuer")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = search("limit=1000")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
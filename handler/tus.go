@@ -0,0 +1,464 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// tus 1.0 protocol headers, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion        = "1.0.0"
+	tusExtensions     = "creation,termination"
+	tusResumableHdr   = "Tus-Resumable"
+	tusVersionHdr     = "Tus-Version"
+	tusExtensionHdr   = "Tus-Extension"
+	tusMaxSizeHdr     = "Tus-Max-Size"
+	uploadLengthHdr   = "Upload-Length"
+	uploadOffsetHdr   = "Upload-Offset"
+	uploadMetadataHdr = "Upload-Metadata"
+	tusPatchMediaType = "application/offset+octet-stream"
+
+	// docIdHdr carries the docId of the document created by a completed upload
+	docIdHdr = "Trustdoc-Doc-Id"
+
+	// tusPartPrefix is the blob store prefix under which received parts are kept until completion
+	tusPartPrefix = "tus/"
+)
+
+// TusOptions advertises the tus protocol version and extensions supported by the server
+func (d *DocH) TusOptions(c *gin.Context) {
+	c.Header(tusResumableHdr, tusVersion)
+	c.Header(tusVersionHdr, tusVersion)
+	c.Header(tusExtensionHdr, tusExtensions)
+	c.Header(tusMaxSizeHdr, strconv.FormatInt(d.TusMaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate creates a new resumable upload. The document metadata, which is otherwise sent as
+// form fields to Upload, is sent in the Upload-Metadata header.
+func (d *DocH) TusCreate(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("resumable upload creation request received")
+	if !tusResumable(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader(uploadLengthHdr), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, uploadResp(nil, errors.New("req validation failed - invalid Upload-Length")))
+		return
+	}
+	if length > d.TusMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge,
+			uploadResp(nil, fmt.Errorf("upload length exceeds max size of %d bytes", d.TusMaxSize)))
+		return
+	}
+	meta, err := parseTusMetadata(c.GetHeader(uploadMetadataHdr))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("req validation failed - %w", err)))
+		return
+	}
+	req := tusUploadReq(meta)
+	if err = binding.Validator.ValidateStruct(&req); err == nil {
+		err = parseDocMeta(&req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("req validation failed - %w", err)))
+		return
+	}
+	if meta["filename"] == "" {
+		c.JSON(http.StatusBadRequest, uploadResp(nil, errors.New("req validation failed - filename is required")))
+		return
+	}
+
+	// a retried creation gets the location of the upload created by the first request
+	fingerprint := c.GetHeader(uploadLengthHdr) + "\n" + c.GetHeader(uploadMetadataHdr)
+	d.idempotent(c, req.OwnerEmail, fingerprint, func(err error) any { return uploadResp(nil, err) }, func() {
+		// fail fast on uploads against the upload policy, the type is checked once the content is received
+		if d.Policy != nil {
+			if err = d.Policy.CheckSize("", length); err == nil {
+				err = d.checkQuota(c, req.OwnerEmail, length)
+			}
+			if err != nil {
+				c.JSON(errStatus(err), uploadResp(nil, err))
+				return
+			}
+		}
+
+		u := dbtx.TusUpload{UploadId: uuid.New().String(), Length: length, Meta: meta}
+		if err = d.Db.CreateTusUpload(c, u); err != nil {
+			c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to persist to db - %w", err)))
+			return
+		}
+		logger.Info("resumable upload created", zap.String("uploadId", u.UploadId), zap.Int64("uploadLength", length))
+		c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+u.UploadId)
+		c.Status(http.StatusCreated)
+	})
+}
+
+// TusHead reports how many bytes of a resumable upload were received
+func (d *DocH) TusHead(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	u, ok := d.tusUpload(c)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header(uploadOffsetHdr, strconv.FormatInt(u.Offset, 10))
+	c.Header(uploadLengthHdr, strconv.FormatInt(u.Length, 10))
+	if u.DocId != "" {
+		c.Header(docIdHdr, u.DocId)
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusPatch receives the next part of a resumable upload. The part is stored as its own object
+// in blob store, and once all bytes are received, the parts are composed to the document, which goes
+// through the upload pipeline.
+func (d *DocH) TusPatch(c *gin.Context) {
+	logger := log.GetLogger(c)
+	if !tusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusPatchMediaType {
+		c.JSON(http.StatusUnsupportedMediaType,
+			uploadResp(nil, fmt.Errorf("content type must be %s", tusPatchMediaType)))
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHdr), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, uploadResp(nil, errors.New("req validation failed - invalid Upload-Offset")))
+		return
+	}
+	u, ok := d.tusUpload(c)
+	if !ok {
+		return
+	}
+	if u.Offset != offset {
+		c.JSON(http.StatusConflict,
+			uploadResp(nil, fmt.Errorf("upload is at offset %d, not %d", u.Offset, offset)))
+		return
+	}
+	if u.Status == dbtx.TusCompleted {
+		c.Header(uploadOffsetHdr, strconv.FormatInt(u.Offset, 10))
+		c.Header(docIdHdr, u.DocId)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if u.Offset < u.Length {
+		if u, ok = d.tusPart(c, u); !ok {
+			return
+		}
+	}
+	logger.Info("resumable upload progressed", zap.String("uploadId", u.UploadId),
+		zap.Int64("uploadOffset", u.Offset), zap.Int64("uploadLength", u.Length))
+
+	// the upload is finished once all bytes are received, an empty PATCH at the end retries it
+	if u.Offset == u.Length {
+		docId, err := d.finishTus(c, u.UploadId)
+		if err != nil {
+			c.JSON(errStatus(err), uploadResp(nil, err))
+			return
+		}
+		c.Header(docIdHdr, docId)
+	}
+	c.Header(uploadOffsetHdr, strconv.FormatInt(u.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusDelete terminates a resumable upload and removes the parts received so far
+func (d *DocH) TusDelete(c *gin.Context) {
+	logger := log.GetLogger(c)
+	if !tusResumable(c) {
+		return
+	}
+	u, ok := d.tusUpload(c)
+	if !ok {
+		return
+	}
+	if u.Status == dbtx.TusFinishing {
+		c.JSON(http.StatusConflict, uploadResp(nil, errors.New("upload is being finished")))
+		return
+	}
+	if u.Status == dbtx.TusCreated {
+		d.deleteTusParts(c, u.UploadId)
+	}
+	if err := d.Db.FinishTusUpload(c, u.UploadId, dbtx.TusTerminated, u.DocId); err != nil {
+		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to persist to db - %w", err)))
+		return
+	}
+	logger.Info("resumable upload terminated", zap.String("uploadId", u.UploadId))
+	c.Status(http.StatusNoContent)
+}
+
+// tusPart streams the request body into a new part object and moves the upload offset past it.
+// Parts without a Content-Length are streamed to blob store in parts of their own.
+func (d *DocH) tusPart(c *gin.Context, u dbtx.TusUpload) (dbtx.TusUpload, bool) {
+	remaining := u.Length - u.Offset
+	size := c.Request.ContentLength
+	if size > remaining {
+		c.JSON(http.StatusBadRequest,
+			uploadResp(nil, fmt.Errorf("part of %d bytes exceeds the remaining %d bytes", size, remaining)))
+		return u, false
+	}
+	if size == 0 {
+		return u, true
+	}
+
+	part := dbtx.TusUploadPart{
+		Offset:  u.Offset,
+		ObjName: fmt.Sprintf("%s%s/%d-%s", tusPartPrefix, u.UploadId, u.Offset, uuid.New().String()),
+	}
+	// read one byte past the remaining ones to find parts which are too large
+	body := &countingReader{r: io.LimitReader(c.Request.Body, remaining+1)}
+	if err := d.Blob.PutAt(c, part.ObjName, body, size); err != nil {
+		if body.err != nil {
+			c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("unable to read part - %w", body.err)))
+			return u, false
+		}
+		c.JSON(http.StatusInternalServerError,
+			uploadResp(nil, fmt.Errorf("unable to store in blob store - %w", err)))
+		return u, false
+	}
+	part.Size = body.n
+	if part.Size == 0 || part.Size > remaining {
+		_ = d.Blob.Delete(c, part.ObjName)
+		if part.Size == 0 {
+			return u, true
+		}
+		c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("part exceeds the remaining %d bytes", remaining)))
+		return u, false
+	}
+
+	next, err := d.Db.AddTusUploadPart(c, u.UploadId, part)
+	if err != nil {
+		_ = d.Blob.Delete(c, part.ObjName)
+		if errors.Is(err, dbtx.ErrTusOffsetConflict) {
+			c.JSON(http.StatusConflict, uploadResp(nil, fmt.Errorf("upload is no longer at offset %d", u.Offset)))
+			return u, false
+		}
+		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to persist to db - %w", err)))
+		return u, false
+	}
+	return next, true
+}
+
+// finishTus claims a fully received upload and runs it through the same pipeline as Upload. Retries of
+// the last part sent while the upload is being finished get a conflict, and the doc once it is finished.
+func (d *DocH) finishTus(ctx context.Context, uploadId string) (string, error) {
+	u, err := d.Db.ClaimTusUpload(ctx, uploadId, d.FinishTimeout)
+	if errors.Is(err, dbtx.ErrTusUploadNotReceived) {
+		done, e := d.Db.GetTusUpload(ctx, uploadId)
+		if e == nil && done.Status == dbtx.TusCompleted {
+			return done.DocId, nil
+		}
+		return "", &stepErr{http.StatusConflict, errors.New("upload is being finished")}
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to persist to db - %w", err)
+	}
+
+	doc, err := d.ingestTus(ctx, u)
+	if err != nil {
+		// the upload can be finished again with an empty PATCH
+		if e := d.Db.FinishTusUpload(ctx, u.UploadId, dbtx.TusCreated, ""); e != nil {
+			log.GetLogger(ctx).Error("unable to release tus upload", zap.String("uploadId", u.UploadId), zap.Error(e))
+		}
+		return "", err
+	}
+	return doc.DocId, nil
+}
+
+// ingestTus hashes, mints and saves the doc of a claimed upload and removes its parts. Parts large enough to
+// be composed are composed to the doc in blob store, so that the doc does not leave blob store to be stored.
+// Smaller ones are read back to back and stored by the pipeline instead.
+func (d *DocH) ingestTus(ctx context.Context, u dbtx.TusUpload) (*dbtx.DocMeta, error) {
+	parts, err := d.Db.GetTusUploadParts(ctx, u.UploadId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find upload parts in db - %w", err)
+	}
+	req := tusUploadReq(u.Meta)
+	if err = parseDocMeta(&req); err != nil {
+		return nil, &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	}
+	src := docSource{
+		name: u.Meta["filename"],
+		size: u.Length,
+		open: func() (io.ReadCloser, error) {
+			return &partsReader{ctx: ctx, blob: d.Blob, parts: parts}, nil
+		},
+	}
+	if err = d.hashReq(ctx, &req, &src); err != nil {
+		return nil, err
+	}
+	if objNames, ok := composable(parts); ok {
+		// the parts are scanned before they are composed, so that malware never reaches the doc store
+		if err = d.scanSource(ctx, &src); err != nil {
+			return nil, err
+		}
+		if err = d.storeStaged(ctx, &src, objNames); err != nil {
+			return nil, err
+		}
+	}
+	res, err := d.ingest(ctx, &req, src, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.Db.FinishTusUpload(ctx, u.UploadId, dbtx.TusCompleted, res.doc.DocId); err != nil {
+		return nil, fmt.Errorf("unable to persist to db - %w", err)
+	}
+	d.deleteTusParts(ctx, u.UploadId)
+	return &res.doc, nil
+}
+
+// composable returns the objects of the parts of an upload, when they are large enough to be composed
+func composable(parts []dbtx.TusUploadPart) ([]string, bool) {
+	objNames := make([]string, 0, len(parts))
+	for i, p := range parts {
+		if i < len(parts)-1 && p.Size < blob.MinComposePartSize {
+			return nil, false
+		}
+		objNames = append(objNames, p.ObjName)
+	}
+	return objNames, len(objNames) > 0
+}
+
+// tusUpload loads the upload addressed by the request, responding 404 for unknown or terminated uploads
+func (d *DocH) tusUpload(c *gin.Context) (dbtx.TusUpload, bool) {
+	var req rest.TusUploadReq
+	if err := c.BindUri(&req); err != nil {
+		c.JSON(http.StatusNotFound, uploadResp(nil, fmt.Errorf("upload not found - %w", err)))
+		return dbtx.TusUpload{}, false
+	}
+	u, err := d.Db.GetTusUpload(c, req.UploadId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && u.Status == dbtx.TusTerminated) {
+		c.JSON(http.StatusNotFound, uploadResp(nil, errors.New("upload not found")))
+		return u, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to find upload in db - %w", err)))
+		return u, false
+	}
+	return u, true
+}
+
+// deleteTusParts removes the part objects of an upload, leftovers are only logged
+func (d *DocH) deleteTusParts(ctx context.Context, uploadId string) {
+	logger := log.GetLogger(ctx)
+	parts, err := d.Db.GetTusUploadParts(ctx, uploadId)
+	if err != nil {
+		logger.Warn("unable to find upload parts to delete", zap.String("uploadId", uploadId), zap.Error(err))
+		return
+	}
+	for _, p := range parts {
+		if err = d.Blob.Delete(ctx, p.ObjName); err != nil {
+			logger.Warn("unable to delete upload part", zap.String("objName", p.ObjName), zap.Error(err))
+		}
+	}
+}
+
+// tusResumable rejects requests for a tus version other than the supported one
+func tusResumable(c *gin.Context) bool {
+	c.Header(tusResumableHdr, tusVersion)
+	if c.GetHeader(tusResumableHdr) != tusVersion {
+		c.Header(tusVersionHdr, tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseTusMetadata decodes the Upload-Metadata header, a comma separated list of
+// key and base64 encoded value pairs separated by a space
+func parseTusMetadata(h string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(h, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, " ")
+		val, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q - %w", k, err)
+		}
+		meta[k] = string(val)
+	}
+	return meta, nil
+}
+
+func tusUploadReq(meta map[string]string) rest.UploadReq {
+	return rest.UploadReq{
+		OwnerEmail:     meta["ownerEmail"],
+		DocTitle:       meta["docTitle"],
+		DocDesc:        meta["docDesc"],
+		OwnerFirstName: meta["ownerFirstName"],
+		OwnerLastName:  meta["ownerLastName"],
+		Tags:           meta["tags"],
+		ValidFrom:      meta["validFrom"],
+		ValidUntil:     meta["validUntil"],
+	}
+}
+
+// partsReader reads the parts of a resumable upload back to back. A part is only fetched
+// from blob store once the previous one is fully read.
+type partsReader struct {
+	ctx   context.Context
+	blob  blob.OpsIf
+	parts []dbtx.TusUploadPart
+	cur   io.Reader
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur == nil {
+			if len(p.parts) == 0 {
+				return 0, io.EOF
+			}
+			r, err := p.blob.Get(p.ctx, p.parts[0].ObjName, nil)
+			if err != nil {
+				return 0, err
+			}
+			p.cur, p.parts = r, p.parts[1:]
+		}
+		n, err := p.cur.Read(b)
+		if errors.Is(err, io.EOF) {
+			_ = p.Close()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close closes the part being read
+func (p *partsReader) Close() error {
+	var err error
+	if c, ok := p.cur.(io.Closer); ok {
+		err = c.Close()
+	}
+	p.cur = nil
+	return err
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+)
+
+// tusStore keeps resumable uploads in memory along with the upload sagas and docs of sagaStore
+type tusStore struct {
+	*sagaStore
+	uploads map[string]*dbtx.TusUpload
+	parts   map[string][]dbtx.TusUploadPart
+}
+
+func (s *tusStore) GetTusUpload(_ context.Context, uploadId string) (dbtx.TusUpload, error) {
+	return *s.uploads[uploadId], nil
+}
+
+func (s *tusStore) GetTusUploadParts(_ context.Context, uploadId string) ([]dbtx.TusUploadPart, error) {
+	return s.parts[uploadId], nil
+}
+
+func (s *tusStore) ClaimTusUpload(_ context.Context, uploadId string, _ time.Duration) (dbtx.TusUpload, error) {
+	u := s.uploads[uploadId]
+	if u.Status != dbtx.TusCreated || u.Offset != u.Length {
+		return dbtx.TusUpload{}, dbtx.ErrTusUploadNotReceived
+	}
+	u.Status = dbtx.TusFinishing
+	return *u, nil
+}
+
+func (s *tusStore) FinishTusUpload(_ context.Context, uploadId, status, docId string) error {
+	s.uploads[uploadId].Status, s.uploads[uploadId].DocId = status, docId
+	return nil
+}
+
+// memBlob is an in memory blob store
+type memBlob map[string][]byte
+
+func (m memBlob) Put(_ context.Context, doc io.Reader, _ int64, _ blob.ObjMeta) (string, error) {
+	docId := fmt.Sprintf("doc-%d", len(m))
+	b, err := io.ReadAll(doc)
+	m[docId] = b
+	return docId, err
+}
+
+func (m memBlob) Get(_ context.Context, docId string, rng *blob.ByteRange) (io.Reader, error) {
+	b, ok := m[docId]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	if rng != nil {
+		b = b[rng.Start : rng.End+1]
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (m memBlob) Stat(_ context.Context, docId string) (blob.ObjInfo, error) {
+	b, ok := m[docId]
+	if !ok {
+		return blob.ObjInfo{}, blob.ErrNotFound
+	}
+	return blob.ObjInfo{Size: int64(len(b))}, nil
+}
+
+func (m memBlob) PutAt(_ context.Context, objName string, obj io.Reader, _ int64) error {
+	b, err := io.ReadAll(obj)
+	m[objName] = b
+	return err
+}
+
+func (m memBlob) Delete(_ context.Context, objName string) error {
+	delete(m, objName)
+	return nil
+}
+
+func (m memBlob) Compose(_ context.Context, objNames []string) (string, error) {
+	var doc []byte
+	for _, objName := range objNames {
+		b, ok := m[objName]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		doc = append(doc, b...)
+	}
+	docId := fmt.Sprintf("doc-%d", len(m))
+	m[docId] = doc
+	return docId, nil
+}
+
+func (m memBlob) PresignGet(_ context.Context, docId string, expiry time.Duration, meta blob.ObjMeta) (string, error) {
+	return fmt.Sprintf("mem://%s?expiry=%s&type=%s", docId, expiry, meta.ContentType), nil
+}
+
+func (m memBlob) PresignPut(_ context.Context, objName string, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("mem://%s?expiry=%s", objName, expiry), nil
+}
+
+func TestDocH_finishTus(t *testing.T) {
+	ctx := context.Background()
+	meta := map[string]string{"ownerEmail": "john.doe@example.com", "docTitle": "lease", "ownerFirstName": "john",
+		"ownerLastName": "doe", "filename": "lease.txt"}
+	received := func(store *tusStore, mb memBlob, uploadId string, parts ...[]byte) {
+		u := &dbtx.TusUpload{UploadId: uploadId, Meta: meta, Status: dbtx.TusCreated}
+		for i, p := range parts {
+			objName := fmt.Sprintf("%s%s/%d", tusPartPrefix, uploadId, i)
+			mb[objName] = p
+			store.parts[uploadId] = append(store.parts[uploadId],
+				dbtx.TusUploadPart{Offset: u.Length, Size: int64(len(p)), ObjName: objName})
+			u.Length += int64(len(p))
+		}
+		u.Offset = u.Length
+		store.uploads[uploadId] = u
+	}
+	newDocH := func() (*DocH, *tusStore, memBlob) {
+		store := &tusStore{sagaStore: newSagaStore(), uploads: make(map[string]*dbtx.TusUpload),
+			parts: make(map[string][]dbtx.TusUploadPart)}
+		mb := memBlob{}
+		return &DocH{Db: store, Blob: mb, Bc: fakeBc{}, H: hash.Md5{}}, store, mb
+	}
+
+	t.Run("parts composed in blob store", func(t *testing.T) {
+		d, store, mb := newDocH()
+		first := bytes.Repeat([]byte("a"), blob.MinComposePartSize)
+		received(store, mb, "up-1", first, []byte("tail"))
+		docId, err := d.finishTus(ctx, "up-1")
+		require.NoError(t, err)
+		assert.Equal(t, append(bytes.Clone(first), "tail"...), mb[docId])
+		assert.Len(t, mb, 1, "parts are removed")
+		assert.Equal(t, dbtx.TusCompleted, store.uploads["up-1"].Status)
+
+		// a retried last part gets the same doc
+		again, err := d.finishTus(ctx, "up-1")
+		require.NoError(t, err)
+		assert.Equal(t, docId, again)
+	})
+
+	t.Run("parts too small to compose are streamed", func(t *testing.T) {
+		d, store, mb := newDocH()
+		received(store, mb, "up-1", []byte("con"), []byte("tent"))
+		docId, err := d.finishTus(ctx, "up-1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("content"), mb[docId])
+		assert.Equal(t, "9a0364b9e99bb480dd25e1f0284c8555", store.docs["9a0364b9e99bb480dd25e1f0284c8555"].DocMd5Hash)
+	})
+
+	t.Run("upload being finished", func(t *testing.T) {
+		d, store, mb := newDocH()
+		received(store, mb, "up-1", []byte("content"))
+		store.uploads["up-1"].Status = dbtx.TusFinishing
+		_, err := d.finishTus(ctx, "up-1")
+		assert.Equal(t, http.StatusConflict, errStatus(err))
+		assert.Empty(t, store.docs)
+	})
+
+	t.Run("upload released when finishing fails", func(t *testing.T) {
+		d, store, mb := newDocH()
+		d.Bc = fakeBc{mintErr: errors.New("node down")}
+		received(store, mb, "up-1", []byte("content"))
+		_, err := d.finishTus(ctx, "up-1")
+		require.Error(t, err)
+		assert.Equal(t, dbtx.TusCreated, store.uploads["up-1"].Status)
+		assert.Contains(t, mb, tusPartPrefix+"up-1/0", "parts are kept for a retry")
+	})
+}
+
+func Test_parseTusMetadata(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	got, err := parseTusMetadata("filename " + enc("a b.pdf") + ", ownerEmail " + enc("x@y.com") + ",is_final")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "a b.pdf", "ownerEmail": "x@y.com", "is_final": ""}, got)
+
+	got, err = parseTusMetadata("")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = parseTusMetadata("filename !!!")
+	assert.Error(t, err)
+}
+
+func Test_partsReader(t *testing.T) {
+	ctx := context.Background()
+	mb := memBlob{"p0": []byte("hello "), "p1": []byte("resumable "), "p2": []byte("world")}
+	parts := []dbtx.TusUploadPart{{ObjName: "p0"}, {ObjName: "p1"}, {ObjName: "p2"}}
+
+	got, err := io.ReadAll(&partsReader{ctx: ctx, blob: mb, parts: parts})
+	require.NoError(t, err)
+	assert.Equal(t, "hello resumable world", string(got))
+
+	_, err = io.ReadAll(&partsReader{ctx: ctx, blob: mb, parts: []dbtx.TusUploadPart{{ObjName: "missing"}}})
+	assert.Error(t, err)
+}
@@ -1,121 +1,264 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 
 	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/internal/scan"
 	"github.com/vposham/trustdoc/log"
 	"github.com/vposham/trustdoc/pkg/rest"
 )
 
+// maxFormValueSize is the largest form field value accepted along with an uploaded doc
+const maxFormValueSize = 1 << 20
+
 func (d *DocH) Upload(c *gin.Context) {
 
 	logger := log.GetLogger(c)
 	logger.Info("upload request received")
 
-	// parse the request
-	req, err := d.uploadReq(c)
+	// asynchronous uploads are accepted once the doc is stored, and are finished as an upload job
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("req validation failed - %w", err)))
+		c.JSON(http.StatusBadRequest, uploadResp(nil, fmt.Errorf("req validation failed - invalid async - %w", err)))
 		return
 	}
 
-	var exists bool
-	doc, err := d.Db.GetDocMetaByHash(c, req.DocMd5Hash)
-	if err == nil {
-		exists = true
-	} else {
-		if !errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to find doc in db - %w", err)))
+	// parse the request, streaming the doc to blob store as it is received
+	req, src, err := d.uploadReq(c)
+	if err != nil {
+		c.JSON(errStatus(err), uploadResp(nil, err))
+		return
+	}
+
+	// a retried upload is recognised by its content, so retries can only be told apart once it is received
+	fingerprint := strings.Join([]string{req.OwnerEmail, req.DocTitle, req.DocDesc, req.OwnerFirstName,
+		req.OwnerLastName, req.Tags, req.ValidFrom, req.ValidUntil, src.name, req.DocMd5Hash,
+		strconv.FormatBool(async)}, "\n")
+	ran := d.idempotent(c, req.OwnerEmail, fingerprint, func(err error) any { return uploadResp(nil, err) }, func() {
+		res, err := d.ingest(c, req, src, async)
+		if err != nil {
+			c.JSON(errStatus(err), uploadResp(nil, err))
 			return
 		}
-		exists = false
+
+		resp := uploadResp(&res.doc, nil)
+		resp.SimilarTo = res.similar
+		if res.finish == nil {
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		go res.finish(detach(c))
+		resp.JobId = res.jobId
+		c.Header("Location", jobLocation(c, res.jobId))
+		c.JSON(http.StatusAccepted, resp)
+	})
+	if !ran {
+		d.deleteBlob(c, src.stored)
 	}
-	logger.Info("doc exists check", zap.String("docId", doc.DocId), zap.Bool("docExists", exists))
+}
 
-	if exists {
-		c.JSON(http.StatusOK, uploadResp(&doc, nil))
-		return
+// uploadReq reads the multipart form part by part. The doc part is streamed to blob store
+// and hashed on the way, so it is never spooled to memory or disk. Form fields can be sent
+// before or after the doc part.
+func (d *DocH) uploadReq(c *gin.Context) (*rest.UploadReq, docSource, error) {
+	var req rest.UploadReq
+	var src docSource
+
+	invalid := func(err error) (*rest.UploadReq, docSource, error) {
+		if src.stored != "" {
+			d.deleteBlob(c, src.stored)
+		}
+		return &req, docSource{}, &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
 	}
 
-	// store the file in blob store
-	f, _ := req.MpFileHeader.Open()
-	docId, err := d.Blob.Put(c, f, req.MpFileHeader.Size)
+	mr, err := c.Request.MultipartReader()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError,
-			uploadResp(nil, fmt.Errorf("unable to store in blob store - %w", err)))
-		return
+		return invalid(fmt.Errorf("unable to parse req - %w", err))
 	}
+	form := make(map[string][]string)
+	var hashed rest.UploadReq
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return invalid(fmt.Errorf("unable to parse req - %w", err))
+		}
 
-	// mint a new tkn in blockchain
-	bcTknId, err := d.Bc.MintDocTkn(c, docId, req.DocMd5Hash, req.OwnerEmailMd5Hash)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to sign in blockchain - %w", err)))
-		return
+		if part.FormName() == "doc" {
+			if src.stored != "" {
+				return invalid(errors.New("only one doc can be uploaded"))
+			}
+			src, err = d.streamDoc(c, part, part.FileName(), &hashed)
+			if err != nil {
+				return &req, docSource{}, err
+			}
+			continue
+		}
+
+		v, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+		if err != nil {
+			return invalid(fmt.Errorf("unable to parse req - %w", err))
+		}
+		if len(v) > maxFormValueSize {
+			return invalid(fmt.Errorf("form field %s is too large", part.FormName()))
+		}
+		form[part.FormName()] = append(form[part.FormName()], string(v))
+	}
+	if src.stored == "" {
+		return invalid(errors.New("unable to read file - doc is required"))
 	}
 
-	doc = dbtx.DocMeta{
-		DocId:          docId,
-		OwnerEmail:     req.OwnerEmail,
-		DocTitle:       req.DocTitle,
-		DocDesc:        req.DocDesc,
-		DocMd5Hash:     req.DocMd5Hash,
-		BcTknId:        bcTknId,
-		DocName:        req.MpFileHeader.Filename,
-		OwnerFirstName: req.OwnerFirstName,
-		OwnerLastName:  req.OwnerLastName,
+	if err = binding.MapFormWithTag(&req, form, "form"); err != nil {
+		return invalid(fmt.Errorf("unable to parse req - %w", err))
 	}
+	if err = binding.Validator.ValidateStruct(&req); err != nil {
+		return invalid(fmt.Errorf("unable to parse req - %w", err))
+	}
+	if err = parseDocMeta(&req); err != nil {
+		return invalid(err)
+	}
+	req.DocMd5Hash, req.DocHashAlgo = hashed.DocMd5Hash, hashed.DocHashAlgo
 
-	// store the metadata in db
-	err = d.Db.SaveDocMeta(c, doc)
+	req.OwnerEmailMd5Hash, err = d.H.Hash(c, strings.NewReader(req.OwnerEmail))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, uploadResp(nil, fmt.Errorf("unable to persist to db - %w", err)))
-		return
+		d.deleteBlob(c, src.stored)
+		return &req, docSource{}, fmt.Errorf("unable to generate hash. emailHashErr - %w", err)
 	}
-
-	c.JSON(http.StatusOK, uploadResp(&doc, nil))
+	return &req, src, nil
 }
 
-func (d *DocH) uploadReq(c *gin.Context) (*rest.UploadReq, error) {
-	var req rest.UploadReq
+// streamDoc puts a doc of unknown size in blob store while hashing it into req, and scanning it
+// for malware. Scanned docs are put in quarantine and only stored once they are found clean.
+// A client which fails to send the doc gets a bad request instead of a blob store error.
+func (d *DocH) streamDoc(ctx context.Context, in io.Reader, name string, req *rest.UploadReq) (docSource, error) {
+	var (
+		scanned scan.Result
+		tree    *hash.Tree
+	)
+	consumers := []func(io.Reader) error{
+		func(r io.Reader) (err error) {
+			tree, err = d.hashDoc(ctx, r, req)
+			return err
+		},
+	}
+	if d.Scanner != nil {
+		consumers = append(consumers, func(r io.Reader) (err error) {
+			scanned, err = d.Scanner.Scan(ctx, r)
+			return err
+		})
+	}
+	fo := newFanOut(consumers...)
 
-	err := c.Bind(&req)
+	cr := &countingReader{r: in}
+	mimeType, sniffed, err := sniffMime(io.TeeReader(cr, fo))
 	if err != nil {
-		return &req, fmt.Errorf("unable to parse req - %w", err)
+		fo.finish(err)
+		return docSource{}, &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - %w", err)}
+	}
+	// fail fast on documents against the upload policy, quotas are checked once the owner is known
+	if d.Policy != nil {
+		if err = d.Policy.CheckType(mimeType); err != nil {
+			fo.finish(err)
+			return docSource{}, err
+		}
+	}
+	// read one byte past the max size to find documents which are too large
+	maxSize := d.maxSize(mimeType)
+	if maxSize > 0 {
+		sniffed = io.LimitReader(sniffed, maxSize+1)
 	}
 
-	// Get the file from the request
-	req.MpFileHeader, err = c.FormFile("doc")
-	if err != nil {
-		return &req, fmt.Errorf("unable to read file - %w", err)
+	src := docSource{name: name, mimeType: mimeType}
+	var docId string
+	if d.Scanner != nil {
+		docId = quarantinePrefix + uuid.New().String()
+		err = d.Blob.PutAt(ctx, docId, sniffed, -1)
+	} else {
+		docId, err = d.Blob.Put(ctx, sniffed, -1, objMeta(src))
 	}
+	errs := fo.finish(err)
 
-	f, err := req.MpFileHeader.Open()
-	if err != nil {
-		return &req, fmt.Errorf("unable to open file - %w", err)
+	var failed error
+	switch {
+	case cr.err != nil:
+		failed = &stepErr{http.StatusBadRequest, fmt.Errorf("req validation failed - unable to read file - %w", cr.err)}
+	case fo.err != nil && errs[0] != nil:
+		failed = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to generate hash. docHashErr - %w", errs[0])}
+	case fo.err != nil && len(errs) > 1 && errs[1] != nil:
+		failed = scanVerdict(scanned, errs[1])
+	case err != nil:
+		failed = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to store in blob store - %w", err)}
+	case maxSize > 0 && cr.n > maxSize:
+		failed = d.Policy.CheckSize(mimeType, cr.n)
+	case errs[0] != nil:
+		failed = &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to generate hash. docHashErr - %w", errs[0])}
+	case d.Scanner != nil:
+		failed = scanVerdict(scanned, errs[1])
+	}
+	if failed != nil {
+		if err == nil {
+			d.deleteBlob(ctx, docId)
+		}
+		return docSource{}, failed
 	}
 
-	// generate md5 hash for the doc and owner email id
-	docMd5Hash, e1 := d.H.Hash(c, f)
-	ownerEmailIdMd5Hash, e2 := d.H.Hash(c, strings.NewReader(req.OwnerEmail))
-	if e1 != nil || e2 != nil {
-		return &req, fmt.Errorf("unable to generate hash. docHashErr - %w. emailHashErr - %w", e1, e2)
+	src = storedSource(ctx, d.Blob, docId, name, mimeType, cr.n)
+	src.tree = tree
+	if d.Scanner != nil {
+		// the clean doc leaves quarantine for the doc store
+		src.stored, src.scanned = "", &scanned
+		err = d.storeStaged(ctx, &src, []string{docId})
+		d.deleteBlob(ctx, docId)
+		if err != nil {
+			return docSource{}, err
+		}
 	}
-	req.DocMd5Hash = docMd5Hash
-	req.OwnerEmailMd5Hash = ownerEmailIdMd5Hash
-	return &req, nil
+	return src, nil
+}
+
+// hashDoc hashes the doc content with the configured algorithm. Merkle tree hashing
+// also returns the chunk hashes, so they can be stored along with the doc metadata.
+func (d *DocH) hashDoc(ctx context.Context, in io.Reader, req *rest.UploadReq) (*hash.Tree, error) {
+	if d.Tree == nil {
+		docMd5Hash, err := d.H.Hash(ctx, in)
+		req.DocMd5Hash, req.DocHashAlgo = docMd5Hash, hash.AlgoMd5
+		return nil, err
+	}
+	t, err := d.Tree.Tree(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	req.DocMd5Hash, req.DocHashAlgo = t.Root, hash.AlgoMerkleSha256
+	return &t, nil
 }
 
 func uploadResp(doc *dbtx.DocMeta, err error) *rest.UploadResp {
 	if err != nil {
-		return &rest.UploadResp{Error: err.Error()}
+		resp := &rest.UploadResp{Error: err.Error()}
+		_ = errors.As(err, &resp.Violation)
+		var infected *scan.InfectedError
+		if errors.As(err, &infected) {
+			resp.Malware = infected.Signature
+		}
+		var dup *duplicateErr
+		if errors.As(err, &dup) {
+			resp.Duplicate = dup.dup
+		}
+		return resp
 	}
 	return &rest.UploadResp{Doc: doc}
 }
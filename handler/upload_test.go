@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/blob"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/internal/policy"
+	"github.com/vposham/trustdoc/internal/scan"
+)
+
+// fakeScanner finds malware in content containing EICAR, and fails on content containing BROKEN
+type fakeScanner struct{}
+
+func (fakeScanner) Scan(_ context.Context, doc io.Reader) (scan.Result, error) {
+	b, err := io.ReadAll(doc)
+	switch {
+	case err != nil:
+		return scan.Result{}, err
+	case bytes.Contains(b, []byte("BROKEN")):
+		return scan.Result{}, errors.New("clamd failed to scan")
+	case bytes.Contains(b, []byte("EICAR")):
+		return scan.Result{Infected: true, Signature: "Eicar-Test-Signature", Engine: "fake"}, nil
+	}
+	return scan.Result{Engine: "fake"}, nil
+}
+
+// putsBlob is a memBlob which records the objects put in it
+type putsBlob struct {
+	memBlob
+	puts *[]string
+}
+
+func (b putsBlob) Put(ctx context.Context, doc io.Reader, size int64, meta blob.ObjMeta) (string, error) {
+	docId, err := b.memBlob.Put(ctx, doc, size, meta)
+	*b.puts = append(*b.puts, docId)
+	return docId, err
+}
+
+func (b putsBlob) PutAt(ctx context.Context, objName string, obj io.Reader, size int64) error {
+	*b.puts = append(*b.puts, objName)
+	return b.memBlob.PutAt(ctx, objName, obj, size)
+}
+
+func uploadCtx(t *testing.T, fields map[string]string, doc string) *gin.Context {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if doc != "" {
+		fw, err := mw.CreateFormFile("doc", "doc.txt")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(doc))
+		require.NoError(t, err)
+	}
+	// fields after the doc are read after it was streamed
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	require.NoError(t, mw.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/upload", &body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	return c
+}
+
+func TestDocH_uploadReq(t *testing.T) {
+	fields := map[string]string{
+		"ownerEmail":     "john.doe@example.com",
+		"docTitle":       "lease",
+		"ownerFirstName": "john",
+		"ownerLastName":  "doe",
+	}
+
+	t.Run("doc streamed to blob store", func(t *testing.T) {
+		mb := memBlob{}
+		d := &DocH{Blob: mb, H: hash.Md5{}}
+		req, src, err := d.uploadReq(uploadCtx(t, fields, "streamed content"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "john.doe@example.com", req.OwnerEmail)
+		assert.Equal(t, hash.AlgoMd5, req.DocHashAlgo)
+		assert.Equal(t, "7e18ca14752cea87dc093d2f239d49c8", req.DocMd5Hash)
+		assert.NotEmpty(t, req.OwnerEmailMd5Hash)
+		assert.Equal(t, "doc.txt", src.name)
+		assert.Equal(t, "text/plain; charset=utf-8", src.mimeType)
+		assert.Equal(t, int64(len("streamed content")), src.size)
+		assert.Equal(t, "streamed content", string(mb[src.stored]))
+	})
+
+	t.Run("invalid fields remove the streamed doc", func(t *testing.T) {
+		mb := memBlob{}
+		d := &DocH{Blob: mb, H: hash.Md5{}}
+		_, _, err := d.uploadReq(uploadCtx(t, map[string]string{"docTitle": "lease"}, "streamed content"))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, errStatus(err))
+		assert.Empty(t, mb)
+	})
+
+	t.Run("doc against upload policy", func(t *testing.T) {
+		mb := memBlob{}
+		d := &DocH{Blob: mb, H: hash.Md5{}, Policy: &policy.Policy{
+			Deny:          []string{"image/png"},
+			MaxSizeByType: map[string]int64{"text/plain": 10},
+		}}
+		_, _, err := d.uploadReq(uploadCtx(t, fields, "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, errStatus(err))
+
+		_, _, err = d.uploadReq(uploadCtx(t, fields, "more than ten bytes of text"))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, errStatus(err))
+		resp := uploadResp(nil, err)
+		require.NotNil(t, resp.Violation)
+		assert.Equal(t, int64(10), resp.Violation.Limit)
+		assert.Empty(t, mb)
+	})
+
+	t.Run("doc scanned for malware", func(t *testing.T) {
+		mb, puts := memBlob{}, []string{}
+		d := &DocH{Blob: putsBlob{memBlob: mb, puts: &puts}, H: hash.Md5{}, Scanner: fakeScanner{}}
+		_, src, err := d.uploadReq(uploadCtx(t, fields, "clean content"))
+		require.NoError(t, err)
+		require.NotNil(t, src.scanned)
+		assert.Equal(t, scan.StatusClean, src.scanned.Status())
+		assert.Equal(t, "clean content", string(mb[src.stored]))
+		assert.False(t, strings.HasPrefix(src.stored, quarantinePrefix))
+
+		_, _, err = d.uploadReq(uploadCtx(t, fields, "content with EICAR in it"))
+		assert.Equal(t, http.StatusUnprocessableEntity, errStatus(err))
+		assert.Equal(t, "Eicar-Test-Signature", uploadResp(nil, err).Malware)
+
+		_, _, err = d.uploadReq(uploadCtx(t, fields, "content clamd fails on, BROKEN"))
+		assert.Equal(t, http.StatusServiceUnavailable, errStatus(err))
+		assert.Len(t, mb, 1, "only the clean doc is kept")
+		for _, objName := range puts {
+			assert.True(t, strings.HasPrefix(objName, quarantinePrefix), "%s put outside quarantine", objName)
+		}
+	})
+
+	t.Run("doc is required", func(t *testing.T) {
+		d := &DocH{Blob: memBlob{}, H: hash.Md5{}}
+		_, _, err := d.uploadReq(uploadCtx(t, fields, ""))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, errStatus(err))
+	})
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// Usage reports how much of their upload quota an owner used
+func (d *DocH) Usage(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("usage request received")
+
+	var req rest.UsageReq
+	if err := c.BindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, rest.UsageResp{Error: "req validation failed - " + err.Error()})
+		return
+	}
+
+	u, err := d.Db.GetUserUsage(c, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError,
+			rest.UsageResp{Error: fmt.Errorf("unable to find usage in db - %w", err).Error()})
+		return
+	}
+	resp := rest.UsageResp{Email: req.Email, Usage: &u, Quota: &dbtx.Usage{}}
+	if d.Policy != nil {
+		resp.Quota = &dbtx.Usage{Docs: d.Policy.OwnerMaxDocs, Bytes: d.Policy.OwnerMaxBytes}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// parseDocMeta reads the tags and the validity window of an upload request
+func parseDocMeta(req *rest.UploadReq) error {
+	if err := parseTags(req); err != nil {
+		return err
+	}
+	return parseValidity(req)
+}
+
+// parseValidity reads the optional RFC 3339 times between which the document of an upload request is valid
+func parseValidity(req *rest.UploadReq) error {
+	from, err := parseValidityTime("validFrom", req.ValidFrom)
+	if err != nil {
+		return err
+	}
+	until, err := parseValidityTime("validUntil", req.ValidUntil)
+	if err != nil {
+		return err
+	}
+	if from != nil && until != nil && !until.After(*from) {
+		return fmt.Errorf("validUntil must be after validFrom")
+	}
+	req.DocValidFrom, req.DocValidUntil = from, until
+	return nil
+}
+
+func parseValidityTime(name, v string) (*time.Time, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(v))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an RFC 3339 time - %w", name, err)
+	}
+	// the window is anchored in blockchain in seconds
+	t = t.UTC().Truncate(time.Second)
+	return &t, nil
+}
+
+// docValidity is the validity window of a doc as it is anchored in blockchain
+func docValidity(doc dbtx.DocMeta) bc.Validity {
+	var v bc.Validity
+	if doc.ValidFrom != nil {
+		v.From = *doc.ValidFrom
+	}
+	if doc.ValidUntil != nil {
+		v.Until = *doc.ValidUntil
+	}
+	return v
+}
+
+// validityState is whether a doc with the given validity window is valid at a time
+func validityState(v bc.Validity, at time.Time) string {
+	switch {
+	case !v.From.IsZero() && at.Before(v.From):
+		return rest.NotYetValid
+	case !v.Until.IsZero() && !at.Before(v.Until):
+		return rest.Expired
+	default:
+		return rest.Valid
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+func Test_parseValidity(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		req       rest.UploadReq
+		wantFrom  *time.Time
+		wantUntil *time.Time
+		wantErr   bool
+	}{
+		{name: "no window", req: rest.UploadReq{}},
+		{name: "only until", req: rest.UploadReq{ValidUntil: "2027-01-01T00:00:00Z"}, wantUntil: &until},
+		{
+			name:      "window in another zone",
+			req:       rest.UploadReq{ValidFrom: "2026-01-01T05:30:00.25+05:30", ValidUntil: " 2027-01-01T00:00:00Z "},
+			wantFrom:  &from,
+			wantUntil: &until,
+		},
+		{name: "not rfc 3339", req: rest.UploadReq{ValidFrom: "2026-01-01"}, wantErr: true},
+		{
+			name:    "until before from",
+			req:     rest.UploadReq{ValidFrom: "2027-01-01T00:00:00Z", ValidUntil: "2026-01-01T00:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "empty window",
+			req:     rest.UploadReq{ValidFrom: "2027-01-01T00:00:00Z", ValidUntil: "2027-01-01T00:00:00Z"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseValidity(&tt.req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFrom, tt.req.DocValidFrom)
+			assert.Equal(t, tt.wantUntil, tt.req.DocValidUntil)
+		})
+	}
+}
+
+func Test_validityState(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := bc.Validity{From: from, Until: until}
+	tests := []struct {
+		name     string
+		validity bc.Validity
+		at       time.Time
+		want     string
+	}{
+		{"no window", bc.Validity{}, until.AddDate(10, 0, 0), rest.Valid},
+		{"before window", window, from.Add(-time.Second), rest.NotYetValid},
+		{"start of window", window, from, rest.Valid},
+		{"end of window", window, until, rest.Expired},
+		{"open start", bc.Validity{Until: until}, from.AddDate(-10, 0, 0), rest.Valid},
+		{"open end", bc.Validity{From: from}, until.AddDate(10, 0, 0), rest.Valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validityState(tt.validity, tt.at))
+		})
+	}
+}
+
+func TestDocH_Verify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verify := func(validity bc.Validity) rest.VerifyResp {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("ownerEmail", "john.doe@example.com"))
+		require.NoError(t, mw.WriteField("docBcTkn", "tkn-1"))
+		fw, err := mw.CreateFormFile("doc", "doc.txt")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("content"))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/verify", &body)
+		c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+		ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader("john.doe@example.com"))
+		require.NoError(t, err)
+		d := &DocH{Db: newSagaStore(), H: hash.Md5{}, Bc: anchorBc{anchors: map[string]bc.Anchor{"tkn-1": {
+			DocHash: "9a0364b9e99bb480dd25e1f0284c8555", OwnerEmailMd5Hash: ownerMd5, Validity: validity}}}}
+		d.Verify(c)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp rest.VerifyResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := verify(bc.Validity{})
+	assert.True(t, resp.Verified)
+	assert.Empty(t, resp.Reasons)
+	assert.Empty(t, resp.Validity)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	resp = verify(bc.Validity{From: past, Until: future})
+	assert.True(t, resp.Verified)
+	assert.Equal(t, rest.Valid, resp.Validity)
+	assert.Equal(t, &future, resp.ValidUntil)
+
+	resp = verify(bc.Validity{Until: past})
+	assert.False(t, resp.Verified)
+	assert.Equal(t, rest.Expired, resp.Validity)
+	assert.Equal(t, []string{rest.Expired}, resp.Reasons)
+	assert.True(t, resp.Report.Expired)
+	assert.Nil(t, resp.ValidFrom)
+
+	resp = verify(bc.Validity{From: future})
+	assert.False(t, resp.Verified)
+	assert.Equal(t, rest.NotYetValid, resp.Validity)
+	assert.Equal(t, []string{rest.NotYetValid}, resp.Reasons)
+	assert.False(t, resp.Report.Expired)
+}
@@ -1,13 +1,21 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
 	"github.com/vposham/trustdoc/log"
+	"github.com/vposham/trustdoc/pkg/receipt"
 	"github.com/vposham/trustdoc/pkg/rest"
 )
 
@@ -18,17 +26,178 @@ func (d *DocH) Verify(c *gin.Context) {
 	// parse the request
 	req, err := d.verifyReq(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, verifyResp(false, err))
+		status := http.StatusBadRequest
+		if se := (*stepErr)(nil); errors.As(err, &se) {
+			status = se.status
+		}
+		c.JSON(status, verifyResp(false, err))
 		return
 	}
-	err = d.Bc.VerifyDocTkn(c, req.DocBcTkn, req.DocMd5Hash, req.OwnerEmailMd5Hash)
+
+	// flag visually similar documents, which is useful even when the verification fails
+	_, similar := d.findSimilar(c, fileSource(req.MpFileHeader), req.DocMd5Hash)
+
+	status, resp := d.verifyTkn(c, req.DocBcTkn, req.DocHashAlgo, req.DocMd5Hash, req.OwnerEmailMd5Hash)
+	resp.SimilarTo = similar
+	c.JSON(status, resp)
+}
+
+// VerifyHash verifies a document by its digest, for clients which only keep the digest of the doc or
+// do not want to send it again. The doc is resolved from its digest for what the client leaves out.
+func (d *DocH) VerifyHash(c *gin.Context) {
+	logger := log.GetLogger(c)
+	logger.Info("document hash verification request received")
+
+	var req rest.VerifyHashReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, verifyResp(false, fmt.Errorf("req validation failed - %w", err)))
+		return
+	}
+	v, err := d.resolveHashReq(c, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError,
-			verifyResp(false, fmt.Errorf("unable to verify in blockchain - %w", err)))
+		c.JSON(errStatus(err), verifyResp(false, err))
 		return
 	}
+	c.JSON(d.verifyTkn(c, v.tkn, v.algo, v.digest, v.ownerEmailMd5Hash))
+}
+
+// hashVerification is a verification of a doc by its digest, with the tkn and owner it is verified against
+type hashVerification struct {
+	tkn, algo, digest, ownerEmailMd5Hash string
+}
+
+// resolveHashReq resolves the doc of a digest for the tkn and owner a hash verification leaves out
+func (d *DocH) resolveHashReq(ctx context.Context, req rest.VerifyHashReq) (hashVerification, error) {
+	digest := strings.ToLower(req.Digest)
+	if len(digest) != digestLen[req.Algorithm] {
+		return hashVerification{}, &stepErr{http.StatusBadRequest,
+			fmt.Errorf("req validation failed - %s digest must be %d hex chars", req.Algorithm, digestLen[req.Algorithm])}
+	}
+
+	tkn, ownerEmail := req.TokenId, req.OwnerEmail
+	if tkn == "" || ownerEmail == "" {
+		meta, err := d.Db.GetDocMetaByHash(ctx, digest)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && docHashAlgo(meta) != req.Algorithm {
+			return hashVerification{}, &stepErr{http.StatusNotFound, errors.New("no doc found with the digest")}
+		}
+		if err != nil {
+			return hashVerification{}, fmt.Errorf("unable to find doc in db - %w", err)
+		}
+		if tkn == "" {
+			tkn = meta.BcTknId
+		}
+		if ownerEmail == "" {
+			ownerEmail = meta.OwnerEmail
+		}
+	}
+	if tkn == "" {
+		return hashVerification{}, &stepErr{http.StatusConflict, errors.New("doc is not anchored in blockchain")}
+	}
+	ownerEmailMd5Hash, err := d.H.Hash(ctx, strings.NewReader(ownerEmail))
+	if err != nil {
+		return hashVerification{}, fmt.Errorf("unable to generate hash - %w", err)
+	}
+	return hashVerification{tkn: tkn, algo: req.Algorithm, digest: digest, ownerEmailMd5Hash: ownerEmailMd5Hash}, nil
+}
+
+// verifyTkn reports what matched of the doc and owner hashes against the ones anchored with a tkn. A doc is
+// only verified when both match, it is not revoked and it is within its validity window.
+func (d *DocH) verifyTkn(ctx context.Context, tkn, algo, docHash, ownerEmailMd5Hash string) (int, *rest.VerifyResp) {
+	return d.verifyAnchored(ctx, d.readAnchor(ctx, tkn), algo, docHash, ownerEmailMd5Hash)
+}
+
+// tknAnchor is what is anchored with a tkn, along with the doc of the service anchored with it
+type tknAnchor struct {
+	tkn    string
+	anchor bc.Anchor
+	doc    dbtx.DocMeta
+	err    error
+}
+
+// readAnchor reads what is anchored with a tkn, err is bc.ErrTknNotFound when the tkn does not exist
+func (d *DocH) readAnchor(ctx context.Context, tkn string) tknAnchor {
+	a, err := d.Bc.GetDocTkn(ctx, tkn)
+	if errors.Is(err, bc.ErrTknNotFound) {
+		return tknAnchor{tkn: tkn, err: err}
+	}
+	if err != nil {
+		return tknAnchor{tkn: tkn, err: fmt.Errorf("unable to verify in blockchain - %w", err)}
+	}
+	doc, err := d.anchoredDoc(ctx, tkn, a.DocHash)
+	if err != nil {
+		return tknAnchor{tkn: tkn, err: fmt.Errorf("unable to find doc in db - %w", err)}
+	}
+	return tknAnchor{tkn: tkn, anchor: a, doc: doc}
+}
+
+// verifyAnchored reports what matched of the doc and owner hashes against what is anchored with a tkn
+func (d *DocH) verifyAnchored(ctx context.Context, ta tknAnchor, algo, docHash,
+	ownerEmailMd5Hash string) (int, *rest.VerifyResp) {
+	tkn, a, doc := ta.tkn, ta.anchor, ta.doc
+	if errors.Is(ta.err, bc.ErrTknNotFound) {
+		resp := verifyResp(false, nil)
+		resp.Reasons, resp.Report = []string{rest.TokenNotFound}, &rest.VerifyReport{}
+		return d.signReceipt(ctx, resp, receipt.Claims{DocHash: docHash, HashAlgo: algo, BcTknId: tkn})
+	}
+	if ta.err != nil {
+		return http.StatusInternalServerError, verifyResp(false, ta.err)
+	}
+	revoked := doc.RevokedAt != nil
+
+	// an authentic doc is not verified outside of its validity window
+	state := validityState(a.Validity, time.Now())
+	report := &rest.VerifyReport{
+		ContentMatch: a.DocHash == docHash,
+		OwnerMatch:   a.OwnerEmailMd5Hash == ownerEmailMd5Hash,
+		TokenExists:  true,
+		Revoked:      revoked,
+		Expired:      state == rest.Expired,
+		Anchor:       restAnchor(a),
+	}
+	var reasons []string
+	if !report.ContentMatch {
+		reasons = append(reasons, rest.ContentMismatch)
+	}
+	if !report.OwnerMatch {
+		reasons = append(reasons, rest.OwnerMismatch)
+	}
+	if revoked {
+		reasons = append(reasons, rest.Revoked)
+	}
+	if state != rest.Valid {
+		reasons = append(reasons, state)
+	}
 
-	c.JSON(http.StatusOK, verifyResp(true, nil))
+	resp := verifyResp(len(reasons) == 0, nil)
+	resp.Reasons, resp.Report = reasons, report
+	if !a.Validity.IsZero() {
+		resp.Validity = state
+		resp.ValidFrom, resp.ValidUntil = timeOrNil(a.Validity.From), timeOrNil(a.Validity.Until)
+	}
+	return d.signReceipt(ctx, resp, receipt.Claims{DocId: doc.DocId, DocHash: docHash, HashAlgo: algo, BcTknId: tkn,
+		TokenId: a.TokenId, ContractAddress: a.ContractAddress, ChainId: a.ChainId})
+}
+
+// restAnchor is where a doc token is anchored, as it is reported
+func restAnchor(a bc.Anchor) *rest.Anchor {
+	return &rest.Anchor{
+		TokenId:         a.TokenId,
+		TxHash:          a.TxHash,
+		BlockNumber:     a.BlockNumber,
+		AnchoredAt:      a.AnchoredAt,
+		ContractAddress: a.ContractAddress,
+		ChainId:         a.ChainId,
+	}
+}
+
+// anchoredDoc is the doc the service anchored with a tkn, whose revocation is only kept by the service.
+// It is empty when the tkn was not minted for a doc of the service.
+func (d *DocH) anchoredDoc(ctx context.Context, tkn, docHash string) (dbtx.DocMeta, error) {
+	meta, err := d.Db.GetDocMetaByHash(ctx, docHash)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !strings.EqualFold(meta.BcTknId, tkn) {
+		return dbtx.DocMeta{}, nil
+	}
+	return meta, err
 }
 
 func (d *DocH) verifyReq(c *gin.Context) (*rest.VerifyReq, error) {
@@ -50,20 +219,63 @@ func (d *DocH) verifyReq(c *gin.Context) (*rest.VerifyReq, error) {
 		return &req, fmt.Errorf("unable to open file - %w", err)
 	}
 
-	// generate md5 hash for the doc and owner email id
-	docMd5Hash, e1 := d.H.Hash(c, f)
+	// the doc is hashed the way the doc anchored with the tkn was hashed on upload, not the way docs are hashed now
+	hasher, algo, err := d.tknHasher(c, req.DocBcTkn)
+	if err != nil {
+		return &req, err
+	}
+
+	// generate hash for the doc and md5 hash for the owner email id
+	docMd5Hash, e1 := hasher.Hash(c, f)
 	ownerEmailIdMd5Hash, e2 := d.H.Hash(c, strings.NewReader(req.OwnerEmail))
 	if e1 != nil || e2 != nil {
 		return &req, fmt.Errorf("unable to generate hash. docHashErr - %w. emailHashErr - %w", e1, e2)
 	}
-	req.DocMd5Hash = docMd5Hash
+	req.DocMd5Hash, req.DocHashAlgo = docMd5Hash, algo
 	req.OwnerEmailMd5Hash = ownerEmailIdMd5Hash
 	return &req, nil
 }
 
+// tknHasher is the hasher and hash algo of the doc anchored with a tkn. Docs the service does not know of
+// are hashed the way docs are hashed now.
+func (d *DocH) tknHasher(ctx context.Context, tkn string) (hash.Hasher, string, error) {
+	meta, err := d.Db.GetDocMetaByTkn(ctx, strings.ToLower(tkn))
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.docHasher(), d.hashAlgo(), nil
+	}
+	if err != nil {
+		return nil, "", &stepErr{http.StatusInternalServerError, fmt.Errorf("unable to find doc in db - %w", err)}
+	}
+	hasher := contentHasher(meta)
+	if hasher == nil {
+		return nil, "", &stepErr{http.StatusInternalServerError,
+			fmt.Errorf("doc is hashed with unsupported algo %s", meta.HashAlgo)}
+	}
+	return hasher, docHashAlgo(meta), nil
+}
+
 func verifyResp(verified bool, err error) *rest.VerifyResp {
 	if err != nil {
 		return &rest.VerifyResp{Error: err.Error(), Verified: verified}
 	}
 	return &rest.VerifyResp{Verified: verified}
 }
+
+// digestLen is the number of hex chars in a digest of each of the hash algos docs are anchored with
+var digestLen = map[string]int{hash.AlgoMd5: 32, hash.AlgoMerkleSha256: 64}
+
+// hashAlgo is the hash algo documents are hashed with
+func (d *DocH) hashAlgo() string {
+	if d.Tree != nil {
+		return hash.AlgoMerkleSha256
+	}
+	return hash.AlgoMd5
+}
+
+// docHashAlgo is the hash algo of a doc, docs hashed before algos were recorded are md5 hashed
+func docHashAlgo(meta dbtx.DocMeta) string {
+	if meta.HashAlgo == "" {
+		return hash.AlgoMd5
+	}
+	return meta.HashAlgo
+}
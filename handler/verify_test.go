@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/bc"
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/hash"
+	"github.com/vposham/trustdoc/pkg/rest"
+)
+
+// anchorBc is a blockchain which verifies the hashes anchored with its tkns
+type anchorBc struct {
+	fakeBc
+	anchors map[string]bc.Anchor
+}
+
+func (b anchorBc) VerifyDocTkn(ctx context.Context, tknId, docMd5Hash, ownerEmailMd5Hash string) (bc.Validity, error) {
+	a, err := b.GetDocTkn(ctx, tknId)
+	if err != nil || a.DocHash != docMd5Hash || a.OwnerEmailMd5Hash != ownerEmailMd5Hash {
+		return bc.Validity{}, bc.ErrTknMismatch
+	}
+	return a.Validity, nil
+}
+
+func (b anchorBc) GetDocTkn(_ context.Context, tknId string) (bc.Anchor, error) {
+	a, ok := b.anchors[tknId]
+	if !ok {
+		return a, bc.ErrTknNotFound
+	}
+	return a, nil
+}
+
+func TestDocH_Verify_storedHashAlgo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		owner  = "john.doe@example.com"
+		docMd5 = "9a0364b9e99bb480dd25e1f0284c8555" // md5 of the doc, content
+	)
+	ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader(owner))
+	require.NoError(t, err)
+	db := newSagaStore()
+	db.docs[docMd5] = dbtx.DocMeta{DocId: "doc-1", OwnerEmail: owner, DocMd5Hash: docMd5,
+		HashAlgo: hash.AlgoMd5, BcTknId: "tkn-1"}
+	// the doc was uploaded before the service switched to merkle trees
+	d := &DocH{
+		Db:   db,
+		Bc:   anchorBc{anchors: map[string]bc.Anchor{"tkn-1": {DocHash: docMd5, OwnerEmailMd5Hash: ownerMd5}}},
+		H:    hash.Md5{},
+		Tree: &hash.Merkle{},
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("ownerEmail", owner))
+	require.NoError(t, mw.WriteField("docBcTkn", "tkn-1"))
+	fw, err := mw.CreateFormFile("doc", "doc.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/verify", &body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	d.Verify(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp rest.VerifyResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Verified)
+	assert.True(t, resp.Report.ContentMatch)
+}
+
+func TestDocH_VerifyHash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		owner  = "john.doe@example.com"
+		docMd5 = "9a0364b9e99bb480dd25e1f0284c8555" // md5 of the doc, content
+	)
+	ownerMd5, err := hash.Md5{}.Hash(context.Background(), strings.NewReader(owner))
+	require.NoError(t, err)
+	db := newSagaStore()
+	db.docs[docMd5] = dbtx.DocMeta{DocId: "doc-1", OwnerEmail: owner, DocMd5Hash: docMd5,
+		HashAlgo: hash.AlgoMd5, BcTknId: "tkn-1"}
+	db.docs["5eb63bbbe01eeed093cb22bb8f5acdc3"] = dbtx.DocMeta{DocId: "doc-2", OwnerEmail: owner,
+		DocMd5Hash: "5eb63bbbe01eeed093cb22bb8f5acdc3"}
+	d := &DocH{
+		Db: db,
+		Bc: anchorBc{anchors: map[string]bc.Anchor{"tkn-1": {DocHash: docMd5, OwnerEmailMd5Hash: ownerMd5}}},
+		H:  hash.Md5{},
+	}
+	verifyHash := func(req rest.VerifyHashReq) (int, rest.VerifyResp) {
+		w := httptest.NewRecorder()
+		c := jsonCtx(t, w, "/svc/v1/doc/verify/hash", req)
+		d.VerifyHash(c)
+		var resp rest.VerifyResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	t.Run("same as verifying the doc", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("ownerEmail", owner))
+		require.NoError(t, mw.WriteField("docBcTkn", "tkn-1"))
+		fw, err := mw.CreateFormFile("doc", "doc.txt")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("content"))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/svc/v1/doc/verify", &body)
+		c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+		d.Verify(c)
+
+		status, resp := verifyHash(rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5,
+			OwnerEmail: owner, TokenId: "tkn-1"})
+		var want rest.VerifyResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &want))
+		assert.Equal(t, w.Code, status)
+		assert.Equal(t, want, resp)
+		assert.True(t, resp.Verified)
+	})
+
+	tests := []struct {
+		name     string
+		req      rest.VerifyHashReq
+		status   int
+		verified bool
+	}{
+		{name: "doc resolved from its digest",
+			req: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: strings.ToUpper(docMd5)}, status: http.StatusOK,
+			verified: true},
+		{name: "owner verified with the doc resolved from its digest",
+			req:    rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5, OwnerEmail: owner},
+			status: http.StatusOK, verified: true},
+		{name: "owner of the tkn resolved from the digest",
+			req:    rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5, TokenId: "tkn-1"},
+			status: http.StatusOK, verified: true},
+		{name: "someone else's doc",
+			req:    rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5, OwnerEmail: "jane.doe@example.com"},
+			status: http.StatusOK},
+		{name: "unknown digest",
+			req:    rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: "0cc175b9c0f1b6a831c399e269772661"},
+			status: http.StatusNotFound},
+		{name: "digest of another algo", req: rest.VerifyHashReq{Algorithm: hash.AlgoMerkleSha256,
+			Digest: strings.Repeat(docMd5, 2)}, status: http.StatusNotFound},
+		{name: "doc not anchored",
+			req:    rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: "5eb63bbbe01eeed093cb22bb8f5acdc3"},
+			status: http.StatusConflict},
+		{name: "digest too short", req: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: docMd5[:30]},
+			status: http.StatusBadRequest},
+		{name: "digest not hex", req: rest.VerifyHashReq{Algorithm: hash.AlgoMd5, Digest: "content"},
+			status: http.StatusBadRequest},
+		{name: "unknown algo", req: rest.VerifyHashReq{Algorithm: "sha1", Digest: docMd5},
+			status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := verifyHash(tt.req)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.verified, resp.Verified)
+			if tt.status != http.StatusOK {
+				assert.NotEmpty(t, resp.Error)
+			}
+		})
+	}
+}
+
+func TestDocH_verifyTkn(t *testing.T) {
+	anchoredAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	anchored := bc.Anchor{DocHash: "doc-hash", OwnerEmailMd5Hash: "owner-hash", AnchoredAt: anchoredAt,
+		BlockNumber: 42, TxHash: "0xabc", TokenId: "7", ContractAddress: "0xdef", ChainId: 1337}
+	expired := anchored
+	expired.Validity = bc.Validity{Until: anchoredAt.Add(time.Hour)}
+	revokedAt := anchoredAt.Add(time.Hour)
+	db := newSagaStore()
+	db.docs["revoked-hash"] = dbtx.DocMeta{DocMd5Hash: "revoked-hash", BcTknId: "tkn-3", RevokedAt: &revokedAt}
+	revoked := anchored
+	revoked.DocHash = "revoked-hash"
+	d := &DocH{Db: db, Bc: anchorBc{anchors: map[string]bc.Anchor{"tkn-1": anchored, "tkn-2": expired,
+		"tkn-3": revoked}}}
+	anchor := &rest.Anchor{TokenId: "7", TxHash: "0xabc", BlockNumber: 42, AnchoredAt: anchoredAt,
+		ContractAddress: "0xdef", ChainId: 1337}
+
+	tests := []struct {
+		name                string
+		tkn, docHash, owner string
+		reasons             []string
+		report              *rest.VerifyReport
+	}{
+		{name: "verified", tkn: "tkn-1", docHash: "doc-hash", owner: "owner-hash",
+			report: &rest.VerifyReport{ContentMatch: true, OwnerMatch: true, TokenExists: true, Anchor: anchor}},
+		{name: "altered doc", tkn: "tkn-1", docHash: "other-hash", owner: "owner-hash",
+			reasons: []string{rest.ContentMismatch},
+			report:  &rest.VerifyReport{OwnerMatch: true, TokenExists: true, Anchor: anchor}},
+		{name: "someone else's doc", tkn: "tkn-1", docHash: "other-hash", owner: "other-owner",
+			reasons: []string{rest.ContentMismatch, rest.OwnerMismatch},
+			report:  &rest.VerifyReport{TokenExists: true, Anchor: anchor}},
+		{name: "expired doc", tkn: "tkn-2", docHash: "doc-hash", owner: "owner-hash",
+			reasons: []string{rest.Expired},
+			report: &rest.VerifyReport{ContentMatch: true, OwnerMatch: true, TokenExists: true, Expired: true,
+				Anchor: anchor}},
+		{name: "revoked doc", tkn: "tkn-3", docHash: "revoked-hash", owner: "owner-hash",
+			reasons: []string{rest.Revoked},
+			report: &rest.VerifyReport{ContentMatch: true, OwnerMatch: true, TokenExists: true, Revoked: true,
+				Anchor: anchor}},
+		{name: "unknown tkn", tkn: "tkn-9", docHash: "doc-hash", owner: "owner-hash",
+			reasons: []string{rest.TokenNotFound}, report: &rest.VerifyReport{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := d.verifyTkn(context.Background(), tt.tkn, hash.AlgoMd5, tt.docHash, tt.owner)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, len(tt.reasons) == 0, resp.Verified)
+			assert.Equal(t, tt.reasons, resp.Reasons)
+			assert.Equal(t, tt.report, resp.Report)
+		})
+	}
+
+	d.Bc = fakeBc{verifyErr: errors.New("node down")}
+	status, resp := d.verifyTkn(context.Background(), "tkn-1", hash.AlgoMd5, "doc-hash", "owner-hash")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.False(t, resp.Verified)
+	assert.Nil(t, resp.Report)
+}
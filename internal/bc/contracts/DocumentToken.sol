@@ -14,6 +14,9 @@ contract DocumentToken is ERC721 {
         string docMd5Hash;
         string ownerEmailIdMd5Hash;
         uint256 uploadedAt;
+        // validity window of the document as unix seconds, 0 when unbounded
+        uint256 validFrom;
+        uint256 validUntil;
     }
 
     mapping(uint256 => Document) private _documents;
@@ -25,6 +28,17 @@ contract DocumentToken is ERC721 {
         string memory _docMd5Hash,
         string memory _ownerEmailIdMd5Hash
     ) public returns (uint256) {
+        return mintDocumentWithValidity(_docId, _docMd5Hash, _ownerEmailIdMd5Hash, 0, 0);
+    }
+
+    function mintDocumentWithValidity(
+        string memory _docId,
+        string memory _docMd5Hash,
+        string memory _ownerEmailIdMd5Hash,
+        uint256 _validFrom,
+        uint256 _validUntil
+    ) public returns (uint256) {
+        require(_validUntil == 0 || _validUntil > _validFrom, "validUntil must be after validFrom");
         _tokenIds.increment();
         uint256 newItemId = _tokenIds.current();
 
@@ -32,7 +46,9 @@ contract DocumentToken is ERC721 {
             docId: _docId,
             docMd5Hash: _docMd5Hash,
             ownerEmailIdMd5Hash: _ownerEmailIdMd5Hash,
-            uploadedAt: block.timestamp
+            uploadedAt: block.timestamp,
+            validFrom: _validFrom,
+            validUntil: _validUntil
         });
 
         _mint(msg.sender, newItemId);
@@ -54,4 +70,9 @@ contract DocumentToken is ERC721 {
         Document storage doc = _documents[_tokenId];
         return doc.ownerEmailIdMd5Hash;
     }
+
+    function getDocumentValidity(uint256 _tokenId) public view returns (uint256, uint256) {
+        Document storage doc = _documents[_tokenId];
+        return (doc.validFrom, doc.validUntil);
+    }
 }
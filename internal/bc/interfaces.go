@@ -1,9 +1,56 @@
 package bc
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTknMismatch is returned when the doc or owner hash anchored with a tkn is not the one verified
+	ErrTknMismatch = errors.New("docTkn verification failed")
+
+	// ErrTknNotFound is returned when no doc was anchored with a tkn
+	ErrTknNotFound = errors.New("docTkn not found")
+)
 
 type OpsIf interface {
-	MintDocTkn(ctx context.Context, docId, docMd5Hash, ownerEmailMd5Hash string) (tknId string, err error)
+	// MintDocTkn anchors a doc in blockchain along with the window in which it is valid
+	MintDocTkn(ctx context.Context, docId, docMd5Hash, ownerEmailMd5Hash string,
+		validity Validity) (tknId string, err error)
+
+	// ConfirmDocTkn waits until the minted tkn is confirmed in blockchain
+	ConfirmDocTkn(ctx context.Context, tknId string) error
+
+	// VerifyDocTkn checks the doc and owner hashes anchored with a tkn, and returns the validity anchored with it
+	VerifyDocTkn(ctx context.Context, tknId, docMd5Hash, ownerEmailMd5Hash string) (Validity, error)
+
+	// GetDocTkn returns what is anchored with a tkn and where it is anchored, or ErrTknNotFound
+	GetDocTkn(ctx context.Context, tknId string) (Anchor, error)
+}
+
+// Anchor is what is anchored in blockchain with a tkn, and the block, contract and chain it is anchored in
+type Anchor struct {
+	DocHash           string
+	OwnerEmailMd5Hash string
+	Validity          Validity
+
+	// AnchoredAt is the time of the block the tkn was minted in
+	AnchoredAt      time.Time
+	BlockNumber     uint64
+	TxHash          string
+	TokenId         string
+	ContractAddress string
+	ChainId         int64
+}
+
+// Validity is the window in which a doc is valid, a zero time leaves that end of it open
+type Validity struct {
+	From  time.Time
+	Until time.Time
+}
 
-	VerifyDocTkn(ctx context.Context, tknId, docMd5Hash, ownerEmailMd5Hash string) (err error)
+// IsZero reports whether the doc is valid at all times
+func (v Validity) IsZero() bool {
+	return v.From.IsZero() && v.Until.IsZero()
 }
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"time"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -38,14 +39,15 @@ type Kaleido struct {
 	receiptWaitMaxDuration time.Duration
 }
 
-func (k *Kaleido) MintDocTkn(ctx context.Context, docId, docHash, ownerEmailHash string) (string, error) {
+func (k *Kaleido) MintDocTkn(ctx context.Context, docId, docHash, ownerEmailHash string,
+	validity Validity) (string, error) {
 	logger := log.GetLogger(ctx)
 	logger.Info("creating new docTkn", zap.String("docId", docId))
 	nonce, err := k.ethCl.PendingNonceAt(ctx, *k.from)
 	if err != nil {
 		return "", fmt.Errorf("failed contractAddress get nonce: %w", err)
 	}
-	tx, err := k.docTkn.MintDocument(&bind.TransactOpts{
+	opts := &bind.TransactOpts{
 		From:      *k.contractAddress,
 		Nonce:     big.NewInt(int64(nonce)),
 		Signer:    k.sign,
@@ -56,7 +58,14 @@ func (k *Kaleido) MintDocTkn(ctx context.Context, docId, docHash, ownerEmailHash
 		GasLimit:  uint64(k.gasLimitOnTx),
 		Context:   ctx,
 		NoSend:    false,
-	}, docId, docHash, ownerEmailHash)
+	}
+	var tx *types.Transaction
+	if validity.IsZero() {
+		tx, err = k.docTkn.MintDocument(opts, docId, docHash, ownerEmailHash)
+	} else {
+		tx, err = k.docTkn.MintDocumentWithValidity(opts, docId, docHash, ownerEmailHash,
+			unixSecs(validity.From), unixSecs(validity.Until))
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to mint new docTkn: %w", err)
 	}
@@ -65,36 +74,113 @@ func (k *Kaleido) MintDocTkn(ctx context.Context, docId, docHash, ownerEmailHash
 	return bcTxHash, nil
 }
 
+// ConfirmDocTkn waits until the tx minting a docTkn is mined, and fails when the tx was reverted
+func (k *Kaleido) ConfirmDocTkn(ctx context.Context, tknId string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("waiting for docTkn to be mined", zap.String("bcTxHash", tknId))
+	start := time.Now()
+	time.Sleep(k.receiptWaitMinDuration)
+	receipt, err := k.waitUntilMined(ctx, start, tknId, 1*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed checking docTkn tx receipt: %w", err)
+	}
+	if receipt.Status == nil || receipt.Status.ToInt().Sign() == 0 {
+		return errors.New("docTkn tx was reverted")
+	}
+	logger.Info("docTkn mined", zap.String("bcTxHash", tknId), zap.Duration("waited", time.Since(start)))
+	return nil
+}
+
 func (k *Kaleido) sign(a common.Address, t *types.Transaction) (*types.Transaction, error) {
 	return types.SignTx(t, k.signer, k.privateKey)
 }
 
-func (k *Kaleido) VerifyDocTkn(ctx context.Context, tknId, docMd5Hash, ownerEmailMd5Hash string) (err error) {
+func (k *Kaleido) VerifyDocTkn(ctx context.Context, tknId, docMd5Hash, ownerEmailMd5Hash string) (Validity, error) {
 	logger := log.GetLogger(ctx)
 	logger.Info("verifying a docTkn")
-	h := common.HexToHash(tknId)
+	a, err := k.GetDocTkn(ctx, tknId)
+	if errors.Is(err, ErrTknNotFound) {
+		return Validity{}, ErrTknMismatch
+	}
+	if err != nil {
+		return Validity{}, fmt.Errorf("failed contractAddress verify docTkn: %w", err)
+	}
+	if a.DocHash != docMd5Hash || a.OwnerEmailMd5Hash != ownerEmailMd5Hash {
+		return Validity{}, ErrTknMismatch
+	}
+	logger.Info("docTkn verified")
+	return a.Validity, nil
+}
 
-	bcDocHash, err := k.docTkn.GetDocumentContent(&bind.CallOpts{
-		Pending: true,
-		From:    *k.contractAddress,
-		Context: ctx,
-	}, h.Big())
+// GetDocTkn reads the doc anchored with a docTkn. A docTkn is the hash of the tx which minted it,
+// the token it minted is the one transferred in the tx receipt.
+func (k *Kaleido) GetDocTkn(ctx context.Context, tknId string) (Anchor, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("reading a docTkn", zap.String("bcTxHash", tknId))
+	receipt, err := k.ethCl.TransactionReceipt(ctx, common.HexToHash(tknId))
+	if errors.Is(err, ethereum.NotFound) {
+		return Anchor{}, ErrTknNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed contractAddress verify docTkn: %w", err)
+		return Anchor{}, fmt.Errorf("failed to get docTkn tx receipt: %w", err)
+	}
+	tokenId := k.mintedTokenId(receipt)
+	if receipt.Status != types.ReceiptStatusSuccessful || tokenId == nil {
+		return Anchor{}, ErrTknNotFound
 	}
 
-	bcDocOwnerHash, err := k.docTkn.GetDocumentOwner(&bind.CallOpts{
+	opts := &bind.CallOpts{
 		Pending: true,
 		From:    *k.contractAddress,
 		Context: ctx,
-	}, h.Big())
+	}
+	_, bcDocHash, bcDocOwnerHash, uploadedAt, err := k.docTkn.GetDocument(opts, tokenId)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("failed contractAddress get docTkn: %w", err)
+	}
+	from, until, err := k.docTkn.GetDocumentValidity(opts, tokenId)
 	if err != nil {
-		return fmt.Errorf("failed contractAddress verify docTkn: %w", err)
+		return Anchor{}, fmt.Errorf("failed contractAddress get docTkn validity: %w", err)
 	}
+	return Anchor{
+		DocHash:           bcDocHash,
+		OwnerEmailMd5Hash: bcDocOwnerHash,
+		Validity:          Validity{From: fromUnixSecs(from), Until: fromUnixSecs(until)},
+		AnchoredAt:        fromUnixSecs(uploadedAt),
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		TxHash:            receipt.TxHash.Hex(),
+		TokenId:           tokenId.String(),
+		ContractAddress:   k.contractAddress.Hex(),
+		ChainId:           k.signer.ChainID().Int64(),
+	}, nil
+}
+
+// mintedTokenId is the token minted by a tx of the contract, which is transferred from the zero address
+func (k *Kaleido) mintedTokenId(receipt *types.Receipt) *big.Int {
+	for _, l := range receipt.Logs {
+		if l.Address != *k.contractAddress {
+			continue
+		}
+		ev, err := k.docTkn.ParseTransfer(*l)
+		if err == nil && ev.From == (common.Address{}) {
+			return ev.TokenId
+		}
+	}
+	return nil
+}
+
+// unixSecs is a time as it is kept in the contract, where 0 is an open end of a validity window
+func unixSecs(t time.Time) *big.Int {
+	if t.IsZero() {
+		return new(big.Int)
+	}
+	return big.NewInt(t.Unix())
+}
 
-	if bcDocHash == docMd5Hash && bcDocOwnerHash == ownerEmailMd5Hash {
-		logger.Info("docTkn verified")
-		return nil
+// fromUnixSecs reads a time kept in the contract
+func fromUnixSecs(secs *big.Int) time.Time {
+	if secs == nil || secs.Sign() == 0 {
+		return time.Time{}
 	}
-	return errors.New("docTkn verification failed")
+	return time.Unix(secs.Int64(), 0).UTC()
 }
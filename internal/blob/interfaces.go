@@ -2,14 +2,69 @@ package blob
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 )
 
+// ErrNotFound is returned for an object which is not in blob store
+var ErrNotFound = errors.New("object not found")
+
+// MinComposePartSize is the smallest size of the objects composed to a document, other than the last one
+const MinComposePartSize = 5 << 20
+
 // OpsIf is the interface for blob store operations
 type OpsIf interface {
-	// Put is used to put a document in blob store
-	Put(ctx context.Context, doc io.Reader, size int64) (docId string, err error)
+	// Put is used to put a document in blob store. size is -1 when the length of doc is unknown,
+	// in which case it is streamed to blob store in parts.
+	Put(ctx context.Context, doc io.Reader, size int64, meta ObjMeta) (docId string, err error)
+
+	// Get is used to get a document from blob store, or only the given range of it when rng is set
+	Get(ctx context.Context, docId string, rng *ByteRange) (doc io.Reader, err error)
+
+	// Stat is used to get the details of a document in blob store, it returns ErrNotFound for unknown documents
+	Stat(ctx context.Context, docId string) (ObjInfo, error)
+
+	// PutAt is used to put an object in blob store under the given name, size can be -1 as with Put
+	PutAt(ctx context.Context, objName string, obj io.Reader, size int64) error
+
+	// Delete is used to remove an object from blob store
+	Delete(ctx context.Context, objName string) error
+
+	// Compose is used to concatenate objects in order to a new document within blob store, without them leaving
+	// blob store. Every object but the last must be at least MinComposePartSize bytes.
+	Compose(ctx context.Context, objNames []string) (docId string, err error)
+
+	// PresignGet is used to get a url which downloads a document without credentials until it expires.
+	// The document is served with the given metadata instead of the one it was stored with.
+	PresignGet(ctx context.Context, docId string, expiry time.Duration, meta ObjMeta) (url string, err error)
+
+	// PresignPut is used to get a url which uploads an object under the given name without credentials
+	// until it expires
+	PresignPut(ctx context.Context, objName string, expiry time.Duration) (url string, err error)
+}
+
+// ObjMeta is the metadata stored along with a document, it is served as is by blob store
+type ObjMeta struct {
+	ContentType        string
+	ContentDisposition string
+}
+
+// ObjInfo holds the details of a document in blob store
+type ObjInfo struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// ByteRange is a range of the bytes of a document, from Start to End inclusive as in http ranges
+type ByteRange struct {
+	Start int64
+	End   int64
+}
 
-	// Get is used to get a document from blob store
-	Get(ctx context.Context, docId string) (doc io.Reader, err error)
+// Len is the number of bytes in the range
+func (r ByteRange) Len() int64 {
+	return r.End - r.Start + 1
 }
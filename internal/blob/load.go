@@ -39,6 +39,8 @@ func loadImpls(_ context.Context) error {
 		secretAccessKey := props.MustGetString("minio.access.secret.key")
 		useSsl := props.MustGetBool("minio.use.ssl")
 		bucketName := props.MustGetString("minio.app.bucket.name")
+		partSize := props.MustGetUint64("minio.upload.part.size")
+		concurrency := props.MustGetUint("minio.upload.concurrency")
 		minioClient, err := minio.New(blobStoreUrl, &minio.Options{
 			Creds:  credentials.NewStaticV4(keyId, secretAccessKey, ""),
 			Secure: useSsl,
@@ -46,10 +48,26 @@ func loadImpls(_ context.Context) error {
 		if err != nil {
 			return fmt.Errorf("failed to create minio client - %w", err)
 		}
-		var blobExec OpsIf = &Minio{
-			bucketName: bucketName,
-			client:     minioClient,
+		blobStore := &Minio{
+			bucketName:  bucketName,
+			client:      minioClient,
+			partSize:    partSize,
+			concurrency: concurrency,
 		}
+
+		// presigned urls are signed for the endpoint clients reach blob store at. The region is set
+		// as signing urls for it must not look the bucket location up, it may not be reachable from here.
+		if presignUrl := props.GetString("minio.presign.endpoint.url", ""); presignUrl != "" {
+			blobStore.presigner, err = minio.New(presignUrl, &minio.Options{
+				Creds:  credentials.NewStaticV4(keyId, secretAccessKey, ""),
+				Secure: props.GetBool("minio.presign.use.ssl", useSsl),
+				Region: props.GetString("minio.region", "us-east-1"),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create minio presign client - %w", err)
+			}
+		}
+		var blobExec OpsIf = blobStore
 		concreteImpls[blobExecKey] = blobExec
 	}
 	return nil
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
 	minio "github.com/minio/minio-go/v7"
@@ -16,14 +18,33 @@ import (
 type Minio struct {
 	bucketName string
 	client     *minio.Client
+	// presigner signs presigned urls for the endpoint clients reach blob store at, when it differs from client's
+	presigner *minio.Client
+
+	// partSize and concurrency tune the multipart upload of streams whose size is unknown,
+	// which buffers up to partSize * concurrency bytes in memory
+	partSize    uint64
+	concurrency uint
+}
+
+// putOpts returns the options used for uploading objects, size is -1 when unknown
+func (m *Minio) putOpts(size int64) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{SendContentMd5: true, PartSize: m.partSize}
+	if size < 0 {
+		opts.NumThreads = m.concurrency
+		opts.ConcurrentStreamParts = m.concurrency > 1
+	}
+	return opts
 }
 
-// Put uploads a document to the Minio blob store
-func (m *Minio) Put(ctx context.Context, doc io.Reader, size int64) (docId string, err error) {
+// Put uploads a document to the Minio blob store. size can be -1 when unknown.
+func (m *Minio) Put(ctx context.Context, doc io.Reader, size int64, meta ObjMeta) (docId string, err error) {
 	logger := log.GetLogger(ctx)
 	docId = uuid.New().String()
 	logger.Info("started uploading document to minio", zap.String("docId", docId))
-	_, err = m.client.PutObject(ctx, m.bucketName, docId, doc, size, minio.PutObjectOptions{SendContentMd5: true})
+	opts := m.putOpts(size)
+	opts.ContentType, opts.ContentDisposition = meta.ContentType, meta.ContentDisposition
+	_, err = m.client.PutObject(ctx, m.bucketName, docId, doc, size, opts)
 	if err != nil {
 		err = fmt.Errorf("failed to upload - %w", err)
 		logger.Error("failed to upload document", zap.String("docId", docId), zap.Error(err))
@@ -33,11 +54,17 @@ func (m *Minio) Put(ctx context.Context, doc io.Reader, size int64) (docId strin
 	return
 }
 
-// Get downloads a document from the Minio blob store
-func (m *Minio) Get(ctx context.Context, docId string) (doc io.Reader, err error) {
+// Get downloads a document, or the given range of it, from the Minio blob store
+func (m *Minio) Get(ctx context.Context, docId string, rng *ByteRange) (doc io.Reader, err error) {
 	logger := log.GetLogger(ctx)
-	logger.Info("started downloading document", zap.String("docId", docId))
-	obj, err := m.client.GetObject(ctx, m.bucketName, docId, minio.GetObjectOptions{})
+	logger.Info("started downloading document", zap.String("docId", docId), zap.Any("range", rng))
+	opts := minio.GetObjectOptions{}
+	if rng != nil {
+		if err = opts.SetRange(rng.Start, rng.End); err != nil {
+			return nil, fmt.Errorf("invalid range - %w", err)
+		}
+	}
+	obj, err := m.client.GetObject(ctx, m.bucketName, docId, opts)
 	if err != nil {
 		err = fmt.Errorf("failed to download - %w", err)
 		logger.Error("failed to download document", zap.String("docId", docId), zap.Error(err))
@@ -46,3 +73,105 @@ func (m *Minio) Get(ctx context.Context, docId string) (doc io.Reader, err error
 	logger.Info("completed downloading document", zap.String("docId", docId))
 	return obj, nil
 }
+
+// Stat gets the details of a document in the Minio blob store
+func (m *Minio) Stat(ctx context.Context, docId string) (ObjInfo, error) {
+	logger := log.GetLogger(ctx)
+	info, err := m.client.StatObject(ctx, m.bucketName, docId, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			logger.Warn("document not found in minio", zap.String("docId", docId))
+			return ObjInfo{}, fmt.Errorf("failed to stat - %w", ErrNotFound)
+		}
+		logger.Error("failed to stat document", zap.String("docId", docId), zap.Error(err))
+		return ObjInfo{}, fmt.Errorf("failed to stat - %w", err)
+	}
+	return ObjInfo{
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// PutAt uploads an object to the Minio blob store under the given name. size can be -1 when unknown.
+func (m *Minio) PutAt(ctx context.Context, objName string, obj io.Reader, size int64) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started uploading object to minio", zap.String("objName", objName))
+	_, err := m.client.PutObject(ctx, m.bucketName, objName, obj, size, m.putOpts(size))
+	if err != nil {
+		logger.Error("failed to upload object", zap.String("objName", objName), zap.Error(err))
+		return fmt.Errorf("failed to upload - %w", err)
+	}
+	logger.Info("completed uploading object to minio", zap.String("objName", objName))
+	return nil
+}
+
+// Delete removes an object from the Minio blob store
+func (m *Minio) Delete(ctx context.Context, objName string) error {
+	logger := log.GetLogger(ctx)
+	err := m.client.RemoveObject(ctx, m.bucketName, objName, minio.RemoveObjectOptions{})
+	if err != nil {
+		logger.Error("failed to delete object", zap.String("objName", objName), zap.Error(err))
+		return fmt.Errorf("failed to delete - %w", err)
+	}
+	logger.Info("deleted object from minio", zap.String("objName", objName))
+	return nil
+}
+
+// Compose concatenates objects to a new document in the Minio blob store, server side
+func (m *Minio) Compose(ctx context.Context, objNames []string) (docId string, err error) {
+	logger := log.GetLogger(ctx)
+	docId = uuid.New().String()
+	logger.Info("started composing objects in minio", zap.Strings("objNames", objNames), zap.String("docId", docId))
+	srcs := make([]minio.CopySrcOptions, 0, len(objNames))
+	for _, objName := range objNames {
+		srcs = append(srcs, minio.CopySrcOptions{Bucket: m.bucketName, Object: objName})
+	}
+	// compose copies objects larger than the 5 GiB a single copy is limited to in parts
+	_, err = m.client.ComposeObject(ctx, minio.CopyDestOptions{Bucket: m.bucketName, Object: docId}, srcs...)
+	if err != nil {
+		logger.Error("failed to compose objects", zap.Strings("objNames", objNames), zap.Error(err))
+		return "", fmt.Errorf("failed to compose - %w", err)
+	}
+	logger.Info("completed composing objects in minio", zap.String("docId", docId))
+	return docId, nil
+}
+
+// PresignGet signs a url downloading a document from the Minio blob store until expiry
+func (m *Minio) PresignGet(ctx context.Context, docId string, expiry time.Duration, meta ObjMeta) (string, error) {
+	logger := log.GetLogger(ctx)
+	params := url.Values{}
+	if meta.ContentType != "" {
+		params.Set("response-content-type", meta.ContentType)
+	}
+	if meta.ContentDisposition != "" {
+		params.Set("response-content-disposition", meta.ContentDisposition)
+	}
+	u, err := m.signer().PresignedGetObject(ctx, m.bucketName, docId, expiry, params)
+	if err != nil {
+		logger.Error("failed to presign document download", zap.String("docId", docId), zap.Error(err))
+		return "", fmt.Errorf("failed to presign - %w", err)
+	}
+	logger.Info("presigned document download", zap.String("docId", docId), zap.Duration("expiry", expiry))
+	return u.String(), nil
+}
+
+// PresignPut signs a url uploading an object to the Minio blob store until expiry
+func (m *Minio) PresignPut(ctx context.Context, objName string, expiry time.Duration) (string, error) {
+	logger := log.GetLogger(ctx)
+	u, err := m.signer().PresignedPutObject(ctx, m.bucketName, objName, expiry)
+	if err != nil {
+		logger.Error("failed to presign object upload", zap.String("objName", objName), zap.Error(err))
+		return "", fmt.Errorf("failed to presign - %w", err)
+	}
+	logger.Info("presigned object upload", zap.String("objName", objName), zap.Duration("expiry", expiry))
+	return u.String(), nil
+}
+
+func (m *Minio) signer() *minio.Client {
+	if m.presigner != nil {
+		return m.presigner
+	}
+	return m.client
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS document_chunks CASCADE;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS chunk_size,
+    DROP COLUMN IF EXISTS hash_algo;
@@ -0,0 +1,17 @@
+-- hash_algo records how doc_hash was computed, chunk_size is only set for chunked (merkle) hashes.
+ALTER TABLE documents
+    ADD COLUMN hash_algo  VARCHAR(20) NOT NULL DEFAULT 'md5',
+    ADD COLUMN chunk_size BIGINT;
+
+-- document_chunks maintains the per-chunk hashes of documents hashed as a merkle tree,
+-- so that a range of a document can be proven intact without rehashing the whole document.
+CREATE TABLE document_chunks
+(
+    document_id BIGINT      NOT NULL,
+    chunk_index INT         NOT NULL,
+    chunk_hash  VARCHAR(64) NOT NULL,
+    PRIMARY KEY (document_id, chunk_index)
+);
+
+ALTER TABLE document_chunks
+    ADD CONSTRAINT document_chunks_document_fkey FOREIGN KEY (document_id) REFERENCES documents (id) ON DELETE CASCADE;
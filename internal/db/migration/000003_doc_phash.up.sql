@@ -0,0 +1,4 @@
+-- phash holds the 64-bit perceptual (difference) hash of image documents, it is used to find
+-- re-encoded or resized copies of an already uploaded image by hamming distance.
+ALTER TABLE documents
+    ADD COLUMN phash BIGINT;
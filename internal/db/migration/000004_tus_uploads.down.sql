@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS update_tus_uploads_change_timestamp ON tus_uploads;
+
+DROP TABLE IF EXISTS tus_upload_parts CASCADE;
+DROP TABLE IF EXISTS tus_uploads CASCADE;
+
+DROP TYPE IF EXISTS tus_upload_status;
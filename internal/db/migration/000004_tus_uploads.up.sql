@@ -0,0 +1,42 @@
+-- specifies the state of a resumable upload
+CREATE TYPE tus_upload_status AS ENUM (
+    'CREATED',
+    'COMPLETED',
+    'TERMINATED'
+    );
+
+-- tus_uploads maintains the state of resumable (tus protocol) uploads.
+-- metadata holds the document metadata sent by the client when creating the upload.
+CREATE TABLE tus_uploads
+(
+    id              BIGSERIAL PRIMARY KEY,
+    upload_id       VARCHAR(50)       NOT NULL UNIQUE,
+    upload_length   BIGINT            NOT NULL,
+    upload_offset   BIGINT            NOT NULL DEFAULT 0,
+    metadata        JSONB,
+    status          tus_upload_status NOT NULL DEFAULT 'CREATED',
+    doc_id          VARCHAR(50),
+    created_at      timestamptz       NOT NULL DEFAULT NOW(),
+    last_updated_at timestamptz       NOT NULL DEFAULT NOW()
+);
+
+-- tus_upload_parts maintains the blob store objects holding the received parts of a resumable upload.
+CREATE TABLE tus_upload_parts
+(
+    upload_id   VARCHAR(50)  NOT NULL,
+    part_offset BIGINT       NOT NULL,
+    part_size   BIGINT       NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (upload_id, part_offset)
+);
+
+ALTER TABLE tus_upload_parts
+    ADD CONSTRAINT tus_upload_parts_upload_fkey FOREIGN KEY (upload_id) REFERENCES tus_uploads (upload_id) ON DELETE CASCADE;
+
+CREATE TRIGGER update_tus_uploads_change_timestamp
+    BEFORE
+        UPDATE
+    ON
+        tus_uploads
+    FOR EACH ROW
+EXECUTE FUNCTION update_change_timestamp_column();
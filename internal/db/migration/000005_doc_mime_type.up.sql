@@ -0,0 +1,3 @@
+-- mime_type is the content type sniffed from the document content on upload, it is served on download.
+ALTER TABLE documents
+    ADD COLUMN mime_type VARCHAR(255) NOT NULL DEFAULT 'application/octet-stream';
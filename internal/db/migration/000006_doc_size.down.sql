@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS documents_user_id_idx;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS file_size;
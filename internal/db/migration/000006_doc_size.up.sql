@@ -0,0 +1,5 @@
+-- file_size is the size of the document content in bytes, it is summed up for per-owner upload quotas.
+ALTER TABLE documents
+    ADD COLUMN file_size BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX documents_user_id_idx ON documents (user_id);
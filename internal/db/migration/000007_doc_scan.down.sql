@@ -0,0 +1,3 @@
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS scan_engine,
+    DROP COLUMN IF EXISTS scan_status;
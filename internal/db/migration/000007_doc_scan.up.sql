@@ -0,0 +1,6 @@
+-- scan_status is the outcome of the malware scan of a document on upload, CLEAN or CLEAN_PARTIAL when only
+-- the start of a large document was scanned, and scan_engine is the scan engine and signature database version.
+-- both are null for documents uploaded while malware scanning was disabled.
+ALTER TABLE documents
+    ADD COLUMN scan_status VARCHAR(20),
+    ADD COLUMN scan_engine VARCHAR(255);
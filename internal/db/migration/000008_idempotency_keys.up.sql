@@ -0,0 +1,15 @@
+-- idempotency_keys maintains the Idempotency-Key headers of mutating requests, so a retried request gets the
+-- response of the original one instead of being processed again. fingerprint is the hash of the request
+-- the key was first used with, and response_status stays null while that request is in progress.
+CREATE TABLE idempotency_keys
+(
+    idem_key         VARCHAR(255) PRIMARY KEY,
+    fingerprint      VARCHAR(64) NOT NULL,
+    response_status  INT,
+    response_headers JSONB,
+    response_body    BYTEA,
+    created_at       timestamptz NOT NULL DEFAULT NOW(),
+    expires_at       timestamptz NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
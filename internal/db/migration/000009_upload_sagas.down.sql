@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_upload_sagas_change_timestamp ON upload_sagas;
+
+DROP TABLE IF EXISTS upload_sagas CASCADE;
+
+DROP TYPE IF EXISTS upload_saga_state;
@@ -0,0 +1,38 @@
+-- specifies the state of an upload, received and stored documents are minted and then saved.
+-- uploads which failed before minting are rolled back, and the ones which could not be saved are failed.
+CREATE TYPE upload_saga_state AS ENUM (
+    'RECEIVED',
+    'STORED',
+    'MINTED',
+    'SAVED',
+    'ROLLED_BACK',
+    'FAILED'
+    );
+
+-- upload_sagas maintains the progress of uploads through blob store, blockchain and db, so uploads
+-- interrupted by a failure or a crash can be finished or rolled back.
+-- doc_meta holds the document metadata to be saved, attempts and last_error the failed recovery attempts.
+CREATE TABLE upload_sagas
+(
+    id              BIGSERIAL PRIMARY KEY,
+    saga_id         VARCHAR(50)       NOT NULL UNIQUE,
+    state           upload_saga_state NOT NULL DEFAULT 'RECEIVED',
+    doc_meta        JSONB             NOT NULL,
+    doc_id          VARCHAR(50),
+    bc_tkn_id       VARCHAR(255),
+    attempts        INT               NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      timestamptz       NOT NULL DEFAULT NOW(),
+    last_updated_at timestamptz       NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX upload_sagas_pending_idx ON upload_sagas (last_updated_at)
+    WHERE state IN ('RECEIVED', 'STORED', 'MINTED');
+
+CREATE TRIGGER update_upload_sagas_change_timestamp
+    BEFORE
+        UPDATE
+    ON
+        upload_sagas
+    FOR EACH ROW
+EXECUTE FUNCTION update_change_timestamp_column();
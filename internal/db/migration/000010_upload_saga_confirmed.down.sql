@@ -0,0 +1,4 @@
+-- enum values can not be dropped, confirmed uploads are moved back to minted instead
+UPDATE upload_sagas
+SET state = 'MINTED'
+WHERE state = 'CONFIRMED';
@@ -0,0 +1,2 @@
+-- minted documents of asynchronous uploads are confirmed in blockchain before they are saved
+ALTER TYPE upload_saga_state ADD VALUE IF NOT EXISTS 'CONFIRMED' AFTER 'MINTED';
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_doc_claims_change_timestamp ON doc_claims;
+
+DROP TABLE IF EXISTS doc_claims CASCADE;
+
+DROP TYPE IF EXISTS doc_claim_status;
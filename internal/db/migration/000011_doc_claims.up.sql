@@ -0,0 +1,30 @@
+-- specifies the state of a claim on a document, pending claims are accepted or rejected by the document owner
+CREATE TYPE doc_claim_status AS ENUM (
+    'PENDING',
+    'ACCEPTED',
+    'REJECTED'
+    );
+
+-- doc_claims maintains the claims of co-ownership on documents, made by uploading a document which is already
+-- owned by someone else. a claimant has at most one claim on a document, uploading it again returns that claim.
+CREATE TABLE doc_claims
+(
+    id              BIGSERIAL PRIMARY KEY,
+    claim_id        VARCHAR(50)      NOT NULL UNIQUE,
+    doc_id          VARCHAR(50)      NOT NULL REFERENCES documents (doc_id),
+    claimant_email  VARCHAR(100)     NOT NULL,
+    first_name      VARCHAR(100)     NOT NULL,
+    last_name       VARCHAR(100)     NOT NULL,
+    status          doc_claim_status NOT NULL DEFAULT 'PENDING',
+    created_at      timestamptz      NOT NULL DEFAULT NOW(),
+    last_updated_at timestamptz      NOT NULL DEFAULT NOW(),
+    UNIQUE (doc_id, claimant_email)
+);
+
+CREATE TRIGGER update_doc_claims_change_timestamp
+    BEFORE
+        UPDATE
+    ON
+        doc_claims
+    FOR EACH ROW
+EXECUTE FUNCTION update_change_timestamp_column();
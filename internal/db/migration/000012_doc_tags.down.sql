@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS documents_tags_idx;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS tags;
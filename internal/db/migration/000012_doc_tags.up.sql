@@ -0,0 +1,6 @@
+-- tags are the free-form key/value metadata of a document as a json object of strings, such as issuer or courseId.
+-- the gin index serves containment (@>) filters on tags.
+ALTER TABLE documents
+    ADD COLUMN tags JSONB;
+
+CREATE INDEX documents_tags_idx ON documents USING GIN (tags jsonb_path_ops);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS documents_valid_until_idx;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS valid_from,
+    DROP COLUMN IF EXISTS valid_until,
+    DROP COLUMN IF EXISTS expiry_notified_at;
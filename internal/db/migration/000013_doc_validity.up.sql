@@ -0,0 +1,8 @@
+-- valid_from and valid_until are the optional window in which a document, such as a certificate or an id, is valid.
+-- expiry_notified_at is when the document was last announced as about to expire.
+ALTER TABLE documents
+    ADD COLUMN valid_from         timestamptz,
+    ADD COLUMN valid_until        timestamptz,
+    ADD COLUMN expiry_notified_at timestamptz;
+
+CREATE INDEX documents_valid_until_idx ON documents (valid_until) WHERE valid_until IS NOT NULL;
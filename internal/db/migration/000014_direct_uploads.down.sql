@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_direct_uploads_change_timestamp ON direct_uploads;
+
+DROP TABLE IF EXISTS direct_uploads CASCADE;
+
+DROP TYPE IF EXISTS direct_upload_status;
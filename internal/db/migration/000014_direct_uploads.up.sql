@@ -0,0 +1,29 @@
+-- specifies the state of a direct-to-blob upload
+CREATE TYPE direct_upload_status AS ENUM (
+    'CREATED',
+    'FINALISING',
+    'COMPLETED'
+    );
+
+-- direct_uploads maintains the state of uploads which clients put in blob store through a presigned url.
+-- metadata holds the document metadata sent by the client when creating the upload.
+CREATE TABLE direct_uploads
+(
+    id              BIGSERIAL PRIMARY KEY,
+    upload_id       VARCHAR(50)          NOT NULL UNIQUE,
+    upload_length   BIGINT               NOT NULL,
+    metadata        JSONB,
+    status          direct_upload_status NOT NULL DEFAULT 'CREATED',
+    doc_id          VARCHAR(50),
+    expires_at      timestamptz          NOT NULL,
+    created_at      timestamptz          NOT NULL DEFAULT NOW(),
+    last_updated_at timestamptz          NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_direct_uploads_change_timestamp
+    BEFORE
+        UPDATE
+    ON
+        direct_uploads
+    FOR EACH ROW
+EXECUTE FUNCTION update_change_timestamp_column();
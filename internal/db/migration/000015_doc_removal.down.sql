@@ -0,0 +1,3 @@
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS revoked_at;
@@ -0,0 +1,5 @@
+-- deleted_at is when a document was withdrawn by its owner, revoked_at is when it was revoked as no longer
+-- authentic. Removed documents are kept for their history, they are no longer served.
+ALTER TABLE documents
+    ADD COLUMN deleted_at timestamptz,
+    ADD COLUMN revoked_at timestamptz;
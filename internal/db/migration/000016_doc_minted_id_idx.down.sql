@@ -0,0 +1 @@
+DROP INDEX IF EXISTS documents_doc_minted_id_idx;
@@ -0,0 +1,2 @@
+-- documents are looked up by the blockchain token they were minted with
+CREATE INDEX documents_doc_minted_id_idx ON documents (doc_minted_id);
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS documents_user_id_idx ON documents (user_id);
+
+DROP INDEX IF EXISTS documents_user_uploaded_idx;
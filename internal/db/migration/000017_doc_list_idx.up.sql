@@ -0,0 +1,5 @@
+-- the documents of an owner are listed newest first, a page at a time from where the previous page ended.
+-- the index serves the lookups by owner the user_id index was there for, so it is dropped.
+CREATE INDEX documents_user_uploaded_idx ON documents (user_id, uploaded_at DESC, id DESC);
+
+DROP INDEX IF EXISTS documents_user_id_idx;
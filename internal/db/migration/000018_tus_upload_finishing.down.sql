@@ -0,0 +1,4 @@
+-- enum values can not be dropped, uploads being finished are moved back to created instead
+UPDATE tus_uploads
+SET status = 'CREATED'
+WHERE status = 'FINISHING';
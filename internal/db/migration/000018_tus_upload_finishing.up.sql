@@ -0,0 +1,2 @@
+-- fully received resumable uploads are claimed for finishing, so that a retried last part does not finish twice
+ALTER TYPE tus_upload_status ADD VALUE IF NOT EXISTS 'FINISHING' AFTER 'CREATED';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS upload_sagas_pending_idx;
+
+CREATE INDEX upload_sagas_pending_idx ON upload_sagas (last_updated_at)
+    WHERE state IN ('RECEIVED', 'STORED', 'MINTED');
@@ -0,0 +1,5 @@
+-- confirmed uploads are pending too, kept apart from the migration adding the enum value so it is committed first
+DROP INDEX IF EXISTS upload_sagas_pending_idx;
+
+CREATE INDEX upload_sagas_pending_idx ON upload_sagas (last_updated_at)
+    WHERE state IN ('RECEIVED', 'STORED', 'MINTED', 'CONFIRMED');
@@ -0,0 +1,2 @@
+ALTER TABLE doc_claims
+    DROP COLUMN IF EXISTS decision_token_hash;
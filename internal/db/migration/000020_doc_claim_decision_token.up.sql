@@ -0,0 +1,4 @@
+-- claims are decided with a one-time token sent to the document owner, only its sha256 digest is kept.
+-- pending claims made before have none, they get one when the claimant uploads the document again.
+ALTER TABLE doc_claims
+    ADD COLUMN decision_token_hash VARCHAR(64) NOT NULL DEFAULT '';
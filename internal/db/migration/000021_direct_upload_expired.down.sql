@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS direct_uploads_created_expires_idx;
+
+-- enum values can not be dropped, expired uploads are moved back to created instead
+UPDATE direct_uploads
+SET status = 'CREATED'
+WHERE status = 'EXPIRED';
@@ -0,0 +1,5 @@
+-- direct uploads not finalised before they expire are cleaned up in background, along with what was put for them
+ALTER TYPE direct_upload_status ADD VALUE IF NOT EXISTS 'EXPIRED' AFTER 'COMPLETED';
+
+CREATE INDEX IF NOT EXISTS direct_uploads_created_expires_idx ON direct_uploads (expires_at)
+    WHERE status = 'CREATED';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS documents_phash_b0_idx;
+DROP INDEX IF EXISTS documents_phash_b1_idx;
+DROP INDEX IF EXISTS documents_phash_b2_idx;
+DROP INDEX IF EXISTS documents_phash_b3_idx;
@@ -0,0 +1,7 @@
+-- the perceptual hash is split in four 16 bit bands, each of them indexed. a hash within 4r+3 bits of another
+-- has a band within r bits of the same band of the other, so similar images are only looked for among the
+-- documents having a band near the same band of the image.
+CREATE INDEX IF NOT EXISTS documents_phash_b0_idx ON documents (((phash >> 48) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS documents_phash_b1_idx ON documents (((phash >> 32) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS documents_phash_b2_idx ON documents (((phash >> 16) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS documents_phash_b3_idx ON documents ((phash & 65535)) WHERE phash IS NOT NULL;
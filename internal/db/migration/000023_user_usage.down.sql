@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_user_usage_change_timestamp ON user_usage;
+
+DROP TABLE IF EXISTS user_usage;
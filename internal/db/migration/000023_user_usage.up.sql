@@ -0,0 +1,32 @@
+-- user_usage maintains how many documents, and how many bytes of content, an owner has uploaded, counting
+-- the uploads in progress. uploads reserve their usage here when they start, so concurrent uploads can not
+-- take an owner past their quota, and the usage of uploads which are rolled back or failed is released.
+CREATE TABLE user_usage
+(
+    owner_email     VARCHAR(255) PRIMARY KEY,
+    docs            BIGINT      NOT NULL DEFAULT 0,
+    bytes           BIGINT      NOT NULL DEFAULT 0,
+    created_at      timestamptz NOT NULL DEFAULT NOW(),
+    last_updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO user_usage (owner_email, docs, bytes)
+SELECT t.owner_email, sum(t.docs), sum(t.bytes)
+FROM (SELECT u.email_id AS owner_email, count(d.id) AS docs, sum(d.file_size) AS bytes
+      FROM documents d
+               JOIN users u ON u.id = d.user_id
+      GROUP BY u.email_id
+      UNION ALL
+      SELECT s.doc_meta ->> 'ownerEmail', count(s.id), sum(coalesce((s.doc_meta ->> 'docSize')::BIGINT, 0))
+      FROM upload_sagas s
+      WHERE s.state IN ('RECEIVED', 'STORED', 'MINTED', 'CONFIRMED')
+      GROUP BY s.doc_meta ->> 'ownerEmail') t
+GROUP BY t.owner_email;
+
+CREATE TRIGGER update_user_usage_change_timestamp
+    BEFORE
+        UPDATE
+    ON
+        user_usage
+    FOR EACH ROW
+EXECUTE FUNCTION update_change_timestamp_column();
@@ -0,0 +1,4 @@
+-- requests in progress hold their idempotency key until locked_until, their lease is renewed while they run.
+-- a key whose lease lapsed, as the instance running its request went down, is taken over by a retry.
+ALTER TABLE idempotency_keys
+    ADD COLUMN locked_until timestamptz NOT NULL DEFAULT NOW();
@@ -0,0 +1,34 @@
+-- name: AddDirectUpload :one
+INSERT INTO direct_uploads (upload_id, upload_length, metadata, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetDirectUpload :one
+SELECT *
+FROM direct_uploads
+WHERE upload_id = $1
+LIMIT 1;
+
+-- name: ClaimDirectUpload :one
+UPDATE direct_uploads
+SET status = 'FINALISING'
+WHERE upload_id = @upload_id
+  AND ((status = 'CREATED' AND expires_at > NOW()) OR (status = 'FINALISING' AND last_updated_at < @stale_before))
+RETURNING *;
+
+-- name: ClaimExpiredDirectUploads :many
+UPDATE direct_uploads
+SET status = 'EXPIRED'
+WHERE id IN (SELECT u.id
+             FROM direct_uploads u
+             WHERE u.status = 'CREATED'
+               AND u.expires_at <= @expired_before
+             ORDER BY u.expires_at
+             LIMIT @max_uploads FOR UPDATE SKIP LOCKED)
+RETURNING *;
+
+-- name: UpdateDirectUploadStatus :exec
+UPDATE direct_uploads
+SET status = $2,
+    doc_id = $3
+WHERE upload_id = $1;
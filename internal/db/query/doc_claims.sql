@@ -0,0 +1,20 @@
+-- name: AddDocClaim :one
+INSERT INTO doc_claims (claim_id, doc_id, claimant_email, first_name, last_name, decision_token_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (doc_id, claimant_email) DO UPDATE
+    SET decision_token_hash = CASE
+                                  WHEN doc_claims.decision_token_hash = '' THEN EXCLUDED.decision_token_hash
+                                  ELSE doc_claims.decision_token_hash END
+RETURNING *;
+
+-- name: GetDocClaim :one
+SELECT *
+FROM doc_claims
+WHERE claim_id = $1
+LIMIT 1;
+
+-- name: DecideDocClaim :execrows
+UPDATE doc_claims
+SET status = $1
+WHERE claim_id = $2
+  AND status = 'PENDING';
@@ -5,8 +5,9 @@ WHERE doc_id = $1
 LIMIT 1;
 
 -- name: AddDoc :one
-INSERT INTO documents (doc_id, title, description, file_name, doc_hash, doc_minted_id, user_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
+INSERT INTO documents (doc_id, title, description, file_name, doc_hash, doc_minted_id, user_id, hash_algo, chunk_size,
+                       phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 RETURNING *;
 
 -- name: GetDocByHash :one
@@ -14,3 +15,78 @@ SELECT *
 FROM documents
 WHERE doc_hash = $1
 LIMIT 1;
+
+-- name: GetDocByMintedId :one
+SELECT *
+FROM documents
+WHERE doc_minted_id = $1
+LIMIT 1;
+
+-- name: AddDocChunks :exec
+INSERT INTO document_chunks (document_id, chunk_index, chunk_hash)
+SELECT @document_id::BIGINT, (c.ord - 1)::INT, c.chunk_hash
+FROM unnest(@chunk_hashes::TEXT[]) WITH ORDINALITY AS c(chunk_hash, ord);
+
+-- name: GetDocChunks :many
+SELECT chunk_hash
+FROM document_chunks
+WHERE document_id = $1
+ORDER BY chunk_index;
+
+-- name: GetSimilarDocs :many
+SELECT doc_id,
+       bit_count((phash # @phash::BIGINT)::BIT(64))::INT AS distance
+FROM documents
+WHERE phash IS NOT NULL
+  AND (((phash >> 48) & 65535) = ANY (@band0::BIGINT[])
+    OR ((phash >> 32) & 65535) = ANY (@band1::BIGINT[])
+    OR ((phash >> 16) & 65535) = ANY (@band2::BIGINT[])
+    OR (phash & 65535) = ANY (@band3::BIGINT[]))
+  AND doc_hash <> @doc_hash
+  AND bit_count((phash # @phash::BIGINT)::BIT(64)) <= @max_distance::INT
+ORDER BY distance, id
+LIMIT @max_results::INT;
+
+-- name: SearchDocsByTags :many
+SELECT d.*
+FROM documents d
+         JOIN users u ON u.id = d.user_id
+WHERE u.email_id = @owner_email
+  AND coalesce(d.tags, '{}'::JSONB) @> @tags::JSONB
+ORDER BY d.uploaded_at DESC, d.id DESC
+LIMIT @max_results::INT;
+
+-- name: ListUserDocs :many
+SELECT *
+FROM documents
+WHERE user_id = @user_id
+  AND (sqlc.narg('uploaded_after')::TIMESTAMPTZ IS NULL OR uploaded_at >= sqlc.narg('uploaded_after'))
+  AND (sqlc.narg('uploaded_before')::TIMESTAMPTZ IS NULL OR uploaded_at < sqlc.narg('uploaded_before'))
+  AND CASE @status::TEXT
+          WHEN 'active' THEN deleted_at IS NULL AND revoked_at IS NULL
+          WHEN 'deleted' THEN deleted_at IS NOT NULL AND revoked_at IS NULL
+          WHEN 'revoked' THEN revoked_at IS NOT NULL
+          ELSE TRUE
+    END
+  AND coalesce(tags, '{}'::JSONB) @> @tags::JSONB
+  AND (sqlc.narg('cursor_uploaded_at')::TIMESTAMPTZ IS NULL
+    OR (uploaded_at, id) < (sqlc.narg('cursor_uploaded_at'), @cursor_id::BIGINT))
+ORDER BY uploaded_at DESC, id DESC
+LIMIT @max_results::INT;
+
+-- name: ClaimExpiringDocs :many
+UPDATE documents
+SET expiry_notified_at = NOW()
+WHERE id IN (SELECT d.id
+             FROM documents d
+             WHERE d.valid_until > NOW()
+               AND d.valid_until <= @expiring_before
+               AND d.expiry_notified_at IS NULL
+             ORDER BY d.valid_until
+             LIMIT @max_docs FOR UPDATE SKIP LOCKED)
+RETURNING *;
+
+-- name: ReleaseDocExpiryNotice :exec
+UPDATE documents
+SET expiry_notified_at = NULL
+WHERE doc_id = $1;
@@ -0,0 +1,42 @@
+-- name: AddIdempotencyKey :execrows
+INSERT INTO idempotency_keys AS k (idem_key, fingerprint, expires_at, locked_until)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (idem_key) DO UPDATE
+    SET fingerprint      = excluded.fingerprint,
+        response_status  = NULL,
+        response_headers = NULL,
+        response_body    = NULL,
+        created_at       = NOW(),
+        expires_at       = excluded.expires_at,
+        locked_until     = excluded.locked_until
+WHERE k.expires_at <= NOW()
+   OR (k.response_status IS NULL AND k.locked_until <= NOW() AND k.fingerprint = excluded.fingerprint);
+
+-- name: GetIdempotencyKey :one
+SELECT *
+FROM idempotency_keys
+WHERE idem_key = $1
+LIMIT 1;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET response_status  = $2,
+    response_headers = $3,
+    response_body    = $4
+WHERE idem_key = $1;
+
+-- name: ExtendIdempotencyKey :exec
+UPDATE idempotency_keys
+SET locked_until = $2
+WHERE idem_key = $1
+  AND response_status IS NULL;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE
+FROM idempotency_keys
+WHERE idem_key = $1;
+
+-- name: DeleteExpiredIdempotencyKeys :exec
+DELETE
+FROM idempotency_keys
+WHERE expires_at <= NOW();
@@ -0,0 +1,42 @@
+-- name: AddTusUpload :one
+INSERT INTO tus_uploads (upload_id, upload_length, metadata)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetTusUpload :one
+SELECT *
+FROM tus_uploads
+WHERE upload_id = $1
+LIMIT 1;
+
+-- name: AdvanceTusUpload :one
+UPDATE tus_uploads
+SET upload_offset = upload_offset + @part_size
+WHERE upload_id = @upload_id
+  AND upload_offset = @part_offset
+  AND status = 'CREATED'
+RETURNING *;
+
+-- name: ClaimTusUpload :one
+UPDATE tus_uploads
+SET status = 'FINISHING'
+WHERE upload_id = @upload_id
+  AND upload_offset = upload_length
+  AND (status = 'CREATED' OR (status = 'FINISHING' AND last_updated_at < @stale_before))
+RETURNING *;
+
+-- name: UpdateTusUploadStatus :exec
+UPDATE tus_uploads
+SET status = $2,
+    doc_id = $3
+WHERE upload_id = $1;
+
+-- name: AddTusUploadPart :exec
+INSERT INTO tus_upload_parts (upload_id, part_offset, part_size, object_name)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetTusUploadParts :many
+SELECT *
+FROM tus_upload_parts
+WHERE upload_id = $1
+ORDER BY part_offset;
@@ -0,0 +1,43 @@
+-- name: AddUploadSaga :exec
+INSERT INTO upload_sagas (saga_id, state, doc_meta, doc_id)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetUploadSaga :one
+SELECT *
+FROM upload_sagas
+WHERE saga_id = $1
+LIMIT 1;
+
+-- name: AdvanceUploadSaga :execrows
+UPDATE upload_sagas
+SET state      = @to_state,
+    doc_id     = COALESCE(sqlc.narg(doc_id), doc_id),
+    bc_tkn_id  = COALESCE(sqlc.narg(bc_tkn_id), bc_tkn_id),
+    last_error = CASE WHEN @to_state IN ('ROLLED_BACK', 'FAILED') THEN last_error END
+WHERE saga_id = @saga_id
+  AND state = @from_state;
+
+-- name: ReleaseUploadSagaUsage :exec
+UPDATE user_usage u
+SET docs  = greatest(u.docs - 1, 0),
+    bytes = greatest(u.bytes - coalesce((s.doc_meta ->> 'docSize')::BIGINT, 0), 0)
+FROM upload_sagas s
+WHERE s.saga_id = $1
+  AND u.owner_email = s.doc_meta ->> 'ownerEmail';
+
+-- name: FailUploadSagaStep :exec
+UPDATE upload_sagas
+SET attempts   = attempts + 1,
+    last_error = $2
+WHERE saga_id = $1;
+
+-- name: ClaimStaleUploadSagas :many
+UPDATE upload_sagas
+SET last_updated_at = NOW()
+WHERE id IN (SELECT s.id
+             FROM upload_sagas s
+             WHERE s.state IN ('RECEIVED', 'STORED', 'MINTED', 'CONFIRMED')
+               AND s.last_updated_at < @stale_before
+             ORDER BY s.last_updated_at
+             LIMIT @max_sagas FOR UPDATE SKIP LOCKED)
+RETURNING *;
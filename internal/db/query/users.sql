@@ -14,3 +14,21 @@ LIMIT 1;
 INSERT INTO users (email_id, first_name, last_name, status)
 VALUES ($1, $2, $3, $4)
 RETURNING *;
+
+-- name: GetUserUsage :one
+SELECT coalesce(sum(docs), 0)::BIGINT AS doc_count, coalesce(sum(bytes), 0)::BIGINT AS doc_bytes
+FROM user_usage
+WHERE owner_email = $1;
+
+-- name: InitUserUsage :exec
+INSERT INTO user_usage (owner_email)
+VALUES ($1)
+ON CONFLICT (owner_email) DO NOTHING;
+
+-- name: ReserveUserUsage :execrows
+UPDATE user_usage
+SET docs  = docs + 1,
+    bytes = bytes + @size::BIGINT
+WHERE owner_email = @owner_email
+  AND (@max_docs::BIGINT = 0 OR docs + 1 <= @max_docs::BIGINT)
+  AND (@max_bytes::BIGINT = 0 OR bytes + @size::BIGINT <= @max_bytes::BIGINT);
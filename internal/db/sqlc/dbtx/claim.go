@@ -0,0 +1,105 @@
+package dbtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// statuses of a claim on a document
+const (
+	ClaimPending  = string(raw.DocClaimStatusPENDING)
+	ClaimAccepted = string(raw.DocClaimStatusACCEPTED)
+	ClaimRejected = string(raw.DocClaimStatusREJECTED)
+)
+
+// ErrClaimDecided is returned when a claim which was already accepted or rejected is decided again
+var ErrClaimDecided = errors.New("doc claim already decided")
+
+// DocClaim is a claim of co-ownership on a document, made by uploading a document owned by someone else
+type DocClaim struct {
+	ClaimId       string
+	DocId         string
+	ClaimantEmail string
+	FirstName     string
+	LastName      string
+	Status        string
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+	// DecisionTokenHash is the sha256 hex digest of the one-time token the owner decides the claim with
+	DecisionTokenHash string
+}
+
+// ClaimDoc records a pending claim on a document, unless the claimant already has a claim on it,
+// in which case the existing claim is returned. An existing claim keeps its decision token, unless it has none.
+func (store *Store) ClaimDoc(ctx context.Context, in DocClaim) (DocClaim, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming doc", zap.String("docId", in.DocId))
+	var out DocClaim
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		c, err := queries.AddDocClaim(ctx, raw.AddDocClaimParams{
+			ClaimID:           in.ClaimId,
+			DocID:             in.DocId,
+			ClaimantEmail:     in.ClaimantEmail,
+			FirstName:         in.FirstName,
+			LastName:          in.LastName,
+			DecisionTokenHash: in.DecisionTokenHash,
+		})
+		out = docClaim(c)
+		return err
+	})
+	return out, err
+}
+
+// GetDocClaim returns a claim on a document
+func (store *Store) GetDocClaim(ctx context.Context, claimId string) (DocClaim, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get doc claim", zap.String("claimId", claimId))
+	var out DocClaim
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		c, err := queries.GetDocClaim(ctx, claimId)
+		out = docClaim(c)
+		return err
+	})
+	return out, err
+}
+
+// DecideDocClaim accepts or rejects a pending claim. ErrClaimDecided is returned when it is not pending.
+func (store *Store) DecideDocClaim(ctx context.Context, claimId, status string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for deciding doc claim", zap.String("claimId", claimId),
+		zap.String("status", status))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		n, err := queries.DecideDocClaim(ctx, raw.DecideDocClaimParams{
+			Status:  raw.DocClaimStatus(status),
+			ClaimID: claimId,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("doc claim %s is not pending - %w", claimId, ErrClaimDecided)
+		}
+		return nil
+	})
+}
+
+func docClaim(c raw.DocClaim) DocClaim {
+	return DocClaim{
+		ClaimId:           c.ClaimID,
+		DocId:             c.DocID,
+		ClaimantEmail:     c.ClaimantEmail,
+		FirstName:         c.FirstName,
+		LastName:          c.LastName,
+		Status:            string(c.Status),
+		CreatedAt:         c.CreatedAt,
+		LastUpdatedAt:     c.LastUpdatedAt,
+		DecisionTokenHash: c.DecisionTokenHash,
+	}
+}
@@ -0,0 +1,156 @@
+package dbtx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// statuses of a direct-to-blob upload
+const (
+	DirectCreated    = string(raw.DirectUploadStatusCREATED)
+	DirectFinalising = string(raw.DirectUploadStatusFINALISING)
+	DirectCompleted  = string(raw.DirectUploadStatusCOMPLETED)
+	DirectExpired    = string(raw.DirectUploadStatusEXPIRED)
+)
+
+// ErrDirectUploadNotCreated is returned when a direct upload being finalised is no longer awaiting its content
+var ErrDirectUploadNotCreated = errors.New("direct upload is not awaiting finalisation")
+
+// DirectUpload holds the state of an upload which a client puts in blob store through a presigned url
+type DirectUpload struct {
+	UploadId  string
+	Length    int64
+	Meta      map[string]string
+	Status    string
+	DocId     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// CreateDirectUpload records a new direct-to-blob upload
+func (store *Store) CreateDirectUpload(ctx context.Context, in DirectUpload) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for creating direct upload", zap.String("uploadId", in.UploadId))
+	meta, err := json.Marshal(in.Meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload metadata - %w", err)
+	}
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		_, err := queries.AddDirectUpload(ctx, raw.AddDirectUploadParams{
+			UploadID:     in.UploadId,
+			UploadLength: in.Length,
+			Metadata:     NewNullJson(&meta),
+			ExpiresAt:    in.ExpiresAt,
+		})
+		return err
+	})
+}
+
+// GetDirectUpload returns the state of a direct-to-blob upload
+func (store *Store) GetDirectUpload(ctx context.Context, uploadId string) (DirectUpload, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get direct upload", zap.String("uploadId", uploadId))
+	var out DirectUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, err := queries.GetDirectUpload(ctx, uploadId)
+		if err != nil {
+			return err
+		}
+		out, err = directUpload(u)
+		return err
+	})
+	return out, err
+}
+
+// ClaimDirectUpload moves a created direct upload which has not expired to finalising, so that it is finalised
+// only once. An upload left finalising for longer than staleFor is claimed again, as the instance finalising it
+// went down. It returns ErrDirectUploadNotCreated when the upload is being finalised, is completed or expired.
+func (store *Store) ClaimDirectUpload(ctx context.Context, uploadId string, staleFor time.Duration) (DirectUpload,
+	error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming direct upload", zap.String("uploadId", uploadId))
+	var out DirectUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, err := queries.ClaimDirectUpload(ctx, raw.ClaimDirectUploadParams{
+			UploadID:    uploadId,
+			StaleBefore: time.Now().Add(-staleFor),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrDirectUploadNotCreated
+			}
+			return err
+		}
+		out, err = directUpload(u)
+		return err
+	})
+	return out, err
+}
+
+// ClaimExpiredDirectUploads moves a batch of created direct uploads which expired at least expiredFor ago
+// to expired, so that what was put for them is cleaned up once
+func (store *Store) ClaimExpiredDirectUploads(ctx context.Context, expiredFor time.Duration,
+	maxUploads int) ([]DirectUpload, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming expired direct uploads", zap.Duration("expiredFor", expiredFor))
+	var out []DirectUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		uploads, err := queries.ClaimExpiredDirectUploads(ctx, raw.ClaimExpiredDirectUploadsParams{
+			ExpiredBefore: time.Now().Add(-expiredFor),
+			MaxUploads:    int32(maxUploads),
+		})
+		if err != nil {
+			return err
+		}
+		out = make([]DirectUpload, 0, len(uploads))
+		for _, u := range uploads {
+			du, err := directUpload(u)
+			if err != nil {
+				return err
+			}
+			out = append(out, du)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// FinishDirectUpload marks a direct upload as completed with its doc, or as created again to be retried
+func (store *Store) FinishDirectUpload(ctx context.Context, uploadId, status, docId string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for finishing direct upload", zap.String("uploadId", uploadId),
+		zap.String("status", status))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.UpdateDirectUploadStatus(ctx, raw.UpdateDirectUploadStatusParams{
+			UploadID: uploadId,
+			Status:   raw.DirectUploadStatus(status),
+			DocID:    NewNullStr(&docId),
+		})
+	})
+}
+
+func directUpload(u raw.DirectUpload) (DirectUpload, error) {
+	out := DirectUpload{
+		UploadId:  u.UploadID,
+		Length:    u.UploadLength,
+		Status:    string(u.Status),
+		DocId:     u.DocID.String,
+		ExpiresAt: u.ExpiresAt,
+		CreatedAt: u.CreatedAt,
+	}
+	if u.Metadata.Valid {
+		if err := json.Unmarshal(u.Metadata.RawMessage, &out.Meta); err != nil {
+			return out, fmt.Errorf("failed to unmarshal upload metadata - %w", err)
+		}
+	}
+	return out, nil
+}
@@ -3,15 +3,23 @@ package dbtx
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/sqlc-dev/pqtype"
 	"go.uber.org/zap"
 
 	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/internal/hash"
 	"github.com/vposham/trustdoc/log"
 )
 
+// DefaultMimeType is the content type of documents whose type is not known
+const DefaultMimeType = "application/octet-stream"
+
 // DocMeta holds all the metadata for a document in postgres
 type DocMeta struct {
 	DocId          string `json:"docId,omitempty"`
@@ -23,6 +31,73 @@ type DocMeta struct {
 	BcTknId        string `json:"bcTknId,omitempty"`
 	OwnerFirstName string `json:"ownerFirstName,omitempty"`
 	OwnerLastName  string `json:"ownerLastName,omitempty"`
+	HashAlgo       string `json:"hashAlgo,omitempty"`
+	ChunkSize      int64  `json:"chunkSize,omitempty"`
+	PerceptualHash string `json:"perceptualHash,omitempty"`
+	MimeType       string `json:"mimeType,omitempty"`
+	DocSize        int64  `json:"docSize,omitempty"`
+	ScanStatus     string `json:"scanStatus,omitempty"`
+	ScanEngine     string `json:"scanEngine,omitempty"`
+
+	// Tags are free-form key/value metadata of the document, such as issuer or courseId
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// ValidFrom and ValidUntil are the optional window in which the document, such as a certificate, is valid
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+
+	// UploadedAt is when the document was saved, it is not set on documents which are not saved yet
+	UploadedAt *time.Time `json:"uploadedAt,omitempty"`
+
+	// DeletedAt and RevokedAt are set once the document was withdrawn by its owner or revoked, it is no longer served
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+
+	// ChunkHashes are the merkle tree leaves of the document, only present for chunked hash algos
+	ChunkHashes []string `json:"-"`
+}
+
+// statuses of the documents listed by ListDocs, a revoked document may also be deleted
+const (
+	DocActive  = "active"
+	DocDeleted = "deleted"
+	DocRevoked = "revoked"
+)
+
+// DocFilter filters the documents of an owner listed by ListDocs
+type DocFilter struct {
+	OwnerEmail string
+	// UploadedAfter and UploadedBefore bound when documents were uploaded, from inclusive to exclusive,
+	// a zero time leaves the bound out
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	// Status is one of DocActive, DocDeleted or DocRevoked, documents of any status are listed when empty
+	Status string
+	// Tags are the tags documents need to have, all documents are listed when empty
+	Tags  map[string]string
+	Limit int
+	// After is where the previous page ended, the first page is listed when nil
+	After *DocCursor
+}
+
+// DocCursor is the position of a document in the documents of an owner listed newest first
+type DocCursor struct {
+	UploadedAt time.Time
+	Id         int64
+}
+
+// SimilarDoc is an existing document which is visually similar to another one
+type SimilarDoc struct {
+	DocId    string `json:"docId"`
+	Distance int    `json:"distance"`
+}
+
+// DocChunks holds the recorded per-chunk hashes of a document, in chunk order
+type DocChunks struct {
+	DocHash   string
+	HashAlgo  string
+	ChunkSize int64
+	Hashes    []string
 }
 
 func (store *Store) SaveDocMeta(ctx context.Context, in DocMeta) error {
@@ -39,10 +114,33 @@ func (store *Store) SaveDocMeta(ctx context.Context, in DocMeta) error {
 				return err
 			}
 		}
+		if err = reserveUsage(ctx, queries, in.OwnerEmail, in.DocSize, Quota{}); err != nil {
+			return err
+		}
 		return saveDocMeta(ctx, queries, in, u)
 	})
 }
 
+// GetDocMeta returns the metadata of a document by its docId
+func (store *Store) GetDocMeta(ctx context.Context, docId string) (DocMeta, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get document meta", zap.String("docId", docId))
+	var m DocMeta
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		doc, err := queries.GetDoc(ctx, docId)
+		if err != nil {
+			return err
+		}
+		u, err := queries.GetUserById(ctx, doc.UserID)
+		if err != nil {
+			return err
+		}
+		m = docMeta(doc, u)
+		return nil
+	})
+	return m, err
+}
+
 func (store *Store) GetDocMetaByHash(ctx context.Context, docMd5Hash string) (DocMeta, error) {
 	logger := log.GetLogger(ctx)
 	logger.Info("started db tx for get document meta by hash", zap.Any("docMd5Hash", docMd5Hash))
@@ -56,22 +154,313 @@ func (store *Store) GetDocMetaByHash(ctx context.Context, docMd5Hash string) (Do
 		if err != nil {
 			return err
 		}
-		m = DocMeta{
-			DocId:          doc.DocID,
-			OwnerEmail:     u.EmailID,
-			DocTitle:       doc.Title,
-			DocDesc:        doc.Description.String,
-			DocName:        doc.FileName,
-			DocMd5Hash:     doc.DocHash,
-			BcTknId:        doc.DocMintedID,
-			OwnerFirstName: u.FirstName,
-			OwnerLastName:  u.LastName,
+		m = docMeta(doc, u)
+		return nil
+	})
+	return m, err
+}
+
+// GetDocMetaByTkn returns the metadata of a document by the blockchain tkn it was minted with
+func (store *Store) GetDocMetaByTkn(ctx context.Context, bcTknId string) (DocMeta, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get document meta by tkn", zap.String("bcTknId", bcTknId))
+	var m DocMeta
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		doc, err := queries.GetDocByMintedId(ctx, bcTknId)
+		if err != nil {
+			return err
+		}
+		u, err := queries.GetUserById(ctx, doc.UserID)
+		if err != nil {
+			return err
 		}
+		m = docMeta(doc, u)
 		return nil
 	})
 	return m, err
 }
 
+// SearchDocs returns the documents of an owner which have all the given tags, latest first. All of them,
+// including the ones without tags, are returned when no tags are given.
+func (store *Store) SearchDocs(ctx context.Context, ownerEmail string, tags map[string]string,
+	maxResults int) ([]DocMeta, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for search documents", zap.String("email", ownerEmail), zap.Int("tags", len(tags)))
+	// no tags match every document, as an empty object is contained in any
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	filter, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal doc tags - %w", err)
+	}
+	out := []DocMeta{}
+	err = store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, exists, err := chkUsrExists(ctx, queries, ownerEmail)
+		if err != nil || !exists {
+			return err
+		}
+		docs, err := queries.SearchDocsByTags(ctx, raw.SearchDocsByTagsParams{
+			OwnerEmail: ownerEmail,
+			Tags:       filter,
+			MaxResults: int32(maxResults),
+		})
+		if err != nil {
+			return err
+		}
+		out = make([]DocMeta, 0, len(docs))
+		for _, doc := range docs {
+			out = append(out, docMeta(doc, *u))
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ListDocs returns a page of the documents of an owner, newest first. The cursor of the last document of
+// the page is returned when more documents follow it, the next page is listed after it.
+func (store *Store) ListDocs(ctx context.Context, f DocFilter) ([]DocMeta, *DocCursor, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for list documents", zap.String("email", f.OwnerEmail), zap.String("status", f.Status))
+	// no tags match every document, as an empty object is contained in any
+	tags := f.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	filter, err := json.Marshal(tags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal doc tags - %w", err)
+	}
+	var (
+		out  []DocMeta
+		next *DocCursor
+	)
+	err = store.execTxWithRetry(ctx, func(queries Queries) error {
+		// a retried tx starts over
+		out, next = []DocMeta{}, nil
+		u, exists, err := chkUsrExists(ctx, queries, f.OwnerEmail)
+		if err != nil || !exists {
+			return err
+		}
+		arg := raw.ListUserDocsParams{
+			UserID:         u.ID,
+			UploadedAfter:  newNullTime(&f.UploadedAfter),
+			UploadedBefore: newNullTime(&f.UploadedBefore),
+			Status:         f.Status,
+			Tags:           filter,
+			// one more document than asked for tells whether another page follows
+			MaxResults: int32(f.Limit + 1),
+		}
+		if f.After != nil {
+			arg.CursorUploadedAt, arg.CursorID = newNullTime(&f.After.UploadedAt), f.After.Id
+		}
+		docs, err := queries.ListUserDocs(ctx, arg)
+		if err != nil {
+			return err
+		}
+		if len(docs) > f.Limit {
+			docs = docs[:f.Limit]
+			last := docs[len(docs)-1]
+			next = &DocCursor{UploadedAt: last.UploadedAt, Id: last.ID}
+		}
+		out = make([]DocMeta, 0, len(docs))
+		for _, doc := range docs {
+			out = append(out, docMeta(doc, *u))
+		}
+		return nil
+	})
+	return out, next, err
+}
+
+// ClaimExpiringDocs returns documents whose validity ends within the given duration, and which were not
+// announced as about to expire yet. They are not returned again, unless their notice is released.
+func (store *Store) ClaimExpiringDocs(ctx context.Context, within time.Duration, maxDocs int) ([]DocMeta, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming expiring documents", zap.Duration("within", within))
+	var out []DocMeta
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		docs, err := queries.ClaimExpiringDocs(ctx, raw.ClaimExpiringDocsParams{
+			ExpiringBefore: sql.NullTime{Time: time.Now().Add(within), Valid: true},
+			MaxDocs:        int32(maxDocs),
+		})
+		if err != nil {
+			return err
+		}
+		out = make([]DocMeta, 0, len(docs))
+		for _, doc := range docs {
+			u, err := queries.GetUserById(ctx, doc.UserID)
+			if err != nil {
+				return err
+			}
+			out = append(out, docMeta(doc, u))
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ReleaseDocExpiryNotice lets a document whose expiry could not be announced be claimed again
+func (store *Store) ReleaseDocExpiryNotice(ctx context.Context, docId string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for releasing document expiry notice", zap.String("docId", docId))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.ReleaseDocExpiryNotice(ctx, docId)
+	})
+}
+
+// GetDocChunks returns the chunk hashes recorded for a document hashed as a merkle tree
+func (store *Store) GetDocChunks(ctx context.Context, docId string) (DocChunks, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get document chunks", zap.String("docId", docId))
+	var c DocChunks
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		doc, err := queries.GetDoc(ctx, docId)
+		if err != nil {
+			return err
+		}
+		hashes, err := queries.GetDocChunks(ctx, doc.ID)
+		if err != nil {
+			return err
+		}
+		c = DocChunks{
+			DocHash:   doc.DocHash,
+			HashAlgo:  doc.HashAlgo,
+			ChunkSize: doc.ChunkSize.Int64,
+			Hashes:    hashes,
+		}
+		return nil
+	})
+	return c, err
+}
+
+// GetSimilarDocs returns the documents whose perceptual hash is within maxDistance bits of pHash,
+// closest first. Documents with the excluded content hash are left out.
+// Only documents having one of the four 16 bit bands of their hash within maxDistance/4 bits of the same band
+// of pHash are looked at, which by pigeonhole are all those within maxDistance bits.
+func (store *Store) GetSimilarDocs(ctx context.Context, pHash, excludeDocHash string,
+	maxDistance, maxResults int) ([]SimilarDoc, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get similar documents", zap.String("pHash", pHash))
+	p, err := newNullPHash(pHash)
+	if err != nil {
+		return nil, err
+	}
+	bands := pHashBands(uint64(p.Int64), maxDistance/4)
+	var out []SimilarDoc
+	err = store.execTxWithRetry(ctx, func(queries Queries) error {
+		rows, err := queries.GetSimilarDocs(ctx, raw.GetSimilarDocsParams{
+			Phash:       p.Int64,
+			Band0:       bands[0],
+			Band1:       bands[1],
+			Band2:       bands[2],
+			Band3:       bands[3],
+			DocHash:     excludeDocHash,
+			MaxDistance: int32(maxDistance),
+			MaxResults:  int32(maxResults),
+		})
+		if err != nil {
+			return err
+		}
+		out = make([]SimilarDoc, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, SimilarDoc{DocId: r.DocID, Distance: int(r.Distance)})
+		}
+		return nil
+	})
+	return out, err
+}
+
+func docMeta(doc raw.Document, u raw.User) DocMeta {
+	return DocMeta{
+		DocId:          doc.DocID,
+		OwnerEmail:     u.EmailID,
+		DocTitle:       doc.Title,
+		DocDesc:        doc.Description.String,
+		DocName:        doc.FileName,
+		DocMd5Hash:     doc.DocHash,
+		BcTknId:        doc.DocMintedID,
+		OwnerFirstName: u.FirstName,
+		OwnerLastName:  u.LastName,
+		HashAlgo:       doc.HashAlgo,
+		ChunkSize:      doc.ChunkSize.Int64,
+		PerceptualHash: pHashStr(doc.Phash),
+		MimeType:       doc.MimeType,
+		DocSize:        doc.FileSize,
+		ScanStatus:     doc.ScanStatus.String,
+		ScanEngine:     doc.ScanEngine.String,
+		Tags:           docTags(doc.Tags),
+		ValidFrom:      timePtr(doc.ValidFrom),
+		ValidUntil:     timePtr(doc.ValidUntil),
+		UploadedAt:     &doc.UploadedAt,
+		DeletedAt:      timePtr(doc.DeletedAt),
+		RevokedAt:      timePtr(doc.RevokedAt),
+	}
+}
+
+// docTagsJson returns the tags of a document as they are stored, nil when it has none
+func docTagsJson(tags map[string]string) ([]byte, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	j, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal doc tags - %w", err)
+	}
+	return j, nil
+}
+
+// docTags returns the stored tags of a document, tags which are not a json object of strings are left out
+func docTags(j pqtype.NullRawMessage) map[string]string {
+	if !j.Valid {
+		return nil
+	}
+	var tags map[string]string
+	_ = json.Unmarshal(j.RawMessage, &tags)
+	return tags
+}
+
+// newNullPHash converts a hex perceptual hash into the signed BIGINT it is stored as
+func newNullPHash(h string) (sql.NullInt64, error) {
+	if h == "" {
+		return sql.NullInt64{}, nil
+	}
+	u, err := strconv.ParseUint(h, 16, 64)
+	if err != nil {
+		return sql.NullInt64{}, fmt.Errorf("invalid perceptual hash - %w", err)
+	}
+	return sql.NullInt64{Int64: int64(u), Valid: true}, nil
+}
+
+// pHashBands returns, for each 16 bit band of a perceptual hash from the most significant one,
+// all the band values within radius bits of it
+func pHashBands(h uint64, radius int) [4][]int64 {
+	var bands [4][]int64
+	for i := range bands {
+		b := (h >> (48 - 16*i)) & 0xffff
+		bands[i] = nearBands(b, 0, radius, nil)
+	}
+	return bands
+}
+
+// nearBands appends b and the values got by flipping up to radius of its bits from bit onwards
+func nearBands(b uint64, bit, radius int, out []int64) []int64 {
+	out = append(out, int64(b))
+	if radius == 0 {
+		return out
+	}
+	for i := bit; i < 16; i++ {
+		out = nearBands(b^(1<<i), i+1, radius-1, out)
+	}
+	return out
+}
+
+func pHashStr(n sql.NullInt64) string {
+	if !n.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%016x", uint64(n.Int64))
+}
+
 func chkUsrExists(ctx context.Context, queries Queries, email string) (u *raw.User, exists bool, err error) {
 	logger := log.GetLogger(ctx)
 	logger.Info("checking if user exists", zap.String("email", email))
@@ -115,6 +504,14 @@ func createUser(ctx context.Context, queries Queries, emailId, firstName, lastNa
 func saveDocMeta(ctx context.Context, queries Queries, in DocMeta, u *raw.User) error {
 	logger := log.GetLogger(ctx)
 	logger.Info("saving document meta", zap.String("docId", in.DocId))
+	pHash, err := newNullPHash(in.PerceptualHash)
+	if err != nil {
+		return err
+	}
+	tags, err := docTagsJson(in.Tags)
+	if err != nil {
+		return err
+	}
 	arg := raw.AddDocParams{
 		DocID:       in.DocId,
 		Title:       in.DocTitle,
@@ -123,12 +520,36 @@ func saveDocMeta(ctx context.Context, queries Queries, in DocMeta, u *raw.User)
 		DocHash:     in.DocMd5Hash,
 		DocMintedID: in.BcTknId,
 		UserID:      u.ID,
+		HashAlgo:    in.HashAlgo,
+		ChunkSize:   newNullInt64(&in.ChunkSize),
+		Phash:       pHash,
+		MimeType:    in.MimeType,
+		FileSize:    in.DocSize,
+		ScanStatus:  NewNullStr(&in.ScanStatus),
+		ScanEngine:  NewNullStr(&in.ScanEngine),
+		Tags:        NewNullJson(&tags),
+		ValidFrom:   newNullTime(in.ValidFrom),
+		ValidUntil:  newNullTime(in.ValidUntil),
+	}
+	if arg.HashAlgo == "" {
+		arg.HashAlgo = hash.AlgoMd5
+	}
+	if arg.MimeType == "" {
+		arg.MimeType = DefaultMimeType
 	}
-	_, err := queries.AddDoc(ctx, arg)
+	doc, err := queries.AddDoc(ctx, arg)
 	if err != nil {
 		logger.Error("failed to saveDocMeta", zap.String("docId", in.DocId), zap.Error(err))
 		err = fmt.Errorf("failed to saveDocMeta - %w", err)
 		return err
 	}
+	if len(in.ChunkHashes) == 0 {
+		return nil
+	}
+	err = queries.AddDocChunks(ctx, raw.AddDocChunksParams{DocumentID: doc.ID, ChunkHashes: in.ChunkHashes})
+	if err != nil {
+		logger.Error("failed to save doc chunks", zap.String("docId", in.DocId), zap.Error(err))
+		return fmt.Errorf("failed to save doc chunks - %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,110 @@
+package dbtx
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+)
+
+// jsonArg matches a json query arg by its text
+type jsonArg string
+
+func (a jsonArg) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	return ok && string(b) == string(a)
+}
+
+// columns of the users and documents rows queries return
+var (
+	userCols = []string{"id", "email_id", "first_name", "last_name", "status", "created_at", "last_updated_at"}
+	docCols  = []string{"id", "doc_id", "title", "description", "file_name", "doc_hash", "doc_minted_id",
+		"doc_tkn_mined", "user_id", "uploaded_at", "last_updated_at", "hash_algo", "chunk_size", "phash", "mime_type",
+		"file_size", "scan_status", "scan_engine", "tags", "valid_from", "valid_until", "expiry_notified_at",
+		"deleted_at", "revoked_at"}
+)
+
+func TestStore_SearchDocs(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		tags   map[string]string
+		filter string
+	}{
+		{name: "no tag filter", tags: nil, filter: `{}`},
+		{name: "tag filter", tags: map[string]string{"issuer": "acme"}, filter: `{"issuer":"acme"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			store := &Store{Queries: &QueryBase{raw.New(db)}, db: db, timeout: time.Second}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM users").WithArgs("john.doe@example.com").
+				WillReturnRows(sqlmock.NewRows(userCols).
+					AddRow(1, "john.doe@example.com", "John", "Doe", "ACTIVE", now, now))
+			// documents without tags are matched as if they had none, instead of never
+			mock.ExpectQuery(`coalesce\(d.tags, '\{\}'::JSONB\) @> \$2::JSONB`).
+				WithArgs("john.doe@example.com", jsonArg(tt.filter), 10).
+				WillReturnRows(sqlmock.NewRows(docCols).
+					AddRow(1, "doc-1", "title", nil, "doc.txt", "hash-1", "tkn-1", false, 1, now, now, "md5", nil,
+						nil, "text/plain", 7, nil, nil, nil, nil, nil, nil, nil, nil))
+			mock.ExpectCommit()
+
+			docs, err := store.SearchDocs(context.Background(), "john.doe@example.com", tt.tags, 10)
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			assert.Equal(t, "doc-1", docs[0].DocId)
+			assert.Nil(t, docs[0].Tags)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStore_ListDocs(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		tags   map[string]string
+		filter string
+	}{
+		{name: "no tag filter", tags: nil, filter: `{}`},
+		{name: "tag filter", tags: map[string]string{"issuer": "acme", "dept": "hr"},
+			filter: `{"dept":"hr","issuer":"acme"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			store := &Store{Queries: &QueryBase{raw.New(db)}, db: db, timeout: time.Second}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM users").WithArgs("john.doe@example.com").
+				WillReturnRows(sqlmock.NewRows(userCols).
+					AddRow(1, "john.doe@example.com", "John", "Doe", "ACTIVE", now, now))
+			mock.ExpectQuery(`coalesce\(tags, '\{\}'::JSONB\) @> \$5::JSONB`).
+				WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), "", jsonArg(tt.filter), sqlmock.AnyArg(), 0, 2).
+				WillReturnRows(sqlmock.NewRows(docCols).
+					AddRow(1, "doc-1", "title", nil, "doc.txt", "hash-1", "tkn-1", false, 1, now, now, "md5", nil,
+						nil, "text/plain", 7, nil, nil, nil, nil, nil, nil, nil, nil))
+			mock.ExpectCommit()
+
+			docs, next, err := store.ListDocs(context.Background(),
+				DocFilter{OwnerEmail: "john.doe@example.com", Tags: tt.tags, Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			assert.Equal(t, "doc-1", docs[0].DocId)
+			assert.Nil(t, next)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
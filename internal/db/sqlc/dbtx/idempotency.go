@@ -0,0 +1,124 @@
+package dbtx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// IdemKey is an Idempotency-Key along with the request it was first used with
+type IdemKey struct {
+	Key         string
+	Fingerprint string
+	// Response is nil while the request the key was first used with is in progress
+	Response  *IdemResponse
+	ExpiresAt time.Time
+	// LockedUntil is when the lease of the request in progress lapses, so the key can be taken over
+	LockedUntil time.Time
+}
+
+// IdemResponse is the stored response of a request made with an Idempotency-Key
+type IdemResponse struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+}
+
+// ClaimIdempotencyKey records a new Idempotency-Key for a request with the given fingerprint, leased to
+// the caller for lease, and returns true when it was claimed. Expired keys, and keys of the same request whose
+// lease lapsed, are claimed again. Otherwise, the key was used before, and the existing key is returned.
+func (store *Store) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string,
+	ttl, lease time.Duration) (IdemKey, bool, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming idempotency key", zap.String("idempotencyKey", key))
+	var out IdemKey
+	var claimed bool
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		if err := queries.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+			return err
+		}
+		expiresAt, lockedUntil := time.Now().Add(ttl), time.Now().Add(lease)
+		n, err := queries.AddIdempotencyKey(ctx, raw.AddIdempotencyKeyParams{
+			IdemKey:     key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   expiresAt,
+			LockedUntil: lockedUntil,
+		})
+		if err != nil {
+			return err
+		}
+		if claimed = n == 1; claimed {
+			out = IdemKey{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt, LockedUntil: lockedUntil}
+			return nil
+		}
+		k, err := queries.GetIdempotencyKey(ctx, key)
+		if err != nil {
+			return err
+		}
+		out, err = idemKey(k)
+		return err
+	})
+	return out, claimed, err
+}
+
+// CompleteIdempotencyKey stores the response of the request an Idempotency-Key was claimed for
+func (store *Store) CompleteIdempotencyKey(ctx context.Context, key string, resp IdemResponse) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for completing idempotency key", zap.String("idempotencyKey", key),
+		zap.Int("responseStatus", resp.Status))
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response headers - %w", err)
+	}
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.CompleteIdempotencyKey(ctx, raw.CompleteIdempotencyKeyParams{
+			IdemKey:         key,
+			ResponseStatus:  sql.NullInt32{Int32: int32(resp.Status), Valid: true},
+			ResponseHeaders: NewNullJson(&headers),
+			ResponseBody:    resp.Body,
+		})
+	})
+}
+
+// ExtendIdempotencyKey renews the lease of the request in progress an Idempotency-Key was claimed for
+func (store *Store) ExtendIdempotencyKey(ctx context.Context, key string, lease time.Duration) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for extending idempotency key", zap.String("idempotencyKey", key))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.ExtendIdempotencyKey(ctx, raw.ExtendIdempotencyKeyParams{
+			IdemKey:     key,
+			LockedUntil: time.Now().Add(lease),
+		})
+	})
+}
+
+// ReleaseIdempotencyKey removes an Idempotency-Key, so the request can be retried with it
+func (store *Store) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for releasing idempotency key", zap.String("idempotencyKey", key))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.DeleteIdempotencyKey(ctx, key)
+	})
+}
+
+func idemKey(k raw.IdempotencyKey) (IdemKey, error) {
+	out := IdemKey{Key: k.IdemKey, Fingerprint: k.Fingerprint, ExpiresAt: k.ExpiresAt, LockedUntil: k.LockedUntil}
+	if !k.ResponseStatus.Valid {
+		return out, nil
+	}
+	out.Response = &IdemResponse{Status: int(k.ResponseStatus.Int32), Body: k.ResponseBody}
+	if k.ResponseHeaders.Valid {
+		if err := json.Unmarshal(k.ResponseHeaders.RawMessage, &out.Response.Headers); err != nil {
+			return out, fmt.Errorf("failed to unmarshal response headers - %w", err)
+		}
+	}
+	return out, nil
+}
@@ -3,6 +3,7 @@ package dbtx
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
 )
@@ -24,5 +25,43 @@ type Queries interface {
 // StoreIf interface provides all the valid business DB transactions
 type StoreIf interface {
 	SaveDocMeta(ctx context.Context, in DocMeta) error
+	GetDocMeta(ctx context.Context, docId string) (DocMeta, error)
 	GetDocMetaByHash(ctx context.Context, docMd5Hash string) (DocMeta, error)
+	GetDocMetaByTkn(ctx context.Context, bcTknId string) (DocMeta, error)
+	GetDocChunks(ctx context.Context, docId string) (DocChunks, error)
+	GetSimilarDocs(ctx context.Context, pHash, excludeDocHash string, maxDistance, maxResults int) ([]SimilarDoc, error)
+	GetUserUsage(ctx context.Context, email string) (Usage, error)
+	SearchDocs(ctx context.Context, ownerEmail string, tags map[string]string, maxResults int) ([]DocMeta, error)
+	ListDocs(ctx context.Context, f DocFilter) ([]DocMeta, *DocCursor, error)
+	ClaimExpiringDocs(ctx context.Context, within time.Duration, maxDocs int) ([]DocMeta, error)
+	ReleaseDocExpiryNotice(ctx context.Context, docId string) error
+
+	CreateTusUpload(ctx context.Context, in TusUpload) error
+	GetTusUpload(ctx context.Context, uploadId string) (TusUpload, error)
+	AddTusUploadPart(ctx context.Context, uploadId string, part TusUploadPart) (TusUpload, error)
+	GetTusUploadParts(ctx context.Context, uploadId string) ([]TusUploadPart, error)
+	ClaimTusUpload(ctx context.Context, uploadId string, staleFor time.Duration) (TusUpload, error)
+	FinishTusUpload(ctx context.Context, uploadId, status, docId string) error
+
+	CreateDirectUpload(ctx context.Context, in DirectUpload) error
+	GetDirectUpload(ctx context.Context, uploadId string) (DirectUpload, error)
+	ClaimDirectUpload(ctx context.Context, uploadId string, staleFor time.Duration) (DirectUpload, error)
+	ClaimExpiredDirectUploads(ctx context.Context, expiredFor time.Duration, maxUploads int) ([]DirectUpload, error)
+	FinishDirectUpload(ctx context.Context, uploadId, status, docId string) error
+
+	ClaimIdempotencyKey(ctx context.Context, key, fingerprint string, ttl, lease time.Duration) (IdemKey, bool, error)
+	ExtendIdempotencyKey(ctx context.Context, key string, lease time.Duration) error
+	CompleteIdempotencyKey(ctx context.Context, key string, resp IdemResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+
+	StartUploadSaga(ctx context.Context, in UploadSaga, quota Quota) error
+	AdvanceUploadSaga(ctx context.Context, sagaId, from, to string, doc DocMeta) error
+	FailUploadSagaStep(ctx context.Context, sagaId string, stepErr error) error
+	SaveUploadSagaDoc(ctx context.Context, sagaId, from string, in DocMeta) error
+	GetUploadSaga(ctx context.Context, sagaId string) (UploadSaga, error)
+	ClaimStaleUploadSagas(ctx context.Context, staleFor time.Duration, maxSagas int) ([]UploadSaga, error)
+
+	ClaimDoc(ctx context.Context, in DocClaim) (DocClaim, error)
+	GetDocClaim(ctx context.Context, claimId string) (DocClaim, error)
+	DecideDocClaim(ctx context.Context, claimId, status string) error
 }
@@ -1,12 +1,48 @@
 package dbtx
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
 
 // MockStore struct provides all the mocked business DB transactions. It implements StoreIf
 type MockStore struct {
 	saveDocMetaFn         func(ctx context.Context, in DocMeta) error
 	getDocMetaFn          func(ctx context.Context, docId string) (DocMeta, error)
 	getDocMetaByDocHashFn func(ctx context.Context, docMd5Hash string) (DocMeta, error)
+	getDocMetaByTknFn     func(ctx context.Context, bcTknId string) (DocMeta, error)
+	getDocChunksFn        func(ctx context.Context, docId string) (DocChunks, error)
+	getSimilarDocsFn      func(ctx context.Context, pHash, docHash string, maxDist, maxRes int) ([]SimilarDoc, error)
+	getUserUsageFn        func(ctx context.Context, email string) (Usage, error)
+	searchDocsFn          func(ctx context.Context, email string, tags map[string]string, maxRes int) ([]DocMeta, error)
+	listDocsFn            func(ctx context.Context, f DocFilter) ([]DocMeta, *DocCursor, error)
+	claimExpiringDocsFn   func(ctx context.Context, within time.Duration, maxDocs int) ([]DocMeta, error)
+	releaseDocExpiryFn    func(ctx context.Context, docId string) error
+	createTusUploadFn     func(ctx context.Context, in TusUpload) error
+	getTusUploadFn        func(ctx context.Context, uploadId string) (TusUpload, error)
+	addTusUploadPartFn    func(ctx context.Context, uploadId string, part TusUploadPart) (TusUpload, error)
+	getTusUploadPartsFn   func(ctx context.Context, uploadId string) ([]TusUploadPart, error)
+	claimTusUploadFn      func(ctx context.Context, uploadId string, staleFor time.Duration) (TusUpload, error)
+	finishTusUploadFn     func(ctx context.Context, uploadId, status, docId string) error
+	createDirectUploadFn  func(ctx context.Context, in DirectUpload) error
+	getDirectUploadFn     func(ctx context.Context, uploadId string) (DirectUpload, error)
+	claimDirectUploadFn   func(ctx context.Context, uploadId string, staleFor time.Duration) (DirectUpload, error)
+	claimExpiredDirectFn  func(ctx context.Context, expiredFor time.Duration, maxUps int) ([]DirectUpload, error)
+	finishDirectUploadFn  func(ctx context.Context, uploadId, status, docId string) error
+	claimIdemKeyFn        func(ctx context.Context, key, fp string, ttl, lease time.Duration) (IdemKey, bool, error)
+	extendIdemKeyFn       func(ctx context.Context, key string, lease time.Duration) error
+	completeIdemKeyFn     func(ctx context.Context, key string, resp IdemResponse) error
+	releaseIdemKeyFn      func(ctx context.Context, key string) error
+	startUploadSagaFn     func(ctx context.Context, in UploadSaga, quota Quota) error
+	advanceUploadSagaFn   func(ctx context.Context, sagaId, from, to string, doc DocMeta) error
+	failUploadSagaStepFn  func(ctx context.Context, sagaId string, stepErr error) error
+	saveUploadSagaDocFn   func(ctx context.Context, sagaId, from string, in DocMeta) error
+	getUploadSagaFn       func(ctx context.Context, sagaId string) (UploadSaga, error)
+	claimStaleSagasFn     func(ctx context.Context, staleFor time.Duration, maxSagas int) ([]UploadSaga, error)
+	claimDocFn            func(ctx context.Context, in DocClaim) (DocClaim, error)
+	getDocClaimFn         func(ctx context.Context, claimId string) (DocClaim, error)
+	decideDocClaimFn      func(ctx context.Context, claimId, status string) error
 }
 
 var _ StoreIf = (*MockStore)(nil)
@@ -33,3 +69,266 @@ func (m MockStore) GetDocMetaByHash(ctx context.Context, docMd5Hash string) (Doc
 	}
 	return DocMeta{}, nil
 }
+
+// GetDocMetaByTkn - mock implementation of it for unit testing
+func (m MockStore) GetDocMetaByTkn(ctx context.Context, bcTknId string) (DocMeta, error) {
+	if m.getDocMetaByTknFn != nil {
+		return m.getDocMetaByTknFn(ctx, bcTknId)
+	}
+	return DocMeta{}, nil
+}
+
+// GetDocChunks - mock implementation of it for unit testing
+func (m MockStore) GetDocChunks(ctx context.Context, docId string) (DocChunks, error) {
+	if m.getDocChunksFn != nil {
+		return m.getDocChunksFn(ctx, docId)
+	}
+	return DocChunks{}, nil
+}
+
+// GetSimilarDocs - mock implementation of it for unit testing
+func (m MockStore) GetSimilarDocs(ctx context.Context, pHash, excludeDocHash string,
+	maxDistance, maxResults int) ([]SimilarDoc, error) {
+	if m.getSimilarDocsFn != nil {
+		return m.getSimilarDocsFn(ctx, pHash, excludeDocHash, maxDistance, maxResults)
+	}
+	return nil, nil
+}
+
+// GetUserUsage - mock implementation of it for unit testing
+func (m MockStore) GetUserUsage(ctx context.Context, email string) (Usage, error) {
+	if m.getUserUsageFn != nil {
+		return m.getUserUsageFn(ctx, email)
+	}
+	return Usage{}, nil
+}
+
+// CreateTusUpload - mock implementation of it for unit testing
+func (m MockStore) CreateTusUpload(ctx context.Context, in TusUpload) error {
+	if m.createTusUploadFn != nil {
+		return m.createTusUploadFn(ctx, in)
+	}
+	return nil
+}
+
+// GetTusUpload - mock implementation of it for unit testing
+func (m MockStore) GetTusUpload(ctx context.Context, uploadId string) (TusUpload, error) {
+	if m.getTusUploadFn != nil {
+		return m.getTusUploadFn(ctx, uploadId)
+	}
+	return TusUpload{}, nil
+}
+
+// AddTusUploadPart - mock implementation of it for unit testing
+func (m MockStore) AddTusUploadPart(ctx context.Context, uploadId string, part TusUploadPart) (TusUpload, error) {
+	if m.addTusUploadPartFn != nil {
+		return m.addTusUploadPartFn(ctx, uploadId, part)
+	}
+	return TusUpload{}, nil
+}
+
+// GetTusUploadParts - mock implementation of it for unit testing
+func (m MockStore) GetTusUploadParts(ctx context.Context, uploadId string) ([]TusUploadPart, error) {
+	if m.getTusUploadPartsFn != nil {
+		return m.getTusUploadPartsFn(ctx, uploadId)
+	}
+	return nil, nil
+}
+
+// ClaimTusUpload - mock implementation of it for unit testing
+func (m MockStore) ClaimTusUpload(ctx context.Context, uploadId string, staleFor time.Duration) (TusUpload, error) {
+	if m.claimTusUploadFn != nil {
+		return m.claimTusUploadFn(ctx, uploadId, staleFor)
+	}
+	return TusUpload{UploadId: uploadId, Status: TusFinishing}, nil
+}
+
+// FinishTusUpload - mock implementation of it for unit testing
+func (m MockStore) FinishTusUpload(ctx context.Context, uploadId, status, docId string) error {
+	if m.finishTusUploadFn != nil {
+		return m.finishTusUploadFn(ctx, uploadId, status, docId)
+	}
+	return nil
+}
+
+// CreateDirectUpload - mock implementation of it for unit testing
+func (m MockStore) CreateDirectUpload(ctx context.Context, in DirectUpload) error {
+	if m.createDirectUploadFn != nil {
+		return m.createDirectUploadFn(ctx, in)
+	}
+	return nil
+}
+
+// GetDirectUpload - mock implementation of it for unit testing
+func (m MockStore) GetDirectUpload(ctx context.Context, uploadId string) (DirectUpload, error) {
+	if m.getDirectUploadFn != nil {
+		return m.getDirectUploadFn(ctx, uploadId)
+	}
+	return DirectUpload{}, nil
+}
+
+// ClaimDirectUpload - mock implementation of it for unit testing
+func (m MockStore) ClaimDirectUpload(ctx context.Context, uploadId string, staleFor time.Duration) (DirectUpload,
+	error) {
+	if m.claimDirectUploadFn != nil {
+		return m.claimDirectUploadFn(ctx, uploadId, staleFor)
+	}
+	return DirectUpload{UploadId: uploadId, Status: DirectFinalising}, nil
+}
+
+// ClaimExpiredDirectUploads - mock implementation of it for unit testing
+func (m MockStore) ClaimExpiredDirectUploads(ctx context.Context, expiredFor time.Duration,
+	maxUploads int) ([]DirectUpload, error) {
+	if m.claimExpiredDirectFn != nil {
+		return m.claimExpiredDirectFn(ctx, expiredFor, maxUploads)
+	}
+	return nil, nil
+}
+
+// FinishDirectUpload - mock implementation of it for unit testing
+func (m MockStore) FinishDirectUpload(ctx context.Context, uploadId, status, docId string) error {
+	if m.finishDirectUploadFn != nil {
+		return m.finishDirectUploadFn(ctx, uploadId, status, docId)
+	}
+	return nil
+}
+
+// ClaimIdempotencyKey - mock implementation of it for unit testing
+func (m MockStore) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string,
+	ttl, lease time.Duration) (IdemKey, bool, error) {
+	if m.claimIdemKeyFn != nil {
+		return m.claimIdemKeyFn(ctx, key, fingerprint, ttl, lease)
+	}
+	return IdemKey{Key: key, Fingerprint: fingerprint}, true, nil
+}
+
+// ExtendIdempotencyKey - mock implementation of it for unit testing
+func (m MockStore) ExtendIdempotencyKey(ctx context.Context, key string, lease time.Duration) error {
+	if m.extendIdemKeyFn != nil {
+		return m.extendIdemKeyFn(ctx, key, lease)
+	}
+	return nil
+}
+
+// CompleteIdempotencyKey - mock implementation of it for unit testing
+func (m MockStore) CompleteIdempotencyKey(ctx context.Context, key string, resp IdemResponse) error {
+	if m.completeIdemKeyFn != nil {
+		return m.completeIdemKeyFn(ctx, key, resp)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey - mock implementation of it for unit testing
+func (m MockStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if m.releaseIdemKeyFn != nil {
+		return m.releaseIdemKeyFn(ctx, key)
+	}
+	return nil
+}
+
+// StartUploadSaga - mock implementation of it for unit testing
+func (m MockStore) StartUploadSaga(ctx context.Context, in UploadSaga, quota Quota) error {
+	if m.startUploadSagaFn != nil {
+		return m.startUploadSagaFn(ctx, in, quota)
+	}
+	return nil
+}
+
+// AdvanceUploadSaga - mock implementation of it for unit testing
+func (m MockStore) AdvanceUploadSaga(ctx context.Context, sagaId, from, to string, doc DocMeta) error {
+	if m.advanceUploadSagaFn != nil {
+		return m.advanceUploadSagaFn(ctx, sagaId, from, to, doc)
+	}
+	return nil
+}
+
+// FailUploadSagaStep - mock implementation of it for unit testing
+func (m MockStore) FailUploadSagaStep(ctx context.Context, sagaId string, stepErr error) error {
+	if m.failUploadSagaStepFn != nil {
+		return m.failUploadSagaStepFn(ctx, sagaId, stepErr)
+	}
+	return nil
+}
+
+// SaveUploadSagaDoc - mock implementation of it for unit testing
+func (m MockStore) SaveUploadSagaDoc(ctx context.Context, sagaId, from string, in DocMeta) error {
+	if m.saveUploadSagaDocFn != nil {
+		return m.saveUploadSagaDocFn(ctx, sagaId, from, in)
+	}
+	return m.SaveDocMeta(ctx, in)
+}
+
+// GetUploadSaga - mock implementation of it for unit testing
+func (m MockStore) GetUploadSaga(ctx context.Context, sagaId string) (UploadSaga, error) {
+	if m.getUploadSagaFn != nil {
+		return m.getUploadSagaFn(ctx, sagaId)
+	}
+	return UploadSaga{}, sql.ErrNoRows
+}
+
+// ClaimStaleUploadSagas - mock implementation of it for unit testing
+func (m MockStore) ClaimStaleUploadSagas(ctx context.Context, staleFor time.Duration,
+	maxSagas int) ([]UploadSaga, error) {
+	if m.claimStaleSagasFn != nil {
+		return m.claimStaleSagasFn(ctx, staleFor, maxSagas)
+	}
+	return nil, nil
+}
+
+// SearchDocs - mock implementation of it for unit testing
+func (m MockStore) SearchDocs(ctx context.Context, ownerEmail string, tags map[string]string,
+	maxResults int) ([]DocMeta, error) {
+	if m.searchDocsFn != nil {
+		return m.searchDocsFn(ctx, ownerEmail, tags, maxResults)
+	}
+	return []DocMeta{}, nil
+}
+
+// ListDocs - mock implementation of it for unit testing
+func (m MockStore) ListDocs(ctx context.Context, f DocFilter) ([]DocMeta, *DocCursor, error) {
+	if m.listDocsFn != nil {
+		return m.listDocsFn(ctx, f)
+	}
+	return []DocMeta{}, nil, nil
+}
+
+// ClaimExpiringDocs - mock implementation of it for unit testing
+func (m MockStore) ClaimExpiringDocs(ctx context.Context, within time.Duration, maxDocs int) ([]DocMeta, error) {
+	if m.claimExpiringDocsFn != nil {
+		return m.claimExpiringDocsFn(ctx, within, maxDocs)
+	}
+	return nil, nil
+}
+
+// ReleaseDocExpiryNotice - mock implementation of it for unit testing
+func (m MockStore) ReleaseDocExpiryNotice(ctx context.Context, docId string) error {
+	if m.releaseDocExpiryFn != nil {
+		return m.releaseDocExpiryFn(ctx, docId)
+	}
+	return nil
+}
+
+// ClaimDoc - mock implementation of it for unit testing
+func (m MockStore) ClaimDoc(ctx context.Context, in DocClaim) (DocClaim, error) {
+	if m.claimDocFn != nil {
+		return m.claimDocFn(ctx, in)
+	}
+	in.Status = ClaimPending
+	return in, nil
+}
+
+// GetDocClaim - mock implementation of it for unit testing
+func (m MockStore) GetDocClaim(ctx context.Context, claimId string) (DocClaim, error) {
+	if m.getDocClaimFn != nil {
+		return m.getDocClaimFn(ctx, claimId)
+	}
+	return DocClaim{}, sql.ErrNoRows
+}
+
+// DecideDocClaim - mock implementation of it for unit testing
+func (m MockStore) DecideDocClaim(ctx context.Context, claimId, status string) error {
+	if m.decideDocClaimFn != nil {
+		return m.decideDocClaimFn(ctx, claimId, status)
+	}
+	return nil
+}
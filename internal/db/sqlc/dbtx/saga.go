@@ -0,0 +1,205 @@
+package dbtx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// states of an upload saga
+const (
+	SagaReceived   = string(raw.UploadSagaStateRECEIVED)
+	SagaStored     = string(raw.UploadSagaStateSTORED)
+	SagaMinted     = string(raw.UploadSagaStateMINTED)
+	SagaConfirmed  = string(raw.UploadSagaStateCONFIRMED)
+	SagaSaved      = string(raw.UploadSagaStateSAVED)
+	SagaRolledBack = string(raw.UploadSagaStateROLLEDBACK)
+	SagaFailed     = string(raw.UploadSagaStateFAILED)
+)
+
+// ErrSagaStateConflict is returned when an upload saga is not in the state it was advanced from,
+// as it was advanced by the recovery of an interrupted upload
+var ErrSagaStateConflict = errors.New("upload saga state conflict")
+
+// UploadSaga holds the progress of an upload through blob store, blockchain and db in postgres
+type UploadSaga struct {
+	SagaId string
+	State  string
+	// Doc is the metadata saved once the upload is minted, its DocId and BcTknId are set as they are known
+	Doc       DocMeta
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// sagaDoc is the persisted form of the metadata of an upload saga, it keeps the chunk hashes as well
+type sagaDoc struct {
+	DocMeta
+	ChunkHashes []string `json:"chunkHashes,omitempty"`
+}
+
+// StartUploadSaga records a new upload in the given state, along with the metadata to be saved for it.
+// The upload reserves its usage for its owner in the same tx, ErrQuotaExceeded is returned when
+// it does not fit in quota. The usage is released when the upload is rolled back or failed.
+func (store *Store) StartUploadSaga(ctx context.Context, in UploadSaga, quota Quota) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for starting upload saga", zap.String("sagaId", in.SagaId),
+		zap.String("state", in.State))
+	meta, err := json.Marshal(sagaDoc{DocMeta: in.Doc, ChunkHashes: in.Doc.ChunkHashes})
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload saga doc meta - %w", err)
+	}
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		if err := reserveUsage(ctx, queries, in.Doc.OwnerEmail, in.Doc.DocSize, quota); err != nil {
+			return err
+		}
+		return queries.AddUploadSaga(ctx, raw.AddUploadSagaParams{
+			SagaID:  in.SagaId,
+			State:   raw.UploadSagaState(in.State),
+			DocMeta: meta,
+			DocID:   NewNullStr(&in.Doc.DocId),
+		})
+	})
+}
+
+// AdvanceUploadSaga moves an upload saga from one state to another, recording the docId and bcTknId
+// of the doc when they are set. ErrSagaStateConflict is returned when the saga is not in the from state.
+func (store *Store) AdvanceUploadSaga(ctx context.Context, sagaId, from, to string, doc DocMeta) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for advancing upload saga", zap.String("sagaId", sagaId),
+		zap.String("fromState", from), zap.String("toState", to))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return advanceUploadSaga(ctx, queries, sagaId, from, to, doc)
+	})
+}
+
+// FailUploadSagaStep records a failed attempt of a step of an upload saga, so it can be retried
+func (store *Store) FailUploadSagaStep(ctx context.Context, sagaId string, stepErr error) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for failing upload saga step", zap.String("sagaId", sagaId))
+	lastErr := stepErr.Error()
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.FailUploadSagaStep(ctx, raw.FailUploadSagaStepParams{
+			SagaID:    sagaId,
+			LastError: NewNullStr(&lastErr),
+		})
+	})
+}
+
+// SaveUploadSagaDoc saves the metadata of a minted or confirmed upload and marks its saga saved in one tx
+func (store *Store) SaveUploadSagaDoc(ctx context.Context, sagaId, from string, in DocMeta) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for saving upload saga doc", zap.String("sagaId", sagaId),
+		zap.String("docId", in.DocId))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		if err := advanceUploadSaga(ctx, queries, sagaId, from, SagaSaved, DocMeta{}); err != nil {
+			return err
+		}
+		u, exists, err := chkUsrExists(ctx, queries, in.OwnerEmail)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			u, err = createUser(ctx, queries, in.OwnerEmail, in.OwnerFirstName, in.OwnerLastName)
+			if err != nil {
+				return err
+			}
+		}
+		return saveDocMeta(ctx, queries, in, u)
+	})
+}
+
+// GetUploadSaga returns the progress of an upload
+func (store *Store) GetUploadSaga(ctx context.Context, sagaId string) (UploadSaga, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get upload saga", zap.String("sagaId", sagaId))
+	var out UploadSaga
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		s, err := queries.GetUploadSaga(ctx, sagaId)
+		if err != nil {
+			return err
+		}
+		out, err = uploadSaga(s)
+		return err
+	})
+	return out, err
+}
+
+// ClaimStaleUploadSagas returns upload sagas which are neither finished nor rolled back, and did not
+// make progress for staleFor. They are not returned to other callers for another staleFor.
+func (store *Store) ClaimStaleUploadSagas(ctx context.Context, staleFor time.Duration,
+	maxSagas int) ([]UploadSaga, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming stale upload sagas", zap.Duration("staleFor", staleFor))
+	var out []UploadSaga
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		sagas, err := queries.ClaimStaleUploadSagas(ctx, raw.ClaimStaleUploadSagasParams{
+			StaleBefore: time.Now().Add(-staleFor),
+			MaxSagas:    int32(maxSagas),
+		})
+		if err != nil {
+			return err
+		}
+		out = make([]UploadSaga, 0, len(sagas))
+		for _, s := range sagas {
+			saga, err := uploadSaga(s)
+			if err != nil {
+				return err
+			}
+			out = append(out, saga)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func advanceUploadSaga(ctx context.Context, queries Queries, sagaId, from, to string, doc DocMeta) error {
+	n, err := queries.AdvanceUploadSaga(ctx, raw.AdvanceUploadSagaParams{
+		ToState:   raw.UploadSagaState(to),
+		DocID:     NewNullStr(&doc.DocId),
+		BcTknID:   NewNullStr(&doc.BcTknId),
+		SagaID:    sagaId,
+		FromState: raw.UploadSagaState(from),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("upload saga %s is not %s - %w", sagaId, from, ErrSagaStateConflict)
+	}
+	if to == SagaRolledBack || to == SagaFailed {
+		return queries.ReleaseUploadSagaUsage(ctx, sagaId)
+	}
+	return nil
+}
+
+func uploadSaga(s raw.UploadSaga) (UploadSaga, error) {
+	var doc sagaDoc
+	if err := json.Unmarshal(s.DocMeta, &doc); err != nil {
+		return UploadSaga{}, fmt.Errorf("failed to unmarshal upload saga doc meta - %w", err)
+	}
+	doc.DocMeta.ChunkHashes = doc.ChunkHashes
+	if s.DocID.Valid {
+		doc.DocId = s.DocID.String
+	}
+	if s.BcTknID.Valid {
+		doc.BcTknId = s.BcTknID.String
+	}
+	return UploadSaga{
+		SagaId:    s.SagaID,
+		State:     string(s.State),
+		Doc:       doc.DocMeta,
+		Attempts:  int(s.Attempts),
+		LastError: s.LastError.String,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.LastUpdatedAt,
+	}, nil
+}
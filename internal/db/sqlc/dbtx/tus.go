@@ -0,0 +1,190 @@
+package dbtx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// statuses of a resumable upload
+const (
+	TusCreated    = string(raw.TusUploadStatusCREATED)
+	TusFinishing  = string(raw.TusUploadStatusFINISHING)
+	TusCompleted  = string(raw.TusUploadStatusCOMPLETED)
+	TusTerminated = string(raw.TusUploadStatusTERMINATED)
+)
+
+// ErrTusOffsetConflict is returned when a resumable upload is not at the offset a part was sent for
+var ErrTusOffsetConflict = errors.New("upload offset conflict")
+
+// ErrTusUploadNotReceived is returned when a resumable upload being finished is not fully received and
+// waiting to be finished
+var ErrTusUploadNotReceived = errors.New("tus upload is not awaiting finishing")
+
+// TusUpload holds the state of a resumable (tus protocol) upload in postgres
+type TusUpload struct {
+	UploadId  string
+	Length    int64
+	Offset    int64
+	Meta      map[string]string
+	Status    string
+	DocId     string
+	CreatedAt time.Time
+}
+
+// TusUploadPart is a received part of a resumable upload, stored as its own blob store object
+type TusUploadPart struct {
+	Offset  int64
+	Size    int64
+	ObjName string
+}
+
+// CreateTusUpload records a new resumable upload
+func (store *Store) CreateTusUpload(ctx context.Context, in TusUpload) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for creating tus upload", zap.String("uploadId", in.UploadId))
+	meta, err := json.Marshal(in.Meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload metadata - %w", err)
+	}
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		_, err := queries.AddTusUpload(ctx, raw.AddTusUploadParams{
+			UploadID:     in.UploadId,
+			UploadLength: in.Length,
+			Metadata:     NewNullJson(&meta),
+		})
+		return err
+	})
+}
+
+// GetTusUpload returns the state of a resumable upload
+func (store *Store) GetTusUpload(ctx context.Context, uploadId string) (TusUpload, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get tus upload", zap.String("uploadId", uploadId))
+	var out TusUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, err := queries.GetTusUpload(ctx, uploadId)
+		if err != nil {
+			return err
+		}
+		out, err = tusUpload(u)
+		return err
+	})
+	return out, err
+}
+
+// AddTusUploadPart records a part stored in blob store and moves the upload offset past it.
+// It returns ErrTusOffsetConflict when the upload is no longer at the part's offset.
+func (store *Store) AddTusUploadPart(ctx context.Context, uploadId string, part TusUploadPart) (TusUpload, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for adding tus upload part", zap.String("uploadId", uploadId),
+		zap.Int64("partOffset", part.Offset), zap.Int64("partSize", part.Size))
+	var out TusUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, err := queries.AdvanceTusUpload(ctx, raw.AdvanceTusUploadParams{
+			PartSize:   part.Size,
+			UploadID:   uploadId,
+			PartOffset: part.Offset,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTusOffsetConflict
+			}
+			return err
+		}
+		err = queries.AddTusUploadPart(ctx, raw.AddTusUploadPartParams{
+			UploadID:   uploadId,
+			PartOffset: part.Offset,
+			PartSize:   part.Size,
+			ObjectName: part.ObjName,
+		})
+		if err != nil {
+			return err
+		}
+		out, err = tusUpload(u)
+		return err
+	})
+	return out, err
+}
+
+// GetTusUploadParts returns the parts of a resumable upload in offset order
+func (store *Store) GetTusUploadParts(ctx context.Context, uploadId string) ([]TusUploadPart, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get tus upload parts", zap.String("uploadId", uploadId))
+	var out []TusUploadPart
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		parts, err := queries.GetTusUploadParts(ctx, uploadId)
+		if err != nil {
+			return err
+		}
+		out = make([]TusUploadPart, 0, len(parts))
+		for _, p := range parts {
+			out = append(out, TusUploadPart{Offset: p.PartOffset, Size: p.PartSize, ObjName: p.ObjectName})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ClaimTusUpload moves a fully received upload to finishing, so that it is finished only once. Uploads
+// left finishing for longer than staleFor, by an instance which went down, are claimed again.
+// It returns ErrTusUploadNotReceived when the upload is not fully received, being finished or done.
+func (store *Store) ClaimTusUpload(ctx context.Context, uploadId string, staleFor time.Duration) (TusUpload, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for claiming tus upload", zap.String("uploadId", uploadId))
+	var out TusUpload
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		u, err := queries.ClaimTusUpload(ctx, raw.ClaimTusUploadParams{
+			UploadID:    uploadId,
+			StaleBefore: time.Now().Add(-staleFor),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTusUploadNotReceived
+			}
+			return err
+		}
+		out, err = tusUpload(u)
+		return err
+	})
+	return out, err
+}
+
+// FinishTusUpload marks a resumable upload as completed or terminated, or as created again to be retried
+func (store *Store) FinishTusUpload(ctx context.Context, uploadId, status, docId string) error {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for finishing tus upload", zap.String("uploadId", uploadId),
+		zap.String("status", status))
+	return store.execTxWithRetry(ctx, func(queries Queries) error {
+		return queries.UpdateTusUploadStatus(ctx, raw.UpdateTusUploadStatusParams{
+			UploadID: uploadId,
+			Status:   raw.TusUploadStatus(status),
+			DocID:    NewNullStr(&docId),
+		})
+	})
+}
+
+func tusUpload(u raw.TusUpload) (TusUpload, error) {
+	out := TusUpload{
+		UploadId:  u.UploadID,
+		Length:    u.UploadLength,
+		Offset:    u.UploadOffset,
+		Status:    string(u.Status),
+		DocId:     u.DocID.String,
+		CreatedAt: u.CreatedAt,
+	}
+	if u.Metadata.Valid {
+		if err := json.Unmarshal(u.Metadata.RawMessage, &out.Meta); err != nil {
+			return out, fmt.Errorf("failed to unmarshal upload metadata - %w", err)
+		}
+	}
+	return out, nil
+}
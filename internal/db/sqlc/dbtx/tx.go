@@ -216,6 +216,24 @@ func newNullInt64(i *int64) sql.NullInt64 {
 	}
 }
 
+func newNullTime(t *time.Time) sql.NullTime {
+	if t == nil || t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{
+		Time:  *t,
+		Valid: true,
+	}
+}
+
+// timePtr returns the time of a NullTime, nil when it is null
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
 // NewNullJson returns NullRawMessage with correct RawMessage and Valid fields
 func NewNullJson(j *[]byte) pqtype.NullRawMessage {
 	if j == nil || len(*j) == 0 {
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"math/bits"
 	"reflect"
 	"testing"
 	"time"
@@ -61,6 +62,52 @@ func Test_newNullInt64(t *testing.T) {
 	}
 }
 
+// Test_newNullTime tests if newNullTime returns correct sql.NullTime, and timePtr reads it back
+func Test_newNullTime(t *testing.T) {
+	validVal := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	type args struct {
+		t *time.Time
+	}
+	tests := []struct {
+		name string
+		args args
+		want sql.NullTime
+	}{
+		{
+			name: "nil time",
+			args: args{
+				t: nil,
+			},
+			want: sql.NullTime{},
+		},
+		{
+			name: "zero time",
+			args: args{
+				t: new(time.Time),
+			},
+			want: sql.NullTime{},
+		},
+		{
+			name: "valid time",
+			args: args{
+				t: &validVal,
+			},
+			want: sql.NullTime{Valid: true, Time: validVal},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newNullTime(tt.args.t)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newNullTime() = %v, want %v", got, tt.want)
+			}
+			if p := timePtr(got); tt.want.Valid != (p != nil) {
+				t.Errorf("timePtr() = %v, want valid %v", p, tt.want.Valid)
+			}
+		})
+	}
+}
+
 // TestNewNullStr tests if NewNullString returns correct sql.NullString
 func Test_NewNullStr(t *testing.T) {
 	validStr := "abcd"
@@ -408,3 +455,33 @@ func Test_randomInt(t *testing.T) {
 		})
 	}
 }
+
+// Test_pHashBands tests if pHashBands returns every band value within the radius and nothing else
+func Test_pHashBands(t *testing.T) {
+	h := uint64(0x0001_00ff_f0f0_ffff)
+	tests := []struct {
+		name   string
+		radius int
+		count  int
+	}{
+		{name: "exact", radius: 0, count: 1},
+		{name: "one bit", radius: 1, count: 17},
+		{name: "three bits", radius: 3, count: 697},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bands := pHashBands(h, tt.radius)
+			for i, band := range bands {
+				want := int64((h >> (48 - 16*i)) & 0xffff)
+				assert.Len(t, band, tt.count)
+				seen := map[int64]bool{}
+				for _, b := range band {
+					assert.False(t, seen[b], "band %d value %x repeated", i, b)
+					seen[b] = true
+					assert.LessOrEqual(t, bits.OnesCount64(uint64(b^want)), tt.radius)
+				}
+				assert.True(t, seen[want])
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package dbtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/raw"
+	"github.com/vposham/trustdoc/log"
+)
+
+// ErrQuotaExceeded is returned when an upload does not fit in the quota of its owner
+var ErrQuotaExceeded = errors.New("owner quota exceeded")
+
+// Usage is how many documents, and how many bytes of content, an owner has uploaded
+type Usage struct {
+	Docs  int64 `json:"docs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Quota is the max number of documents, and bytes of content, an owner can upload. 0 is unlimited.
+type Quota struct {
+	MaxDocs  int64
+	MaxBytes int64
+}
+
+// GetUserUsage returns the upload usage of an owner, counting their uploads in progress.
+// Owners without documents have no usage.
+func (store *Store) GetUserUsage(ctx context.Context, email string) (Usage, error) {
+	logger := log.GetLogger(ctx)
+	logger.Info("started db tx for get user usage", zap.String("email", email))
+	var u Usage
+	err := store.execTxWithRetry(ctx, func(queries Queries) error {
+		row, err := queries.GetUserUsage(ctx, email)
+		if err != nil {
+			return err
+		}
+		u = Usage{Docs: row.DocCount, Bytes: row.DocBytes}
+		return nil
+	})
+	return u, err
+}
+
+// reserveUsage adds a document of size bytes to the usage of its owner, unless it takes them past quota
+func reserveUsage(ctx context.Context, queries Queries, email string, size int64, quota Quota) error {
+	if err := queries.InitUserUsage(ctx, email); err != nil {
+		return err
+	}
+	n, err := queries.ReserveUserUsage(ctx, raw.ReserveUserUsageParams{
+		Size:       size,
+		OwnerEmail: email,
+		MaxDocs:    quota.MaxDocs,
+		MaxBytes:   quota.MaxBytes,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%d bytes doc of %s - %w", size, email, ErrQuotaExceeded)
+	}
+	return nil
+}
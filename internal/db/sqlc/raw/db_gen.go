@@ -24,39 +24,258 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.addDirectUploadStmt, err = db.PrepareContext(ctx, addDirectUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query AddDirectUpload: %w", err)
+	}
 	if q.addDocStmt, err = db.PrepareContext(ctx, addDoc); err != nil {
 		return nil, fmt.Errorf("error preparing query AddDoc: %w", err)
 	}
+	if q.addDocChunksStmt, err = db.PrepareContext(ctx, addDocChunks); err != nil {
+		return nil, fmt.Errorf("error preparing query AddDocChunks: %w", err)
+	}
+	if q.addDocClaimStmt, err = db.PrepareContext(ctx, addDocClaim); err != nil {
+		return nil, fmt.Errorf("error preparing query AddDocClaim: %w", err)
+	}
+	if q.addIdempotencyKeyStmt, err = db.PrepareContext(ctx, addIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query AddIdempotencyKey: %w", err)
+	}
+	if q.addTusUploadStmt, err = db.PrepareContext(ctx, addTusUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query AddTusUpload: %w", err)
+	}
+	if q.addTusUploadPartStmt, err = db.PrepareContext(ctx, addTusUploadPart); err != nil {
+		return nil, fmt.Errorf("error preparing query AddTusUploadPart: %w", err)
+	}
+	if q.addUploadSagaStmt, err = db.PrepareContext(ctx, addUploadSaga); err != nil {
+		return nil, fmt.Errorf("error preparing query AddUploadSaga: %w", err)
+	}
 	if q.addUserStmt, err = db.PrepareContext(ctx, addUser); err != nil {
 		return nil, fmt.Errorf("error preparing query AddUser: %w", err)
 	}
+	if q.advanceTusUploadStmt, err = db.PrepareContext(ctx, advanceTusUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query AdvanceTusUpload: %w", err)
+	}
+	if q.advanceUploadSagaStmt, err = db.PrepareContext(ctx, advanceUploadSaga); err != nil {
+		return nil, fmt.Errorf("error preparing query AdvanceUploadSaga: %w", err)
+	}
+	if q.claimDirectUploadStmt, err = db.PrepareContext(ctx, claimDirectUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimDirectUpload: %w", err)
+	}
+	if q.claimExpiredDirectUploadsStmt, err = db.PrepareContext(ctx, claimExpiredDirectUploads); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimExpiredDirectUploads: %w", err)
+	}
+	if q.claimExpiringDocsStmt, err = db.PrepareContext(ctx, claimExpiringDocs); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimExpiringDocs: %w", err)
+	}
+	if q.claimStaleUploadSagasStmt, err = db.PrepareContext(ctx, claimStaleUploadSagas); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimStaleUploadSagas: %w", err)
+	}
+	if q.claimTusUploadStmt, err = db.PrepareContext(ctx, claimTusUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimTusUpload: %w", err)
+	}
+	if q.completeIdempotencyKeyStmt, err = db.PrepareContext(ctx, completeIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query CompleteIdempotencyKey: %w", err)
+	}
+	if q.decideDocClaimStmt, err = db.PrepareContext(ctx, decideDocClaim); err != nil {
+		return nil, fmt.Errorf("error preparing query DecideDocClaim: %w", err)
+	}
+	if q.deleteExpiredIdempotencyKeysStmt, err = db.PrepareContext(ctx, deleteExpiredIdempotencyKeys); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteExpiredIdempotencyKeys: %w", err)
+	}
+	if q.deleteIdempotencyKeyStmt, err = db.PrepareContext(ctx, deleteIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteIdempotencyKey: %w", err)
+	}
+	if q.extendIdempotencyKeyStmt, err = db.PrepareContext(ctx, extendIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query ExtendIdempotencyKey: %w", err)
+	}
+	if q.failUploadSagaStepStmt, err = db.PrepareContext(ctx, failUploadSagaStep); err != nil {
+		return nil, fmt.Errorf("error preparing query FailUploadSagaStep: %w", err)
+	}
+	if q.getDirectUploadStmt, err = db.PrepareContext(ctx, getDirectUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDirectUpload: %w", err)
+	}
 	if q.getDocStmt, err = db.PrepareContext(ctx, getDoc); err != nil {
 		return nil, fmt.Errorf("error preparing query GetDoc: %w", err)
 	}
 	if q.getDocByHashStmt, err = db.PrepareContext(ctx, getDocByHash); err != nil {
 		return nil, fmt.Errorf("error preparing query GetDocByHash: %w", err)
 	}
+	if q.getDocByMintedIdStmt, err = db.PrepareContext(ctx, getDocByMintedId); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDocByMintedId: %w", err)
+	}
+	if q.getDocChunksStmt, err = db.PrepareContext(ctx, getDocChunks); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDocChunks: %w", err)
+	}
+	if q.getDocClaimStmt, err = db.PrepareContext(ctx, getDocClaim); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDocClaim: %w", err)
+	}
+	if q.getIdempotencyKeyStmt, err = db.PrepareContext(ctx, getIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query GetIdempotencyKey: %w", err)
+	}
+	if q.getSimilarDocsStmt, err = db.PrepareContext(ctx, getSimilarDocs); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSimilarDocs: %w", err)
+	}
+	if q.getTusUploadStmt, err = db.PrepareContext(ctx, getTusUpload); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTusUpload: %w", err)
+	}
+	if q.getTusUploadPartsStmt, err = db.PrepareContext(ctx, getTusUploadParts); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTusUploadParts: %w", err)
+	}
+	if q.getUploadSagaStmt, err = db.PrepareContext(ctx, getUploadSaga); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUploadSaga: %w", err)
+	}
 	if q.getUserStmt, err = db.PrepareContext(ctx, getUser); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUser: %w", err)
 	}
 	if q.getUserByIdStmt, err = db.PrepareContext(ctx, getUserById); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUserById: %w", err)
 	}
+	if q.getUserUsageStmt, err = db.PrepareContext(ctx, getUserUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUserUsage: %w", err)
+	}
+	if q.initUserUsageStmt, err = db.PrepareContext(ctx, initUserUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query InitUserUsage: %w", err)
+	}
+	if q.listUserDocsStmt, err = db.PrepareContext(ctx, listUserDocs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUserDocs: %w", err)
+	}
+	if q.releaseDocExpiryNoticeStmt, err = db.PrepareContext(ctx, releaseDocExpiryNotice); err != nil {
+		return nil, fmt.Errorf("error preparing query ReleaseDocExpiryNotice: %w", err)
+	}
+	if q.releaseUploadSagaUsageStmt, err = db.PrepareContext(ctx, releaseUploadSagaUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ReleaseUploadSagaUsage: %w", err)
+	}
+	if q.reserveUserUsageStmt, err = db.PrepareContext(ctx, reserveUserUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ReserveUserUsage: %w", err)
+	}
+	if q.searchDocsByTagsStmt, err = db.PrepareContext(ctx, searchDocsByTags); err != nil {
+		return nil, fmt.Errorf("error preparing query SearchDocsByTags: %w", err)
+	}
+	if q.updateDirectUploadStatusStmt, err = db.PrepareContext(ctx, updateDirectUploadStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateDirectUploadStatus: %w", err)
+	}
+	if q.updateTusUploadStatusStmt, err = db.PrepareContext(ctx, updateTusUploadStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateTusUploadStatus: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.addDirectUploadStmt != nil {
+		if cerr := q.addDirectUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addDirectUploadStmt: %w", cerr)
+		}
+	}
 	if q.addDocStmt != nil {
 		if cerr := q.addDocStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing addDocStmt: %w", cerr)
 		}
 	}
+	if q.addDocChunksStmt != nil {
+		if cerr := q.addDocChunksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addDocChunksStmt: %w", cerr)
+		}
+	}
+	if q.addDocClaimStmt != nil {
+		if cerr := q.addDocClaimStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addDocClaimStmt: %w", cerr)
+		}
+	}
+	if q.addIdempotencyKeyStmt != nil {
+		if cerr := q.addIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.addTusUploadStmt != nil {
+		if cerr := q.addTusUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addTusUploadStmt: %w", cerr)
+		}
+	}
+	if q.addTusUploadPartStmt != nil {
+		if cerr := q.addTusUploadPartStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addTusUploadPartStmt: %w", cerr)
+		}
+	}
+	if q.addUploadSagaStmt != nil {
+		if cerr := q.addUploadSagaStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addUploadSagaStmt: %w", cerr)
+		}
+	}
 	if q.addUserStmt != nil {
 		if cerr := q.addUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing addUserStmt: %w", cerr)
 		}
 	}
+	if q.advanceTusUploadStmt != nil {
+		if cerr := q.advanceTusUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing advanceTusUploadStmt: %w", cerr)
+		}
+	}
+	if q.advanceUploadSagaStmt != nil {
+		if cerr := q.advanceUploadSagaStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing advanceUploadSagaStmt: %w", cerr)
+		}
+	}
+	if q.claimDirectUploadStmt != nil {
+		if cerr := q.claimDirectUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimDirectUploadStmt: %w", cerr)
+		}
+	}
+	if q.claimExpiredDirectUploadsStmt != nil {
+		if cerr := q.claimExpiredDirectUploadsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimExpiredDirectUploadsStmt: %w", cerr)
+		}
+	}
+	if q.claimExpiringDocsStmt != nil {
+		if cerr := q.claimExpiringDocsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimExpiringDocsStmt: %w", cerr)
+		}
+	}
+	if q.claimStaleUploadSagasStmt != nil {
+		if cerr := q.claimStaleUploadSagasStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimStaleUploadSagasStmt: %w", cerr)
+		}
+	}
+	if q.claimTusUploadStmt != nil {
+		if cerr := q.claimTusUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimTusUploadStmt: %w", cerr)
+		}
+	}
+	if q.completeIdempotencyKeyStmt != nil {
+		if cerr := q.completeIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing completeIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.decideDocClaimStmt != nil {
+		if cerr := q.decideDocClaimStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing decideDocClaimStmt: %w", cerr)
+		}
+	}
+	if q.deleteExpiredIdempotencyKeysStmt != nil {
+		if cerr := q.deleteExpiredIdempotencyKeysStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteExpiredIdempotencyKeysStmt: %w", cerr)
+		}
+	}
+	if q.deleteIdempotencyKeyStmt != nil {
+		if cerr := q.deleteIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.extendIdempotencyKeyStmt != nil {
+		if cerr := q.extendIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing extendIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.failUploadSagaStepStmt != nil {
+		if cerr := q.failUploadSagaStepStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing failUploadSagaStepStmt: %w", cerr)
+		}
+	}
+	if q.getDirectUploadStmt != nil {
+		if cerr := q.getDirectUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDirectUploadStmt: %w", cerr)
+		}
+	}
 	if q.getDocStmt != nil {
 		if cerr := q.getDocStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getDocStmt: %w", cerr)
@@ -67,6 +286,46 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getDocByHashStmt: %w", cerr)
 		}
 	}
+	if q.getDocByMintedIdStmt != nil {
+		if cerr := q.getDocByMintedIdStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDocByMintedIdStmt: %w", cerr)
+		}
+	}
+	if q.getDocChunksStmt != nil {
+		if cerr := q.getDocChunksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDocChunksStmt: %w", cerr)
+		}
+	}
+	if q.getDocClaimStmt != nil {
+		if cerr := q.getDocClaimStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDocClaimStmt: %w", cerr)
+		}
+	}
+	if q.getIdempotencyKeyStmt != nil {
+		if cerr := q.getIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.getSimilarDocsStmt != nil {
+		if cerr := q.getSimilarDocsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSimilarDocsStmt: %w", cerr)
+		}
+	}
+	if q.getTusUploadStmt != nil {
+		if cerr := q.getTusUploadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTusUploadStmt: %w", cerr)
+		}
+	}
+	if q.getTusUploadPartsStmt != nil {
+		if cerr := q.getTusUploadPartsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTusUploadPartsStmt: %w", cerr)
+		}
+	}
+	if q.getUploadSagaStmt != nil {
+		if cerr := q.getUploadSagaStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUploadSagaStmt: %w", cerr)
+		}
+	}
 	if q.getUserStmt != nil {
 		if cerr := q.getUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getUserStmt: %w", cerr)
@@ -77,6 +336,51 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getUserByIdStmt: %w", cerr)
 		}
 	}
+	if q.getUserUsageStmt != nil {
+		if cerr := q.getUserUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUserUsageStmt: %w", cerr)
+		}
+	}
+	if q.initUserUsageStmt != nil {
+		if cerr := q.initUserUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing initUserUsageStmt: %w", cerr)
+		}
+	}
+	if q.listUserDocsStmt != nil {
+		if cerr := q.listUserDocsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUserDocsStmt: %w", cerr)
+		}
+	}
+	if q.releaseDocExpiryNoticeStmt != nil {
+		if cerr := q.releaseDocExpiryNoticeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing releaseDocExpiryNoticeStmt: %w", cerr)
+		}
+	}
+	if q.releaseUploadSagaUsageStmt != nil {
+		if cerr := q.releaseUploadSagaUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing releaseUploadSagaUsageStmt: %w", cerr)
+		}
+	}
+	if q.reserveUserUsageStmt != nil {
+		if cerr := q.reserveUserUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing reserveUserUsageStmt: %w", cerr)
+		}
+	}
+	if q.searchDocsByTagsStmt != nil {
+		if cerr := q.searchDocsByTagsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing searchDocsByTagsStmt: %w", cerr)
+		}
+	}
+	if q.updateDirectUploadStatusStmt != nil {
+		if cerr := q.updateDirectUploadStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateDirectUploadStatusStmt: %w", cerr)
+		}
+	}
+	if q.updateTusUploadStatusStmt != nil {
+		if cerr := q.updateTusUploadStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateTusUploadStatusStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -114,25 +418,101 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db               DBTX
-	tx               *sql.Tx
-	addDocStmt       *sql.Stmt
-	addUserStmt      *sql.Stmt
-	getDocStmt       *sql.Stmt
-	getDocByHashStmt *sql.Stmt
-	getUserStmt      *sql.Stmt
-	getUserByIdStmt  *sql.Stmt
+	db                               DBTX
+	tx                               *sql.Tx
+	addDirectUploadStmt              *sql.Stmt
+	addDocStmt                       *sql.Stmt
+	addDocChunksStmt                 *sql.Stmt
+	addDocClaimStmt                  *sql.Stmt
+	addIdempotencyKeyStmt            *sql.Stmt
+	addTusUploadStmt                 *sql.Stmt
+	addTusUploadPartStmt             *sql.Stmt
+	addUploadSagaStmt                *sql.Stmt
+	addUserStmt                      *sql.Stmt
+	advanceTusUploadStmt             *sql.Stmt
+	advanceUploadSagaStmt            *sql.Stmt
+	claimDirectUploadStmt            *sql.Stmt
+	claimExpiredDirectUploadsStmt    *sql.Stmt
+	claimExpiringDocsStmt            *sql.Stmt
+	claimStaleUploadSagasStmt        *sql.Stmt
+	claimTusUploadStmt               *sql.Stmt
+	completeIdempotencyKeyStmt       *sql.Stmt
+	decideDocClaimStmt               *sql.Stmt
+	deleteExpiredIdempotencyKeysStmt *sql.Stmt
+	deleteIdempotencyKeyStmt         *sql.Stmt
+	extendIdempotencyKeyStmt         *sql.Stmt
+	failUploadSagaStepStmt           *sql.Stmt
+	getDirectUploadStmt              *sql.Stmt
+	getDocStmt                       *sql.Stmt
+	getDocByHashStmt                 *sql.Stmt
+	getDocByMintedIdStmt             *sql.Stmt
+	getDocChunksStmt                 *sql.Stmt
+	getDocClaimStmt                  *sql.Stmt
+	getIdempotencyKeyStmt            *sql.Stmt
+	getSimilarDocsStmt               *sql.Stmt
+	getTusUploadStmt                 *sql.Stmt
+	getTusUploadPartsStmt            *sql.Stmt
+	getUploadSagaStmt                *sql.Stmt
+	getUserStmt                      *sql.Stmt
+	getUserByIdStmt                  *sql.Stmt
+	getUserUsageStmt                 *sql.Stmt
+	initUserUsageStmt                *sql.Stmt
+	listUserDocsStmt                 *sql.Stmt
+	releaseDocExpiryNoticeStmt       *sql.Stmt
+	releaseUploadSagaUsageStmt       *sql.Stmt
+	reserveUserUsageStmt             *sql.Stmt
+	searchDocsByTagsStmt             *sql.Stmt
+	updateDirectUploadStatusStmt     *sql.Stmt
+	updateTusUploadStatusStmt        *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:               tx,
-		tx:               tx,
-		addDocStmt:       q.addDocStmt,
-		addUserStmt:      q.addUserStmt,
-		getDocStmt:       q.getDocStmt,
-		getDocByHashStmt: q.getDocByHashStmt,
-		getUserStmt:      q.getUserStmt,
-		getUserByIdStmt:  q.getUserByIdStmt,
+		db:                               tx,
+		tx:                               tx,
+		addDirectUploadStmt:              q.addDirectUploadStmt,
+		addDocStmt:                       q.addDocStmt,
+		addDocChunksStmt:                 q.addDocChunksStmt,
+		addDocClaimStmt:                  q.addDocClaimStmt,
+		addIdempotencyKeyStmt:            q.addIdempotencyKeyStmt,
+		addTusUploadStmt:                 q.addTusUploadStmt,
+		addTusUploadPartStmt:             q.addTusUploadPartStmt,
+		addUploadSagaStmt:                q.addUploadSagaStmt,
+		addUserStmt:                      q.addUserStmt,
+		advanceTusUploadStmt:             q.advanceTusUploadStmt,
+		advanceUploadSagaStmt:            q.advanceUploadSagaStmt,
+		claimDirectUploadStmt:            q.claimDirectUploadStmt,
+		claimExpiredDirectUploadsStmt:    q.claimExpiredDirectUploadsStmt,
+		claimExpiringDocsStmt:            q.claimExpiringDocsStmt,
+		claimStaleUploadSagasStmt:        q.claimStaleUploadSagasStmt,
+		claimTusUploadStmt:               q.claimTusUploadStmt,
+		completeIdempotencyKeyStmt:       q.completeIdempotencyKeyStmt,
+		decideDocClaimStmt:               q.decideDocClaimStmt,
+		deleteExpiredIdempotencyKeysStmt: q.deleteExpiredIdempotencyKeysStmt,
+		deleteIdempotencyKeyStmt:         q.deleteIdempotencyKeyStmt,
+		extendIdempotencyKeyStmt:         q.extendIdempotencyKeyStmt,
+		failUploadSagaStepStmt:           q.failUploadSagaStepStmt,
+		getDirectUploadStmt:              q.getDirectUploadStmt,
+		getDocStmt:                       q.getDocStmt,
+		getDocByHashStmt:                 q.getDocByHashStmt,
+		getDocByMintedIdStmt:             q.getDocByMintedIdStmt,
+		getDocChunksStmt:                 q.getDocChunksStmt,
+		getDocClaimStmt:                  q.getDocClaimStmt,
+		getIdempotencyKeyStmt:            q.getIdempotencyKeyStmt,
+		getSimilarDocsStmt:               q.getSimilarDocsStmt,
+		getTusUploadStmt:                 q.getTusUploadStmt,
+		getTusUploadPartsStmt:            q.getTusUploadPartsStmt,
+		getUploadSagaStmt:                q.getUploadSagaStmt,
+		getUserStmt:                      q.getUserStmt,
+		getUserByIdStmt:                  q.getUserByIdStmt,
+		getUserUsageStmt:                 q.getUserUsageStmt,
+		initUserUsageStmt:                q.initUserUsageStmt,
+		listUserDocsStmt:                 q.listUserDocsStmt,
+		releaseDocExpiryNoticeStmt:       q.releaseDocExpiryNoticeStmt,
+		releaseUploadSagaUsageStmt:       q.releaseUploadSagaUsageStmt,
+		reserveUserUsageStmt:             q.reserveUserUsageStmt,
+		searchDocsByTagsStmt:             q.searchDocsByTagsStmt,
+		updateDirectUploadStatusStmt:     q.updateDirectUploadStatusStmt,
+		updateTusUploadStatusStmt:        q.updateTusUploadStatusStmt,
 	}
 }
@@ -8,22 +8,37 @@ package raw
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/sqlc-dev/pqtype"
 )
 
 const addDoc = `-- name: AddDoc :one
-INSERT INTO documents (doc_id, title, description, file_name, doc_hash, doc_minted_id, user_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at
+INSERT INTO documents (doc_id, title, description, file_name, doc_hash, doc_minted_id, user_id, hash_algo, chunk_size,
+                       phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+RETURNING id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
 `
 
 type AddDocParams struct {
-	DocID       string         `json:"docId"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	FileName    string         `json:"fileName"`
-	DocHash     string         `json:"docHash"`
-	DocMintedID string         `json:"docMintedId"`
-	UserID      int64          `json:"userId"`
+	DocID       string                `json:"docId"`
+	Title       string                `json:"title"`
+	Description sql.NullString        `json:"description"`
+	FileName    string                `json:"fileName"`
+	DocHash     string                `json:"docHash"`
+	DocMintedID string                `json:"docMintedId"`
+	UserID      int64                 `json:"userId"`
+	HashAlgo    string                `json:"hashAlgo"`
+	ChunkSize   sql.NullInt64         `json:"chunkSize"`
+	Phash       sql.NullInt64         `json:"phash"`
+	MimeType    string                `json:"mimeType"`
+	FileSize    int64                 `json:"fileSize"`
+	ScanStatus  sql.NullString        `json:"scanStatus"`
+	ScanEngine  sql.NullString        `json:"scanEngine"`
+	Tags        pqtype.NullRawMessage `json:"tags"`
+	ValidFrom   sql.NullTime          `json:"validFrom"`
+	ValidUntil  sql.NullTime          `json:"validUntil"`
 }
 
 func (q *Queries) AddDoc(ctx context.Context, arg AddDocParams) (Document, error) {
@@ -35,6 +50,16 @@ func (q *Queries) AddDoc(ctx context.Context, arg AddDocParams) (Document, error
 		arg.DocHash,
 		arg.DocMintedID,
 		arg.UserID,
+		arg.HashAlgo,
+		arg.ChunkSize,
+		arg.Phash,
+		arg.MimeType,
+		arg.FileSize,
+		arg.ScanStatus,
+		arg.ScanEngine,
+		arg.Tags,
+		arg.ValidFrom,
+		arg.ValidUntil,
 	)
 	var i Document
 	err := row.Scan(
@@ -49,12 +74,107 @@ func (q *Queries) AddDoc(ctx context.Context, arg AddDocParams) (Document, error
 		&i.UserID,
 		&i.UploadedAt,
 		&i.LastUpdatedAt,
+		&i.HashAlgo,
+		&i.ChunkSize,
+		&i.Phash,
+		&i.MimeType,
+		&i.FileSize,
+		&i.ScanStatus,
+		&i.ScanEngine,
+		&i.Tags,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.ExpiryNotifiedAt,
+		&i.DeletedAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
 
+const addDocChunks = `-- name: AddDocChunks :exec
+INSERT INTO document_chunks (document_id, chunk_index, chunk_hash)
+SELECT $1::BIGINT, (c.ord - 1)::INT, c.chunk_hash
+FROM unnest($2::TEXT[]) WITH ORDINALITY AS c(chunk_hash, ord)
+`
+
+type AddDocChunksParams struct {
+	DocumentID  int64    `json:"documentId"`
+	ChunkHashes []string `json:"chunkHashes"`
+}
+
+func (q *Queries) AddDocChunks(ctx context.Context, arg AddDocChunksParams) error {
+	_, err := q.exec(ctx, q.addDocChunksStmt, addDocChunks, arg.DocumentID, pq.Array(arg.ChunkHashes))
+	return err
+}
+
+const claimExpiringDocs = `-- name: ClaimExpiringDocs :many
+UPDATE documents
+SET expiry_notified_at = NOW()
+WHERE id IN (SELECT d.id
+             FROM documents d
+             WHERE d.valid_until > NOW()
+               AND d.valid_until <= $1
+               AND d.expiry_notified_at IS NULL
+             ORDER BY d.valid_until
+             LIMIT $2 FOR UPDATE SKIP LOCKED)
+RETURNING id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
+`
+
+type ClaimExpiringDocsParams struct {
+	ExpiringBefore sql.NullTime `json:"expiringBefore"`
+	MaxDocs        int32        `json:"maxDocs"`
+}
+
+func (q *Queries) ClaimExpiringDocs(ctx context.Context, arg ClaimExpiringDocsParams) ([]Document, error) {
+	rows, err := q.query(ctx, q.claimExpiringDocsStmt, claimExpiringDocs, arg.ExpiringBefore, arg.MaxDocs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.DocID,
+			&i.Title,
+			&i.Description,
+			&i.FileName,
+			&i.DocHash,
+			&i.DocMintedID,
+			&i.DocTknMined,
+			&i.UserID,
+			&i.UploadedAt,
+			&i.LastUpdatedAt,
+			&i.HashAlgo,
+			&i.ChunkSize,
+			&i.Phash,
+			&i.MimeType,
+			&i.FileSize,
+			&i.ScanStatus,
+			&i.ScanEngine,
+			&i.Tags,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.ExpiryNotifiedAt,
+			&i.DeletedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDoc = `-- name: GetDoc :one
-SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at
+SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
 FROM documents
 WHERE doc_id = $1
 LIMIT 1
@@ -75,12 +195,25 @@ func (q *Queries) GetDoc(ctx context.Context, docID string) (Document, error) {
 		&i.UserID,
 		&i.UploadedAt,
 		&i.LastUpdatedAt,
+		&i.HashAlgo,
+		&i.ChunkSize,
+		&i.Phash,
+		&i.MimeType,
+		&i.FileSize,
+		&i.ScanStatus,
+		&i.ScanEngine,
+		&i.Tags,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.ExpiryNotifiedAt,
+		&i.DeletedAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
 
 const getDocByHash = `-- name: GetDocByHash :one
-SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at
+SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
 FROM documents
 WHERE doc_hash = $1
 LIMIT 1
@@ -101,6 +234,313 @@ func (q *Queries) GetDocByHash(ctx context.Context, docHash string) (Document, e
 		&i.UserID,
 		&i.UploadedAt,
 		&i.LastUpdatedAt,
+		&i.HashAlgo,
+		&i.ChunkSize,
+		&i.Phash,
+		&i.MimeType,
+		&i.FileSize,
+		&i.ScanStatus,
+		&i.ScanEngine,
+		&i.Tags,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.ExpiryNotifiedAt,
+		&i.DeletedAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
+
+const getDocByMintedId = `-- name: GetDocByMintedId :one
+SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
+FROM documents
+WHERE doc_minted_id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetDocByMintedId(ctx context.Context, docMintedID string) (Document, error) {
+	row := q.queryRow(ctx, q.getDocByMintedIdStmt, getDocByMintedId, docMintedID)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.DocID,
+		&i.Title,
+		&i.Description,
+		&i.FileName,
+		&i.DocHash,
+		&i.DocMintedID,
+		&i.DocTknMined,
+		&i.UserID,
+		&i.UploadedAt,
+		&i.LastUpdatedAt,
+		&i.HashAlgo,
+		&i.ChunkSize,
+		&i.Phash,
+		&i.MimeType,
+		&i.FileSize,
+		&i.ScanStatus,
+		&i.ScanEngine,
+		&i.Tags,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.ExpiryNotifiedAt,
+		&i.DeletedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getDocChunks = `-- name: GetDocChunks :many
+SELECT chunk_hash
+FROM document_chunks
+WHERE document_id = $1
+ORDER BY chunk_index
+`
+
+func (q *Queries) GetDocChunks(ctx context.Context, documentID int64) ([]string, error) {
+	rows, err := q.query(ctx, q.getDocChunksStmt, getDocChunks, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var chunk_hash string
+		if err := rows.Scan(&chunk_hash); err != nil {
+			return nil, err
+		}
+		items = append(items, chunk_hash)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSimilarDocs = `-- name: GetSimilarDocs :many
+SELECT doc_id,
+       bit_count((phash # $1::BIGINT)::BIT(64))::INT AS distance
+FROM documents
+WHERE phash IS NOT NULL
+  AND (((phash >> 48) & 65535) = ANY ($2::BIGINT[])
+    OR ((phash >> 32) & 65535) = ANY ($3::BIGINT[])
+    OR ((phash >> 16) & 65535) = ANY ($4::BIGINT[])
+    OR (phash & 65535) = ANY ($5::BIGINT[]))
+  AND doc_hash <> $6
+  AND bit_count((phash # $1::BIGINT)::BIT(64)) <= $7::INT
+ORDER BY distance, id
+LIMIT $8::INT
+`
+
+type GetSimilarDocsParams struct {
+	Phash       int64   `json:"phash"`
+	Band0       []int64 `json:"band0"`
+	Band1       []int64 `json:"band1"`
+	Band2       []int64 `json:"band2"`
+	Band3       []int64 `json:"band3"`
+	DocHash     string  `json:"docHash"`
+	MaxDistance int32   `json:"maxDistance"`
+	MaxResults  int32   `json:"maxResults"`
+}
+
+type GetSimilarDocsRow struct {
+	DocID    string `json:"docId"`
+	Distance int32  `json:"distance"`
+}
+
+func (q *Queries) GetSimilarDocs(ctx context.Context, arg GetSimilarDocsParams) ([]GetSimilarDocsRow, error) {
+	rows, err := q.query(ctx, q.getSimilarDocsStmt, getSimilarDocs,
+		arg.Phash,
+		pq.Array(arg.Band0),
+		pq.Array(arg.Band1),
+		pq.Array(arg.Band2),
+		pq.Array(arg.Band3),
+		arg.DocHash,
+		arg.MaxDistance,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSimilarDocsRow{}
+	for rows.Next() {
+		var i GetSimilarDocsRow
+		if err := rows.Scan(&i.DocID, &i.Distance); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDocs = `-- name: ListUserDocs :many
+SELECT id, doc_id, title, description, file_name, doc_hash, doc_minted_id, doc_tkn_mined, user_id, uploaded_at, last_updated_at, hash_algo, chunk_size, phash, mime_type, file_size, scan_status, scan_engine, tags, valid_from, valid_until, expiry_notified_at, deleted_at, revoked_at
+FROM documents
+WHERE user_id = $1
+  AND ($2::TIMESTAMPTZ IS NULL OR uploaded_at >= $2)
+  AND ($3::TIMESTAMPTZ IS NULL OR uploaded_at < $3)
+  AND CASE $4::TEXT
+          WHEN 'active' THEN deleted_at IS NULL AND revoked_at IS NULL
+          WHEN 'deleted' THEN deleted_at IS NOT NULL AND revoked_at IS NULL
+          WHEN 'revoked' THEN revoked_at IS NOT NULL
+          ELSE TRUE
+    END
+  AND coalesce(tags, '{}'::JSONB) @> $5::JSONB
+  AND ($6::TIMESTAMPTZ IS NULL
+    OR (uploaded_at, id) < ($6, $7::BIGINT))
+ORDER BY uploaded_at DESC, id DESC
+LIMIT $8::INT
+`
+
+type ListUserDocsParams struct {
+	UserID           int64           `json:"userId"`
+	UploadedAfter    sql.NullTime    `json:"uploadedAfter"`
+	UploadedBefore   sql.NullTime    `json:"uploadedBefore"`
+	Status           string          `json:"status"`
+	Tags             json.RawMessage `json:"tags"`
+	CursorUploadedAt sql.NullTime    `json:"cursorUploadedAt"`
+	CursorID         int64           `json:"cursorId"`
+	MaxResults       int32           `json:"maxResults"`
+}
+
+func (q *Queries) ListUserDocs(ctx context.Context, arg ListUserDocsParams) ([]Document, error) {
+	rows, err := q.query(ctx, q.listUserDocsStmt, listUserDocs,
+		arg.UserID,
+		arg.UploadedAfter,
+		arg.UploadedBefore,
+		arg.Status,
+		arg.Tags,
+		arg.CursorUploadedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.DocID,
+			&i.Title,
+			&i.Description,
+			&i.FileName,
+			&i.DocHash,
+			&i.DocMintedID,
+			&i.DocTknMined,
+			&i.UserID,
+			&i.UploadedAt,
+			&i.LastUpdatedAt,
+			&i.HashAlgo,
+			&i.ChunkSize,
+			&i.Phash,
+			&i.MimeType,
+			&i.FileSize,
+			&i.ScanStatus,
+			&i.ScanEngine,
+			&i.Tags,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.ExpiryNotifiedAt,
+			&i.DeletedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseDocExpiryNotice = `-- name: ReleaseDocExpiryNotice :exec
+UPDATE documents
+SET expiry_notified_at = NULL
+WHERE doc_id = $1
+`
+
+func (q *Queries) ReleaseDocExpiryNotice(ctx context.Context, docID string) error {
+	_, err := q.exec(ctx, q.releaseDocExpiryNoticeStmt, releaseDocExpiryNotice, docID)
+	return err
+}
+
+const searchDocsByTags = `-- name: SearchDocsByTags :many
+SELECT d.id, d.doc_id, d.title, d.description, d.file_name, d.doc_hash, d.doc_minted_id, d.doc_tkn_mined, d.user_id, d.uploaded_at, d.last_updated_at, d.hash_algo, d.chunk_size, d.phash, d.mime_type, d.file_size, d.scan_status, d.scan_engine, d.tags, d.valid_from, d.valid_until, d.expiry_notified_at, d.deleted_at, d.revoked_at
+FROM documents d
+         JOIN users u ON u.id = d.user_id
+WHERE u.email_id = $1
+  AND coalesce(d.tags, '{}'::JSONB) @> $2::JSONB
+ORDER BY d.uploaded_at DESC, d.id DESC
+LIMIT $3::INT
+`
+
+type SearchDocsByTagsParams struct {
+	OwnerEmail string          `json:"ownerEmail"`
+	Tags       json.RawMessage `json:"tags"`
+	MaxResults int32           `json:"maxResults"`
+}
+
+func (q *Queries) SearchDocsByTags(ctx context.Context, arg SearchDocsByTagsParams) ([]Document, error) {
+	rows, err := q.query(ctx, q.searchDocsByTagsStmt, searchDocsByTags, arg.OwnerEmail, arg.Tags, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.DocID,
+			&i.Title,
+			&i.Description,
+			&i.FileName,
+			&i.DocHash,
+			&i.DocMintedID,
+			&i.DocTknMined,
+			&i.UserID,
+			&i.UploadedAt,
+			&i.LastUpdatedAt,
+			&i.HashAlgo,
+			&i.ChunkSize,
+			&i.Phash,
+			&i.MimeType,
+			&i.FileSize,
+			&i.ScanStatus,
+			&i.ScanEngine,
+			&i.Tags,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.ExpiryNotifiedAt,
+			&i.DeletedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
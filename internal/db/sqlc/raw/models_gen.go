@@ -7,10 +7,191 @@ package raw
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+type DirectUploadStatus string
+
+const (
+	DirectUploadStatusCREATED    DirectUploadStatus = "CREATED"
+	DirectUploadStatusFINALISING DirectUploadStatus = "FINALISING"
+	DirectUploadStatusCOMPLETED  DirectUploadStatus = "COMPLETED"
+	DirectUploadStatusEXPIRED    DirectUploadStatus = "EXPIRED"
+)
+
+func (e *DirectUploadStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DirectUploadStatus(s)
+	case string:
+		*e = DirectUploadStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DirectUploadStatus: %T", src)
+	}
+	return nil
+}
+
+type NullDirectUploadStatus struct {
+	DirectUploadStatus DirectUploadStatus `json:"directUploadStatus"`
+	Valid              bool               `json:"valid"` // Valid is true if DirectUploadStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDirectUploadStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.DirectUploadStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DirectUploadStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDirectUploadStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DirectUploadStatus), nil
+}
+
+type DocClaimStatus string
+
+const (
+	DocClaimStatusPENDING  DocClaimStatus = "PENDING"
+	DocClaimStatusACCEPTED DocClaimStatus = "ACCEPTED"
+	DocClaimStatusREJECTED DocClaimStatus = "REJECTED"
 )
 
+func (e *DocClaimStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DocClaimStatus(s)
+	case string:
+		*e = DocClaimStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DocClaimStatus: %T", src)
+	}
+	return nil
+}
+
+type NullDocClaimStatus struct {
+	DocClaimStatus DocClaimStatus `json:"docClaimStatus"`
+	Valid          bool           `json:"valid"` // Valid is true if DocClaimStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDocClaimStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.DocClaimStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DocClaimStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDocClaimStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DocClaimStatus), nil
+}
+
+type TusUploadStatus string
+
+const (
+	TusUploadStatusCREATED    TusUploadStatus = "CREATED"
+	TusUploadStatusFINISHING  TusUploadStatus = "FINISHING"
+	TusUploadStatusCOMPLETED  TusUploadStatus = "COMPLETED"
+	TusUploadStatusTERMINATED TusUploadStatus = "TERMINATED"
+)
+
+func (e *TusUploadStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = TusUploadStatus(s)
+	case string:
+		*e = TusUploadStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for TusUploadStatus: %T", src)
+	}
+	return nil
+}
+
+type NullTusUploadStatus struct {
+	TusUploadStatus TusUploadStatus `json:"tusUploadStatus"`
+	Valid           bool            `json:"valid"` // Valid is true if TusUploadStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullTusUploadStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.TusUploadStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.TusUploadStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullTusUploadStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.TusUploadStatus), nil
+}
+
+type UploadSagaState string
+
+const (
+	UploadSagaStateRECEIVED   UploadSagaState = "RECEIVED"
+	UploadSagaStateSTORED     UploadSagaState = "STORED"
+	UploadSagaStateMINTED     UploadSagaState = "MINTED"
+	UploadSagaStateCONFIRMED  UploadSagaState = "CONFIRMED"
+	UploadSagaStateSAVED      UploadSagaState = "SAVED"
+	UploadSagaStateROLLEDBACK UploadSagaState = "ROLLED_BACK"
+	UploadSagaStateFAILED     UploadSagaState = "FAILED"
+)
+
+func (e *UploadSagaState) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UploadSagaState(s)
+	case string:
+		*e = UploadSagaState(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UploadSagaState: %T", src)
+	}
+	return nil
+}
+
+type NullUploadSagaState struct {
+	UploadSagaState UploadSagaState `json:"uploadSagaState"`
+	Valid           bool            `json:"valid"` // Valid is true if UploadSagaState is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUploadSagaState) Scan(value interface{}) error {
+	if value == nil {
+		ns.UploadSagaState, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UploadSagaState.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUploadSagaState) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UploadSagaState), nil
+}
+
 type UserType string
 
 const (
@@ -54,18 +235,105 @@ func (ns NullUserType) Value() (driver.Value, error) {
 	return string(ns.UserType), nil
 }
 
+type DirectUpload struct {
+	ID            int64                 `json:"id"`
+	UploadID      string                `json:"uploadId"`
+	UploadLength  int64                 `json:"uploadLength"`
+	Metadata      pqtype.NullRawMessage `json:"metadata"`
+	Status        DirectUploadStatus    `json:"status"`
+	DocID         sql.NullString        `json:"docId"`
+	ExpiresAt     time.Time             `json:"expiresAt"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	LastUpdatedAt time.Time             `json:"lastUpdatedAt"`
+}
+
+type DocClaim struct {
+	ID                int64          `json:"id"`
+	ClaimID           string         `json:"claimId"`
+	DocID             string         `json:"docId"`
+	ClaimantEmail     string         `json:"claimantEmail"`
+	FirstName         string         `json:"firstName"`
+	LastName          string         `json:"lastName"`
+	Status            DocClaimStatus `json:"status"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	LastUpdatedAt     time.Time      `json:"lastUpdatedAt"`
+	DecisionTokenHash string         `json:"decisionTokenHash"`
+}
+
 type Document struct {
-	ID            int64          `json:"id"`
-	DocID         string         `json:"docId"`
-	Title         string         `json:"title"`
-	Description   sql.NullString `json:"description"`
-	FileName      string         `json:"fileName"`
-	DocHash       string         `json:"docHash"`
-	DocMintedID   string         `json:"docMintedId"`
-	DocTknMined   bool           `json:"docTknMined"`
-	UserID        int64          `json:"userId"`
-	UploadedAt    time.Time      `json:"uploadedAt"`
-	LastUpdatedAt time.Time      `json:"lastUpdatedAt"`
+	ID               int64                 `json:"id"`
+	DocID            string                `json:"docId"`
+	Title            string                `json:"title"`
+	Description      sql.NullString        `json:"description"`
+	FileName         string                `json:"fileName"`
+	DocHash          string                `json:"docHash"`
+	DocMintedID      string                `json:"docMintedId"`
+	DocTknMined      bool                  `json:"docTknMined"`
+	UserID           int64                 `json:"userId"`
+	UploadedAt       time.Time             `json:"uploadedAt"`
+	LastUpdatedAt    time.Time             `json:"lastUpdatedAt"`
+	HashAlgo         string                `json:"hashAlgo"`
+	ChunkSize        sql.NullInt64         `json:"chunkSize"`
+	Phash            sql.NullInt64         `json:"phash"`
+	MimeType         string                `json:"mimeType"`
+	FileSize         int64                 `json:"fileSize"`
+	ScanStatus       sql.NullString        `json:"scanStatus"`
+	ScanEngine       sql.NullString        `json:"scanEngine"`
+	Tags             pqtype.NullRawMessage `json:"tags"`
+	ValidFrom        sql.NullTime          `json:"validFrom"`
+	ValidUntil       sql.NullTime          `json:"validUntil"`
+	ExpiryNotifiedAt sql.NullTime          `json:"expiryNotifiedAt"`
+	DeletedAt        sql.NullTime          `json:"deletedAt"`
+	RevokedAt        sql.NullTime          `json:"revokedAt"`
+}
+
+type DocumentChunk struct {
+	DocumentID int64  `json:"documentId"`
+	ChunkIndex int32  `json:"chunkIndex"`
+	ChunkHash  string `json:"chunkHash"`
+}
+
+type IdempotencyKey struct {
+	IdemKey         string                `json:"idemKey"`
+	Fingerprint     string                `json:"fingerprint"`
+	ResponseStatus  sql.NullInt32         `json:"responseStatus"`
+	ResponseHeaders pqtype.NullRawMessage `json:"responseHeaders"`
+	ResponseBody    []byte                `json:"responseBody"`
+	CreatedAt       time.Time             `json:"createdAt"`
+	ExpiresAt       time.Time             `json:"expiresAt"`
+	LockedUntil     time.Time             `json:"lockedUntil"`
+}
+
+type TusUpload struct {
+	ID            int64                 `json:"id"`
+	UploadID      string                `json:"uploadId"`
+	UploadLength  int64                 `json:"uploadLength"`
+	UploadOffset  int64                 `json:"uploadOffset"`
+	Metadata      pqtype.NullRawMessage `json:"metadata"`
+	Status        TusUploadStatus       `json:"status"`
+	DocID         sql.NullString        `json:"docId"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	LastUpdatedAt time.Time             `json:"lastUpdatedAt"`
+}
+
+type TusUploadPart struct {
+	UploadID   string `json:"uploadId"`
+	PartOffset int64  `json:"partOffset"`
+	PartSize   int64  `json:"partSize"`
+	ObjectName string `json:"objectName"`
+}
+
+type UploadSaga struct {
+	ID            int64           `json:"id"`
+	SagaID        string          `json:"sagaId"`
+	State         UploadSagaState `json:"state"`
+	DocMeta       json.RawMessage `json:"docMeta"`
+	DocID         sql.NullString  `json:"docId"`
+	BcTknID       sql.NullString  `json:"bcTknId"`
+	Attempts      int32           `json:"attempts"`
+	LastError     sql.NullString  `json:"lastError"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	LastUpdatedAt time.Time       `json:"lastUpdatedAt"`
 }
 
 type User struct {
@@ -77,3 +345,11 @@ type User struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	LastUpdatedAt time.Time `json:"lastUpdatedAt"`
 }
+
+type UserUsage struct {
+	OwnerEmail    string    `json:"ownerEmail"`
+	Docs          int64     `json:"docs"`
+	Bytes         int64     `json:"bytes"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastUpdatedAt time.Time `json:"lastUpdatedAt"`
+}
@@ -9,12 +9,50 @@ import (
 )
 
 type Querier interface {
+	AddDirectUpload(ctx context.Context, arg AddDirectUploadParams) (DirectUpload, error)
 	AddDoc(ctx context.Context, arg AddDocParams) (Document, error)
+	AddDocChunks(ctx context.Context, arg AddDocChunksParams) error
+	AddDocClaim(ctx context.Context, arg AddDocClaimParams) (DocClaim, error)
+	AddIdempotencyKey(ctx context.Context, arg AddIdempotencyKeyParams) (int64, error)
+	AddTusUpload(ctx context.Context, arg AddTusUploadParams) (TusUpload, error)
+	AddTusUploadPart(ctx context.Context, arg AddTusUploadPartParams) error
+	AddUploadSaga(ctx context.Context, arg AddUploadSagaParams) error
 	AddUser(ctx context.Context, arg AddUserParams) (User, error)
+	AdvanceTusUpload(ctx context.Context, arg AdvanceTusUploadParams) (TusUpload, error)
+	AdvanceUploadSaga(ctx context.Context, arg AdvanceUploadSagaParams) (int64, error)
+	ClaimDirectUpload(ctx context.Context, arg ClaimDirectUploadParams) (DirectUpload, error)
+	ClaimExpiredDirectUploads(ctx context.Context, arg ClaimExpiredDirectUploadsParams) ([]DirectUpload, error)
+	ClaimExpiringDocs(ctx context.Context, arg ClaimExpiringDocsParams) ([]Document, error)
+	ClaimStaleUploadSagas(ctx context.Context, arg ClaimStaleUploadSagasParams) ([]UploadSaga, error)
+	ClaimTusUpload(ctx context.Context, arg ClaimTusUploadParams) (TusUpload, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	DecideDocClaim(ctx context.Context, arg DecideDocClaimParams) (int64, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) error
+	DeleteIdempotencyKey(ctx context.Context, idemKey string) error
+	ExtendIdempotencyKey(ctx context.Context, arg ExtendIdempotencyKeyParams) error
+	FailUploadSagaStep(ctx context.Context, arg FailUploadSagaStepParams) error
+	GetDirectUpload(ctx context.Context, uploadID string) (DirectUpload, error)
 	GetDoc(ctx context.Context, docID string) (Document, error)
 	GetDocByHash(ctx context.Context, docHash string) (Document, error)
+	GetDocByMintedId(ctx context.Context, docMintedID string) (Document, error)
+	GetDocChunks(ctx context.Context, documentID int64) ([]string, error)
+	GetDocClaim(ctx context.Context, claimID string) (DocClaim, error)
+	GetIdempotencyKey(ctx context.Context, idemKey string) (IdempotencyKey, error)
+	GetSimilarDocs(ctx context.Context, arg GetSimilarDocsParams) ([]GetSimilarDocsRow, error)
+	GetTusUpload(ctx context.Context, uploadID string) (TusUpload, error)
+	GetTusUploadParts(ctx context.Context, uploadID string) ([]TusUploadPart, error)
+	GetUploadSaga(ctx context.Context, sagaID string) (UploadSaga, error)
 	GetUser(ctx context.Context, emailID string) (User, error)
 	GetUserById(ctx context.Context, id int64) (User, error)
+	GetUserUsage(ctx context.Context, ownerEmail string) (GetUserUsageRow, error)
+	InitUserUsage(ctx context.Context, ownerEmail string) error
+	ListUserDocs(ctx context.Context, arg ListUserDocsParams) ([]Document, error)
+	ReleaseDocExpiryNotice(ctx context.Context, docID string) error
+	ReleaseUploadSagaUsage(ctx context.Context, sagaID string) error
+	ReserveUserUsage(ctx context.Context, arg ReserveUserUsageParams) (int64, error)
+	SearchDocsByTags(ctx context.Context, arg SearchDocsByTagsParams) ([]Document, error)
+	UpdateDirectUploadStatus(ctx context.Context, arg UpdateDirectUploadStatusParams) error
+	UpdateTusUploadStatus(ctx context.Context, arg UpdateTusUploadStatusParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -85,3 +85,61 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (User, error) {
 	)
 	return i, err
 }
+
+const getUserUsage = `-- name: GetUserUsage :one
+SELECT coalesce(sum(docs), 0)::BIGINT AS doc_count, coalesce(sum(bytes), 0)::BIGINT AS doc_bytes
+FROM user_usage
+WHERE owner_email = $1
+`
+
+type GetUserUsageRow struct {
+	DocCount int64 `json:"docCount"`
+	DocBytes int64 `json:"docBytes"`
+}
+
+func (q *Queries) GetUserUsage(ctx context.Context, ownerEmail string) (GetUserUsageRow, error) {
+	row := q.queryRow(ctx, q.getUserUsageStmt, getUserUsage, ownerEmail)
+	var i GetUserUsageRow
+	err := row.Scan(&i.DocCount, &i.DocBytes)
+	return i, err
+}
+
+const initUserUsage = `-- name: InitUserUsage :exec
+INSERT INTO user_usage (owner_email)
+VALUES ($1)
+ON CONFLICT (owner_email) DO NOTHING
+`
+
+func (q *Queries) InitUserUsage(ctx context.Context, ownerEmail string) error {
+	_, err := q.exec(ctx, q.initUserUsageStmt, initUserUsage, ownerEmail)
+	return err
+}
+
+const reserveUserUsage = `-- name: ReserveUserUsage :execrows
+UPDATE user_usage
+SET docs  = docs + 1,
+    bytes = bytes + $1::BIGINT
+WHERE owner_email = $2
+  AND ($3::BIGINT = 0 OR docs + 1 <= $3::BIGINT)
+  AND ($4::BIGINT = 0 OR bytes + $1::BIGINT <= $4::BIGINT)
+`
+
+type ReserveUserUsageParams struct {
+	Size       int64  `json:"size"`
+	OwnerEmail string `json:"ownerEmail"`
+	MaxDocs    int64  `json:"maxDocs"`
+	MaxBytes   int64  `json:"maxBytes"`
+}
+
+func (q *Queries) ReserveUserUsage(ctx context.Context, arg ReserveUserUsageParams) (int64, error) {
+	result, err := q.exec(ctx, q.reserveUserUsageStmt, reserveUserUsage,
+		arg.Size,
+		arg.OwnerEmail,
+		arg.MaxDocs,
+		arg.MaxBytes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	// register the decoders of the image formats which can be perceptually hashed
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+)
+
+const (
+	// dHashWidth is one more than the bits per row, as each bit compares two neighbouring cells
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// ErrImageTooLarge is returned for images with more pixels than DHash decodes
+var ErrImageTooLarge = errors.New("image too large")
+
+// DHash is a perceptual difference hash of an image. Unlike content hashes, re-encoded,
+// resized or slightly altered copies of an image hash to values within a small hamming distance.
+type DHash struct {
+	// MaxPixels is the largest width x height of an image which is decoded, 0 is unlimited.
+	// A small compressed image can decode to far more memory than its size.
+	MaxPixels int64
+}
+
+var _ Hasher = (*DHash)(nil)
+
+// Hash decodes the image and returns its 64-bit difference hash as 16 hex chars
+func (d DHash) Hash(ctx context.Context, in io.Reader) (string, error) {
+	logger := log.GetLogger(ctx)
+
+	// the dimensions are read from the header before the pixels are decoded, the header is kept to decode from
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(in, &head))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image config - %w", err)
+	}
+	if d.MaxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > d.MaxPixels {
+		return "", fmt.Errorf("%w - %dx%d is over %d pixels", ErrImageTooLarge, cfg.Width, cfg.Height, d.MaxPixels)
+	}
+	img, format, err := image.Decode(io.MultiReader(&head, in))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image - %w", err)
+	}
+	out := fmt.Sprintf("%016x", dHash(img))
+	logger.Info("perceptual hash generated", zap.String("imgFormat", format), zap.String("hash", out))
+	return out, nil
+}
+
+// HammingDistance returns the number of differing bits between two DHash hashes
+func HammingDistance(a, b string) (int, error) {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash %q - %w", a, err)
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash %q - %w", b, err)
+	}
+	return bits.OnesCount64(x ^ y), nil
+}
+
+// dHash shrinks the image to a 9x8 grayscale grid and sets a bit for every cell
+// which is brighter than its right neighbour.
+func dHash(img image.Image) uint64 {
+	grid := shrinkGray(img, dHashWidth, dHashHeight)
+	var out uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			out <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				out |= 1
+			}
+		}
+	}
+	return out
+}
+
+// shrinkGray averages the luminance of every source pixel falling into each cell of a w x h grid
+func shrinkGray(img image.Image, w, h int) [][]float64 {
+	b := img.Bounds()
+	sum := make([][]float64, h)
+	cnt := make([][]float64, h)
+	for i := range sum {
+		sum[i] = make([]float64, w)
+		cnt[i] = make([]float64, w)
+	}
+	dx, dy := b.Dx(), b.Dy()
+	if dx == 0 || dy == 0 {
+		return sum
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * h / dy
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * w / dx
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			cnt[cy][cx]++
+		}
+	}
+	for y := range sum {
+		for x := range sum[y] {
+			if cnt[y][x] > 0 {
+				sum[y][x] /= cnt[y][x]
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,81 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// page draws a fake scanned page with a few dark text blocks on a light background
+func page(w, h int, invert bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(230 - 200*x/w)
+			if (y*10/h)%3 == 1 && (x*7/w)%2 == 0 {
+				v = 20
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestDHash_Hash(t *testing.T) {
+	ctx := context.Background()
+
+	var orig, resized, reencoded, inverted bytes.Buffer
+	require.NoError(t, png.Encode(&orig, page(400, 560, false)))
+	require.NoError(t, png.Encode(&resized, page(200, 280, false)))
+	require.NoError(t, jpeg.Encode(&reencoded, page(400, 560, false), &jpeg.Options{Quality: 40}))
+	require.NoError(t, png.Encode(&inverted, page(400, 560, true)))
+
+	hash := func(b *bytes.Buffer) string {
+		h, err := DHash{}.Hash(ctx, b)
+		require.NoError(t, err)
+		assert.Len(t, h, 16)
+		return h
+	}
+	origH, resizedH, reencodedH, invertedH := hash(&orig), hash(&resized), hash(&reencoded), hash(&inverted)
+
+	dist := func(a, b string) int {
+		d, err := HammingDistance(a, b)
+		require.NoError(t, err)
+		return d
+	}
+	assert.LessOrEqual(t, dist(origH, resizedH), 5, "resized copy")
+	assert.LessOrEqual(t, dist(origH, reencodedH), 5, "re-encoded copy")
+	assert.Greater(t, dist(origH, invertedH), 20, "different image")
+
+	_, err := DHash{}.Hash(ctx, strings.NewReader("not an image"))
+	assert.Error(t, err)
+
+	// a flat image compresses to a small file whatever its dimensions
+	var big bytes.Buffer
+	require.NoError(t, png.Encode(&big, image.NewGray(image.Rect(0, 0, 4000, 3000))))
+	_, err = DHash{MaxPixels: 4000*3000 - 1}.Hash(ctx, bytes.NewReader(big.Bytes()))
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	h, err := DHash{MaxPixels: 4000 * 3000}.Hash(ctx, bytes.NewReader(big.Bytes()))
+	require.NoError(t, err)
+	assert.Len(t, h, 16)
+}
+
+func TestHammingDistance(t *testing.T) {
+	d, err := HammingDistance("00000000000000ff", "000000000000000f")
+	require.NoError(t, err)
+	assert.Equal(t, 4, d)
+
+	_, err = HammingDistance("zz", "00")
+	assert.Error(t, err)
+}
@@ -5,6 +5,22 @@ import (
 	"io"
 )
 
+const (
+	// AlgoMd5 identifies documents hashed as a single md5 digest
+	AlgoMd5 = "md5"
+
+	// AlgoMerkleSha256 identifies documents hashed as a chunked sha256 merkle tree
+	AlgoMerkleSha256 = "merkle-sha256"
+)
+
 type Hasher interface {
 	Hash(ctx context.Context, data io.Reader) (string, error)
 }
+
+// TreeHasher is a Hasher which also exposes the per-chunk hashes it used to derive the root hash
+type TreeHasher interface {
+	Hasher
+
+	// Tree hashes data and returns the whole merkle tree, whose Root is the same value Hash returns
+	Tree(ctx context.Context, data io.Reader) (Tree, error)
+}
@@ -0,0 +1,290 @@
+package hash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+)
+
+// DefaultChunkSize is the chunk size used by Merkle when none is configured
+const DefaultChunkSize int64 = 4 << 20 // 4 MiB
+
+const (
+	// leafPrefix and nodePrefix domain separate leaf and interior node hashes (RFC 6962)
+	leafPrefix byte = 0x00
+	nodePrefix byte = 0x01
+)
+
+var (
+	// ErrRangeMismatch is returned when a range of content doesn't match the recorded chunk hashes
+	ErrRangeMismatch = errors.New("content range does not match recorded chunk hashes")
+
+	// ErrTreeMismatch is returned when the recorded chunk hashes don't fold into the recorded root
+	ErrTreeMismatch = errors.New("chunk hashes do not match merkle root")
+)
+
+// Merkle is a Hasher meant for very large files. Content is split into fixed size chunks which
+// are sha256 hashed in parallel, and the chunk hashes are folded pairwise into a single root.
+// The root is the document hash, the chunk hashes allow a range of the file to be proven
+// intact later on without rehashing the whole file.
+type Merkle struct {
+	// ChunkSize is the number of bytes per leaf. DefaultChunkSize is used when not set.
+	ChunkSize int64
+
+	// Workers caps the number of chunks hashed at once. runtime.NumCPU is used when not set.
+	Workers int
+}
+
+var _ TreeHasher = (*Merkle)(nil)
+
+// Tree is a merkle tree built over the chunks of a document
+type Tree struct {
+	ChunkSize int64    `json:"chunkSize"`
+	Size      int64    `json:"size"`
+	Root      string   `json:"root"`
+	Leaves    []string `json:"leaves"`
+}
+
+// ProofStep is a sibling hash on the path from a leaf to the root
+type ProofStep struct {
+	Hash string `json:"hash"`
+	// Left is true when the sibling sits to the left of the running hash
+	Left bool `json:"left"`
+}
+
+// Hash returns the merkle root of the content
+func (m Merkle) Hash(ctx context.Context, in io.Reader) (string, error) {
+	t, err := m.Tree(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	return t.Root, nil
+}
+
+// Tree reads the content chunk by chunk and hashes the chunks on up to Workers goroutines.
+// At most Workers+1 chunks are held in memory at any time.
+func (m Merkle) Tree(ctx context.Context, in io.Reader) (Tree, error) {
+	logger := log.GetLogger(ctx)
+	chunkSize, workers := m.chunkSize(), m.workers()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		leaves []string
+		size   int64
+		sem    = make(chan struct{}, workers)
+		pool   = sync.Pool{New: func() any {
+			b := make([]byte, chunkSize)
+			return &b
+		}}
+	)
+
+	for idx := 0; ; idx++ {
+		if err := ctx.Err(); err != nil {
+			wg.Wait()
+			return Tree{}, err
+		}
+		sem <- struct{}{}
+		buf := pool.Get().(*[]byte)
+		n, err := io.ReadFull(in, *buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			<-sem
+			wg.Wait()
+			return Tree{}, fmt.Errorf("failed to read chunk %d - %w", idx, err)
+		}
+		// an empty document still gets a single (empty) leaf
+		if n == 0 && idx > 0 {
+			pool.Put(buf)
+			<-sem
+			break
+		}
+		size += int64(n)
+		mu.Lock()
+		leaves = append(leaves, "")
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(idx, n int, buf *[]byte) {
+			defer wg.Done()
+			h := leafHash((*buf)[:n])
+			pool.Put(buf)
+			mu.Lock()
+			leaves[idx] = h
+			mu.Unlock()
+			<-sem
+		}(idx, n, buf)
+
+		if int64(n) < chunkSize {
+			break
+		}
+	}
+	wg.Wait()
+
+	t, err := NewTree(chunkSize, leaves)
+	if err != nil {
+		return Tree{}, err
+	}
+	t.Size = size
+	logger.Info("merkle hash generated", zap.Int64("bytesHashed", size),
+		zap.Int("chunks", len(leaves)), zap.String("hash", t.Root))
+	return t, nil
+}
+
+func (m Merkle) chunkSize() int64 {
+	if m.ChunkSize <= 0 {
+		return DefaultChunkSize
+	}
+	return m.ChunkSize
+}
+
+func (m Merkle) workers() int {
+	if m.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return m.Workers
+}
+
+// NewTree rebuilds a tree from previously recorded chunk hashes
+func NewTree(chunkSize int64, leaves []string) (Tree, error) {
+	if len(leaves) == 0 {
+		return Tree{}, errors.New("merkle tree needs at least one leaf")
+	}
+	level := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		b, err := hex.DecodeString(l)
+		if err != nil {
+			return Tree{}, fmt.Errorf("invalid chunk hash at %d - %w", i, err)
+		}
+		level[i] = b
+	}
+	for len(level) > 1 {
+		level = foldLevel(level)
+	}
+	return Tree{
+		ChunkSize: chunkSize,
+		Root:      hex.EncodeToString(level[0]),
+		Leaves:    leaves,
+	}, nil
+}
+
+// Proof returns the sibling hashes needed to fold the leaf at idx up to the root
+func (t Tree) Proof(idx int) ([]ProofStep, error) {
+	if idx < 0 || idx >= len(t.Leaves) {
+		return nil, fmt.Errorf("chunk %d out of range, tree has %d chunks", idx, len(t.Leaves))
+	}
+	level := make([][]byte, len(t.Leaves))
+	for i, l := range t.Leaves {
+		b, err := hex.DecodeString(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk hash at %d - %w", i, err)
+		}
+		level[i] = b
+	}
+	var proof []ProofStep
+	for len(level) > 1 {
+		sibling := idx ^ 1
+		// the last node of an odd level is carried up as is, so it has no sibling
+		if sibling < len(level) {
+			proof = append(proof, ProofStep{Hash: hex.EncodeToString(level[sibling]), Left: sibling < idx})
+		}
+		level = foldLevel(level)
+		idx /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof checks that a chunk hash folds up to root using the given proof
+func VerifyProof(leaf string, proof []ProofStep, root string) bool {
+	cur, err := hex.DecodeString(leaf)
+	if err != nil {
+		return false
+	}
+	for _, p := range proof {
+		sib, err := hex.DecodeString(p.Hash)
+		if err != nil {
+			return false
+		}
+		if p.Left {
+			cur = nodeHash(sib, cur)
+		} else {
+			cur = nodeHash(cur, sib)
+		}
+	}
+	return hex.EncodeToString(cur) == root
+}
+
+// VerifyRange proves that data, which is a part of the document starting at offset, is intact.
+// offset must be on a chunk boundary and data must end either on a chunk boundary or at the
+// end of the document. Only the chunks covered by data are hashed.
+func (t Tree) VerifyRange(ctx context.Context, data io.Reader, offset int64) error {
+	if t.ChunkSize <= 0 || offset < 0 || offset%t.ChunkSize != 0 {
+		return fmt.Errorf("offset %d is not aligned to chunk size %d", offset, t.ChunkSize)
+	}
+	rebuilt, err := NewTree(t.ChunkSize, t.Leaves)
+	if err != nil {
+		return err
+	}
+	if rebuilt.Root != t.Root {
+		return ErrTreeMismatch
+	}
+
+	buf := make([]byte, t.ChunkSize)
+	for idx := int(offset / t.ChunkSize); ; idx++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(data, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read chunk %d - %w", idx, err)
+		}
+		if n == 0 {
+			return nil
+		}
+		if idx >= len(t.Leaves) || leafHash(buf[:n]) != t.Leaves[idx] {
+			return fmt.Errorf("chunk %d - %w", idx, ErrRangeMismatch)
+		}
+		// a short chunk is only valid as the last chunk of the document
+		if int64(n) < t.ChunkSize {
+			if idx != len(t.Leaves)-1 {
+				return fmt.Errorf("chunk %d is truncated - %w", idx, ErrRangeMismatch)
+			}
+			return nil
+		}
+	}
+}
+
+func foldLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, nodeHash(level[i], level[i+1]))
+	}
+	return next
+}
+
+func leafHash(chunk []byte) string {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(chunk)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -0,0 +1,109 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkle_Tree(t *testing.T) {
+	ctx := context.Background()
+	content := strings.Repeat("0123456789", 10) // 100 bytes, 7 chunks of 16
+
+	serial, err := Merkle{ChunkSize: 16, Workers: 1}.Tree(ctx, strings.NewReader(content))
+	require.NoError(t, err)
+	parallel, err := Merkle{ChunkSize: 16, Workers: 8}.Tree(ctx, strings.NewReader(content))
+	require.NoError(t, err)
+
+	assert.Equal(t, serial, parallel, "worker count must not change the tree")
+	assert.Len(t, serial.Leaves, 7)
+	assert.Equal(t, int64(100), serial.Size)
+	assert.Equal(t, leafHash([]byte(content[96:])), serial.Leaves[6])
+
+	root, err := Merkle{ChunkSize: 16}.Hash(ctx, strings.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, serial.Root, root)
+}
+
+func TestMerkle_Tree_small(t *testing.T) {
+	ctx := context.Background()
+
+	// a single chunk is the leaf hash itself
+	got, err := Merkle{ChunkSize: 16}.Tree(ctx, strings.NewReader("hello world"))
+	require.NoError(t, err)
+	sum := sha256.Sum256(append([]byte{leafPrefix}, "hello world"...))
+	assert.Equal(t, hex.EncodeToString(sum[:]), got.Root)
+
+	// empty content still has a root
+	got, err = Merkle{ChunkSize: 16}.Tree(ctx, strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Len(t, got.Leaves, 1)
+	assert.Equal(t, int64(0), got.Size)
+
+	// exact multiple of the chunk size doesn't add an empty trailing leaf
+	got, err = Merkle{ChunkSize: 4}.Tree(ctx, strings.NewReader("abcdefgh"))
+	require.NoError(t, err)
+	assert.Len(t, got.Leaves, 2)
+}
+
+func TestTree_Proof(t *testing.T) {
+	tree, err := Merkle{ChunkSize: 8}.Tree(context.Background(), strings.NewReader(strings.Repeat("x", 83)))
+	require.NoError(t, err)
+
+	for i, leaf := range tree.Leaves {
+		proof, err := tree.Proof(i)
+		require.NoError(t, err)
+		assert.True(t, VerifyProof(leaf, proof, tree.Root), "proof for chunk %d", i)
+		assert.False(t, VerifyProof(leafHash([]byte("tampered")), proof, tree.Root))
+	}
+
+	_, err = tree.Proof(len(tree.Leaves))
+	assert.Error(t, err)
+}
+
+func TestTree_VerifyRange(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(strings.Repeat("abcdefghij", 5)) // 50 bytes, chunks of 8
+	tree, err := Merkle{ChunkSize: 8}.Tree(ctx, bytes.NewReader(content))
+	require.NoError(t, err)
+
+	stored, err := NewTree(8, tree.Leaves)
+	require.NoError(t, err)
+	assert.Equal(t, tree.Root, stored.Root)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		offset  int64
+		wantErr error
+	}{
+		{name: "middle chunks", data: content[8:24], offset: 8},
+		{name: "tail", data: content[40:], offset: 40},
+		{name: "tampered", data: []byte("ABCDEFGH"), offset: 8, wantErr: ErrRangeMismatch},
+		{name: "truncated chunk", data: content[8:12], offset: 8, wantErr: ErrRangeMismatch},
+		{name: "beyond end", data: content[40:], offset: 48, wantErr: ErrRangeMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := stored.VerifyRange(ctx, bytes.NewReader(tt.data), tt.offset)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+
+	assert.Error(t, stored.VerifyRange(ctx, bytes.NewReader(content), 3), "unaligned offset")
+
+	forged := stored
+	forged.Leaves = append([]string{leafHash([]byte("forged"))}, stored.Leaves[1:]...)
+	assert.ErrorIs(t, forged.VerifyRange(ctx, bytes.NewReader(content[8:16]), 8), ErrTreeMismatch)
+}
@@ -94,6 +94,14 @@ func Start(ctx context.Context, logger *zap.Logger, port string) {
 	v, _ := concreteImpls[httpSrvrImplKey].(ServeConf)
 	router := v.CreateServer(ctx)
 
+	// finish or roll back uploads interrupted by a failure or a crash, clean up expired direct uploads
+	// and announce docs about to expire, until shutdown
+	if v.DocH != nil {
+		go v.DocH.RecoverUploads(ctx)
+		go v.DocH.CleanupDirectUploads(ctx)
+		go v.DocH.NotifyExpiring(ctx)
+	}
+
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
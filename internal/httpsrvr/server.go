@@ -17,7 +17,7 @@ func (s ServeConf) CreateServer(c context.Context) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	// use this to limit file upload sizes
+	// limits the memory used by form files of verify requests, upload sizes are limited by the upload policy
 	router.MaxMultipartMemory = 8 << 20 // 8 MiB
 
 	router.Use(gin.Recovery())
@@ -56,6 +56,9 @@ func (s ServeConf) addBusinessEndpointsMiddlewares(router *gin.RouterGroup) {
 }
 
 func (s ServeConf) addBusinessEndpoints(c context.Context, router *gin.Engine) {
+	// keys verification receipts are signed with, for third parties to validate them offline
+	router.GET("/.well-known/jwks.json", s.DocH.Jwks)
+
 	svcRtr := router.Group("/svc")
 
 	// add all the middlewares
@@ -66,7 +69,41 @@ func (s ServeConf) addBusinessEndpoints(c context.Context, router *gin.Engine) {
 	// create a group for all endpoints which contains business logic and
 	docV1Rtr := intVerRtr.Group("/doc")
 
+	docV1Rtr.GET("", s.DocH.ListDocs)
 	docV1Rtr.POST("/upload", s.DocH.Upload)
+	docV1Rtr.POST("/bulk", s.DocH.BulkUpload)
 	docV1Rtr.GET("/download/:docId", s.DocH.Download)
+	docV1Rtr.POST("/download/:docId/url", s.DocH.PresignDownload)
 	docV1Rtr.POST("/verify", s.DocH.Verify)
+	docV1Rtr.POST("/verify/hash", s.DocH.VerifyHash)
+	docV1Rtr.POST("/verify/batch", s.DocH.VerifyBatch)
+	docV1Rtr.POST("/receipts/validate", s.DocH.ValidateReceipt)
+	docV1Rtr.GET("/jobs/:jobId", s.DocH.Job)
+	docV1Rtr.GET("/claims/:claimId", s.DocH.Claim)
+	docV1Rtr.PUT("/claims/:claimId", s.DocH.DecideClaim)
+	docV1Rtr.GET("/by-token/:tokenId", s.DocH.GetDocByTkn)
+	docV1Rtr.GET("/:docId", s.DocH.GetDoc)
+
+	// resumable uploads using the tus 1.0 protocol
+	tusV1Rtr := docV1Rtr.Group("/uploads")
+	tusV1Rtr.OPTIONS("", s.DocH.TusOptions)
+	tusV1Rtr.POST("", s.DocH.TusCreate)
+	tusV1Rtr.HEAD("/:uploadId", s.DocH.TusHead)
+	tusV1Rtr.PATCH("/:uploadId", s.DocH.TusPatch)
+	tusV1Rtr.DELETE("/:uploadId", s.DocH.TusDelete)
+
+	// direct-to-blob uploads, put in blob store through a presigned url
+	directV1Rtr := docV1Rtr.Group("/direct")
+	directV1Rtr.POST("", s.DocH.DirectUpload)
+	directV1Rtr.POST("/:uploadId/finalise", s.DocH.FinaliseDirectUpload)
+
+	// public verification pages of documents, which their qr codes link to
+	pageRtr := router.Group("/v")
+	s.addBusinessEndpointsMiddlewares(pageRtr)
+	pageRtr.GET("/:docId", s.DocH.VerifyPage)
+	pageRtr.GET("/:docId/qr", s.DocH.VerifyPageQr)
+
+	usrV1Rtr := intVerRtr.Group("/users")
+	usrV1Rtr.GET("/:email/usage", s.DocH.Usage)
+	usrV1Rtr.GET("/:email/docs", s.DocH.Search)
 }
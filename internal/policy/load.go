@@ -0,0 +1,86 @@
+// Package policy holds the upload policy, which decides the documents accepted for upload
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vposham/trustdoc/config"
+)
+
+var (
+	onceInit      = new(sync.Once)
+	concreteImpls = make(map[string]any)
+)
+
+const (
+	// policyKey is the upload policy loaded from configuration
+	policyKey = "UploadPolicyKey"
+)
+
+// Load enables us inject this package as dependency from its parent
+func Load(ctx context.Context) error {
+	var appErr error
+	onceInit.Do(func() {
+		appErr = loadImpls(ctx)
+	})
+	return appErr
+}
+
+func loadImpls(_ context.Context) error {
+	props := config.GetAll()
+	if concreteImpls[policyKey] == nil {
+		bySize, err := parseSizes(props.GetString("upload.policy.max.size.by.type", ""))
+		if err != nil {
+			return fmt.Errorf("invalid upload.policy.max.size.by.type - %w", err)
+		}
+		concreteImpls[policyKey] = &Policy{
+			Allow:         parseList(props.GetString("upload.policy.mime.allow", "")),
+			Deny:          parseList(props.GetString("upload.policy.mime.deny", "")),
+			MaxSize:       props.MustGetInt64("upload.policy.max.size"),
+			MaxSizeByType: bySize,
+			OwnerMaxDocs:  props.MustGetInt64("upload.policy.owner.max.docs"),
+			OwnerMaxBytes: props.MustGetInt64("upload.policy.owner.max.bytes"),
+		}
+	}
+	return nil
+}
+
+// GetPolicy is used to get the upload policy
+func GetPolicy() *Policy {
+	return concreteImpls[policyKey].(*Policy)
+}
+
+// parseList parses a comma separated list of mime types
+func parseList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = baseType(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseSizes parses a comma separated list of mime type=max size in bytes pairs
+func parseSizes(s string) (map[string]int64, error) {
+	out := make(map[string]int64)
+	for _, v := range strings.Split(s, ",") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		t, size, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not a type=size pair", v)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size for %s - %w", t, err)
+		}
+		out[baseType(t)] = n
+	}
+	return out, nil
+}
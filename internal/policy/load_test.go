@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vposham/trustdoc/config"
+)
+
+func TestMain(m *testing.M) {
+	ce := os.Getenv("appEnv")
+	defer func() {
+		_ = os.Setenv("appEnv", ce)
+	}()
+	_ = os.Setenv("appEnv", "test")
+	ctx := context.Background()
+	_ = config.Load(ctx, "../../config")
+	_ = Load(ctx)
+
+	os.Exit(m.Run())
+}
+
+func TestGetPolicy(t *testing.T) {
+	p := GetPolicy()
+	require.NotNil(t, p)
+	assert.Contains(t, p.Deny, "application/x-mach-binary")
+	assert.Error(t, p.CheckType("application/x-executable"))
+}
+
+func Test_parseSizes(t *testing.T) {
+	got, err := parseSizes("image/*=10, Application/PDF = 20,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"image/*": 10, "application/pdf": 20}, got)
+
+	_, err = parseSizes("image/*")
+	assert.Error(t, err)
+	_, err = parseSizes("image/*=big")
+	assert.Error(t, err)
+}
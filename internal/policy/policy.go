@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// violation codes returned to clients
+const (
+	CodeTypeNotAllowed     = "typeNotAllowed"
+	CodeTooLarge           = "tooLarge"
+	CodeDocQuotaExceeded   = "docQuotaExceeded"
+	CodeBytesQuotaExceeded = "bytesQuotaExceeded"
+)
+
+// Policy decides which documents are accepted for upload. Mime type patterns are either
+// a full type like application/pdf or a wildcard like image/*. Zero limits are unlimited.
+type Policy struct {
+	// Allow lists the accepted mime types, all types are accepted when it is empty
+	Allow []string
+	// Deny lists the rejected mime types, it wins over Allow
+	Deny []string
+
+	// MaxSize is the largest document accepted
+	MaxSize int64
+	// MaxSizeByType overrides MaxSize for the matching mime types, a full type wins over a wildcard
+	MaxSizeByType map[string]int64
+
+	// OwnerMaxDocs and OwnerMaxBytes are the per-owner quotas on uploaded documents
+	OwnerMaxDocs  int64
+	OwnerMaxBytes int64
+}
+
+// Violation is a document rejected by the upload policy
+type Violation struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	MimeType string `json:"mimeType,omitempty"`
+	Limit    int64  `json:"limit,omitempty"`
+	Actual   int64  `json:"actual,omitempty"`
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// Status is the http status a violation is reported with
+func (v *Violation) Status() int {
+	switch v.Code {
+	case CodeTypeNotAllowed:
+		return http.StatusUnsupportedMediaType
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusTooManyRequests
+	}
+}
+
+// CheckType rejects mime types which are denied or not allowed
+func (p *Policy) CheckType(mimeType string) error {
+	t := baseType(mimeType)
+	if matches(p.Deny, t) || (len(p.Allow) > 0 && !matches(p.Allow, t)) {
+		return &Violation{
+			Code:     CodeTypeNotAllowed,
+			Message:  fmt.Sprintf("documents of type %s are not accepted", t),
+			MimeType: t,
+		}
+	}
+	return nil
+}
+
+// MaxSizeFor returns the largest document accepted for a mime type, 0 when unlimited.
+// An empty mime type gets the largest size accepted for any type.
+func (p *Policy) MaxSizeFor(mimeType string) int64 {
+	t := baseType(mimeType)
+	if t == "" {
+		return p.maxSizeAny()
+	}
+	if m, ok := p.MaxSizeByType[t]; ok {
+		return m
+	}
+	if major, _, ok := strings.Cut(t, "/"); ok {
+		if m, ok := p.MaxSizeByType[major+"/*"]; ok {
+			return m
+		}
+	}
+	return p.MaxSize
+}
+
+// CheckSize rejects documents larger than accepted for their mime type
+func (p *Policy) CheckSize(mimeType string, size int64) error {
+	if m := p.MaxSizeFor(mimeType); m > 0 && size > m {
+		return &Violation{
+			Code:     CodeTooLarge,
+			Message:  fmt.Sprintf("document of %d bytes exceeds the max size of %d bytes", size, m),
+			MimeType: baseType(mimeType),
+			Limit:    m,
+			Actual:   size,
+		}
+	}
+	return nil
+}
+
+// CheckQuota rejects a document of size bytes when it takes an owner, who already uploaded docs
+// documents of total bytes, past their quota
+func (p *Policy) CheckQuota(docs, bytes, size int64) error {
+	if p.OwnerMaxDocs > 0 && docs+1 > p.OwnerMaxDocs {
+		return &Violation{
+			Code:    CodeDocQuotaExceeded,
+			Message: fmt.Sprintf("owner already uploaded the max of %d documents", p.OwnerMaxDocs),
+			Limit:   p.OwnerMaxDocs,
+			Actual:  docs,
+		}
+	}
+	if p.OwnerMaxBytes > 0 && bytes+size > p.OwnerMaxBytes {
+		return &Violation{
+			Code:    CodeBytesQuotaExceeded,
+			Message: fmt.Sprintf("document takes owner past the max of %d bytes uploaded", p.OwnerMaxBytes),
+			Limit:   p.OwnerMaxBytes,
+			Actual:  bytes + size,
+		}
+	}
+	return nil
+}
+
+// maxSizeAny is the largest size accepted for any mime type, 0 when unlimited
+func (p *Policy) maxSizeAny() int64 {
+	m := p.MaxSize
+	for _, s := range p.MaxSizeByType {
+		if m == 0 || s == 0 {
+			return 0
+		}
+		m = max(m, s)
+	}
+	return m
+}
+
+// baseType strips the parameters, like charset, off a mime type
+func baseType(mimeType string) string {
+	t, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+func matches(patterns []string, t string) bool {
+	major, _, _ := strings.Cut(t, "/")
+	for _, p := range patterns {
+		if p == t || p == "*/*" || p == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
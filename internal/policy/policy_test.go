@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		Allow:         []string{"application/pdf", "image/*", "text/plain"},
+		Deny:          []string{"image/svg+xml"},
+		MaxSize:       100,
+		MaxSizeByType: map[string]int64{"image/*": 1000, "image/gif": 10},
+		OwnerMaxDocs:  3,
+		OwnerMaxBytes: 500,
+	}
+}
+
+func violationCode(err error) string {
+	var v *Violation
+	if errors.As(err, &v) {
+		return v.Code
+	}
+	return ""
+}
+
+func TestPolicy_CheckType(t *testing.T) {
+	p := testPolicy()
+	tests := []struct {
+		mimeType string
+		wantCode string
+	}{
+		{mimeType: "application/pdf"},
+		{mimeType: "image/png"},
+		{mimeType: "text/plain; charset=utf-8"},
+		{mimeType: "image/svg+xml", wantCode: CodeTypeNotAllowed},
+		{mimeType: "application/zip", wantCode: CodeTypeNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, violationCode(p.CheckType(tt.mimeType)))
+		})
+	}
+
+	assert.NoError(t, (&Policy{}).CheckType("application/zip"), "empty allow list allows all")
+}
+
+func TestPolicy_CheckSize(t *testing.T) {
+	p := testPolicy()
+	assert.Equal(t, int64(100), p.MaxSizeFor("application/pdf"))
+	assert.Equal(t, int64(1000), p.MaxSizeFor("image/png"))
+	assert.Equal(t, int64(10), p.MaxSizeFor("image/gif"))
+	assert.Equal(t, int64(1000), p.MaxSizeFor(""))
+
+	assert.NoError(t, p.CheckSize("application/pdf", 100))
+	assert.NoError(t, p.CheckSize("image/png", 1000))
+	err := p.CheckSize("image/gif", 11)
+	assert.Equal(t, CodeTooLarge, violationCode(err))
+	var v *Violation
+	assert.ErrorAs(t, err, &v)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, v.Status())
+	assert.Equal(t, int64(10), v.Limit)
+
+	assert.NoError(t, (&Policy{}).CheckSize("image/png", 1<<40), "zero max size is unlimited")
+}
+
+func TestPolicy_CheckQuota(t *testing.T) {
+	p := testPolicy()
+	assert.NoError(t, p.CheckQuota(2, 400, 100))
+	assert.Equal(t, CodeDocQuotaExceeded, violationCode(p.CheckQuota(3, 0, 1)))
+	assert.Equal(t, CodeBytesQuotaExceeded, violationCode(p.CheckQuota(1, 400, 101)))
+
+	var v *Violation
+	assert.ErrorAs(t, p.CheckQuota(3, 0, 1), &v)
+	assert.Equal(t, http.StatusTooManyRequests, v.Status())
+}
@@ -0,0 +1,468 @@
+// Package qr encodes short texts, such as urls, as QR codes (ISO/IEC 18004) and renders them as PNG or SVG.
+// Texts are encoded in byte mode at error correction level M, in the smallest of versions 1 to 10.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong is returned for texts longer than a version 10 code holds
+var ErrTooLong = errors.New("text too long for a qr code")
+
+// Border is the quiet zone around a code, in modules, required for it to be scanned
+const Border = 4
+
+// block is the structure of the error correction blocks of a version at level M
+type block struct {
+	ecLen int
+	// data codewords of the blocks in the first group and in the second group, which have one more
+	blocks1, data1, blocks2 int
+}
+
+// versions are the versions 1 to 10 at level M
+var versions = []block{
+	{10, 1, 16, 0}, {16, 1, 28, 0}, {26, 1, 44, 0}, {18, 2, 32, 0}, {24, 2, 43, 0},
+	{16, 4, 27, 0}, {18, 4, 31, 0}, {22, 2, 38, 2}, {22, 3, 36, 2}, {26, 4, 43, 1},
+}
+
+// alignments are the centers of the alignment patterns of the versions
+var alignments = [][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// Code is a QR code, a square of dark and light modules
+type Code struct {
+	Size    int
+	modules [][]bool
+	fn      [][]bool
+}
+
+// Dark tells whether the module at column x and row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode encodes a text as a QR code
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	ver := 0
+	for v := 1; v <= len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*versions[v-1].dataLen() {
+			ver = v
+			break
+		}
+	}
+	if ver == 0 {
+		return nil, fmt.Errorf("%w - %d bytes", ErrTooLong, len(data))
+	}
+
+	c := &Code{Size: 17 + 4*ver}
+	c.modules, c.fn = grid(c.Size), grid(c.Size)
+	c.drawFunctionPatterns(ver)
+	c.drawCodewords(versions[ver-1].codewords(dataCodewords(ver, data)))
+
+	// the mask with the least penalty makes the code easiest to scan
+	best, bestPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		c.applyMask(m)
+		c.drawFormat(m)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+		c.applyMask(m)
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c, nil
+}
+
+// PNG renders the code with each module scale pixels wide, within its quiet zone
+func (c *Code) PNG(scale int) ([]byte, error) {
+	side := (c.Size + 2*Border) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex((x+Border)*scale+px, (y+Border)*scale+py, 1)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a single path of unit modules within its quiet zone, which scales to any size
+func (c *Code) SVG() []byte {
+	side := c.Size + 2*Border
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		side, side)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, side, side)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+Border, y+Border)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (b block) dataLen() int {
+	return b.blocks1*b.data1 + b.blocks2*(b.data1+1)
+}
+
+// dataCodewords are the data of a version, the byte mode header and text followed by the terminator and padding
+func dataCodewords(ver int, data []byte) []byte {
+	var bits bitBuf
+	bits.append(0b0100, 4)
+	if ver >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * versions[ver-1].dataLen()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	out := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// codewords splits the data in blocks, adds their error correction codewords and interleaves them
+func (b block) codewords(data []byte) []byte {
+	var blocks, ecc [][]byte
+	divisor := rsDivisor(b.ecLen)
+	for i := 0; i < b.blocks1+b.blocks2; i++ {
+		n := b.data1
+		if i >= b.blocks1 {
+			n++
+		}
+		blocks = append(blocks, data[:n])
+		ecc = append(ecc, rsRemainder(data[:n], divisor))
+		data = data[n:]
+	}
+	var out []byte
+	for i := 0; i <= b.data1; i++ {
+		for _, blk := range blocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for _, e := range ecc {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.fn[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(ver int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignments[ver-1]
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			// alignment patterns would overlap the finder patterns in three corners
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// format modules are reserved until the mask is chosen
+	c.drawFormat(0)
+	if ver >= 7 {
+		bits := ver<<12 | bchRemainder(ver, 12, 0x1F25)
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern and its separator around a center
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormat draws the two copies of the format of level M with a mask, and the dark module
+func (c *Code) drawFormat(mask int) {
+	// level M is 00 in the format
+	bits := (mask<<10 | bchRemainder(mask, 10, 0x537)) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawCodewords places the codewords in two module wide columns, zigzagging up and down from the right
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		// the vertical timing pattern is skipped over
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.fn[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = data[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by a mask, applying it again undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.fn[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, from runs and blocks of modules of a color,
+// patterns looking like finder patterns and an unbalanced share of dark modules
+func (c *Code) penalty() int {
+	p, dark := 0, 0
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := 0; j < c.Size; j++ {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+			p += linePenalty(line)
+		}
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x < c.Size-1 && y < c.Size-1 {
+				m := c.modules[y][x]
+				if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	return p + 10*((abs(dark*20-total*10)+total-1)/total-1)
+}
+
+// finderLike is the 1:1:3:1:1 dark and light ratio of a finder pattern
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+func linePenalty(line []bool) int {
+	p, run := 0, 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+	// finder like patterns with 4 light modules on either side, the quiet zone counts as light
+	at := func(i int) bool { return i >= 0 && i < len(line) && line[i] }
+	for i := -4; i+len(finderLike) <= len(line)+4; i++ {
+		match := true
+		for k, m := range finderLike {
+			if at(i+k) != m {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		before, after := true, true
+		for k := 1; k <= 4; k++ {
+			before = before && !at(i-k)
+			after = after && !at(i+len(finderLike)-1+k)
+		}
+		if before || after {
+			p += 40
+		}
+	}
+	return p
+}
+
+// bchRemainder is the remainder of the BCH code of a value, whose generator has n+1 bits
+func bchRemainder(v, n, generator int) int {
+	rem := v
+	for i := 0; i < n; i++ {
+		rem = rem<<1 ^ (rem>>(n-1))*generator
+	}
+	return rem & (1<<n - 1)
+}
+
+// rsDivisor is the Reed-Solomon generator polynomial of a degree, highest coefficient first without it
+func rsDivisor(degree int) []byte {
+	out := make([]byte, degree)
+	out[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range out {
+			out[j] = gfMul(out[j], root)
+			if j+1 < len(out) {
+				out[j] ^= out[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return out
+}
+
+// rsRemainder is the Reed-Solomon error correction of data
+func rsRemainder(data, divisor []byte) []byte {
+	out := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ out[0]
+		copy(out, out[1:])
+		out[len(out)-1] = 0
+		for i, d := range divisor {
+			out[i] ^= gfMul(d, factor)
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuf []bool
+
+func (b *bitBuf) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,199 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rsRemainder(t *testing.T) {
+	// HELLO WORLD at version 1-M, the worked example of the error correction coding tutorial at thonky.com
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsDivisor(10)))
+}
+
+func Test_bchRemainder(t *testing.T) {
+	// format strings of level M for masks 0 to 7, and version strings of versions 7 to 10, in ISO/IEC 18004
+	formats := []int{0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000}
+	for mask, want := range formats {
+		assert.Equal(t, want, (mask<<10|bchRemainder(mask, 10, 0x537))^0x5412, "mask %d", mask)
+	}
+	for ver, want := range map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3} {
+		assert.Equal(t, want, ver<<12|bchRemainder(ver, 12, 0x1F25), "version %d", ver)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		ver  int
+	}{
+		{name: "short text", text: "HELLO WORLD", ver: 1},
+		{name: "url", text: "https://docs.example.com/v/0b7e3e4c-3a4f-4b4e-9d55-4a3c1f3f5e6a", ver: 5},
+		{name: "long url with version info", text: "https://docs.example.com/v/" + strings.Repeat("a", 90), ver: 7},
+		{name: "two block groups", text: strings.Repeat("b", 150), ver: 8},
+		{name: "three short blocks", text: strings.Repeat("b", 160), ver: 9},
+		{name: "largest", text: strings.Repeat("c", 213), ver: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode(tt.text)
+			require.NoError(t, err)
+			require.Equal(t, 17+4*tt.ver, c.Size)
+			for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+				assertFinder(t, c, corner[0], corner[1])
+			}
+			assert.True(t, c.Dark(8, c.Size-8))
+			assert.Equal(t, tt.text, decode(t, c, tt.ver))
+		})
+	}
+
+	_, err := Encode(strings.Repeat("d", 214))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestCode_render(t *testing.T) {
+	c, err := Encode("HELLO WORLD")
+	require.NoError(t, err)
+
+	b, err := c.PNG(4)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	side := (c.Size + 2*Border) * 4
+	assert.Equal(t, side, img.Bounds().Dx())
+	dark := func(x, y int) bool { r, _, _, _ := img.At(x, y).RGBA(); return r == 0 }
+	assert.False(t, dark(0, 0))
+	assert.True(t, dark(Border*4, Border*4))
+	assert.False(t, dark((Border+1)*4, (Border+1)*4))
+
+	svg := string(c.SVG())
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`))
+	assert.Contains(t, svg, "M4 4h1v1h-1z")
+	assert.NotContains(t, svg, "M5 5h1v1h-1z")
+}
+
+// assertFinder checks the finder pattern with its top left module at x, y
+func assertFinder(t *testing.T, c *Code, x, y int) {
+	for dy := 0; dy < 7; dy++ {
+		for dx := 0; dx < 7; dx++ {
+			ring := min(dx, dy, 6-dx, 6-dy)
+			assert.Equal(t, ring != 1, c.Dark(x+dx, y+dy), "finder at %d,%d module %d,%d", x, y, dx, dy)
+		}
+	}
+}
+
+// decode reads the text back from a code, checking its format and the error correction of its blocks
+func decode(t *testing.T, c *Code, ver int) string {
+	var format int
+	for i := 0; i < 15; i++ {
+		x, y := 8, 0
+		switch {
+		case i <= 5:
+			y = i
+		case i == 6:
+			y = 7
+		case i == 7:
+			y = 8
+		default:
+			x, y = 14-i, 8
+			if i == 8 {
+				x = 7
+			}
+		}
+		if c.Dark(x, y) {
+			format |= 1 << i
+		}
+	}
+	mask := (format ^ 0x5412) >> 10
+	require.Equal(t, 0, mask>>3, "level is not M")
+	require.Equal(t, format, (mask<<10|bchRemainder(mask, 10, 0x537))^0x5412)
+
+	// data modules are the ones which are not function modules of an empty code of the version
+	empty := &Code{Size: c.Size}
+	empty.modules, empty.fn = grid(c.Size), grid(c.Size)
+	empty.drawFunctionPatterns(ver)
+	unmasked := &Code{Size: c.Size, modules: grid(c.Size), fn: empty.fn}
+	for y := range c.modules {
+		copy(unmasked.modules[y], c.modules[y])
+	}
+	unmasked.applyMask(mask)
+
+	b := versions[ver-1]
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if empty.fn[y][x] {
+					continue
+				}
+				cur <<= 1
+				if unmasked.modules[y][x] {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					raw = append(raw, cur)
+				}
+			}
+		}
+	}
+	numBlocks := b.blocks1 + b.blocks2
+	require.GreaterOrEqual(t, len(raw), b.dataLen()+numBlocks*b.ecLen)
+
+	// deinterleave the blocks and check their error correction
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= b.data1; i++ {
+		for j := range blocks {
+			if i < b.data1 || j >= b.blocks1 {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for j := range blocks {
+		ecc := make([]byte, b.ecLen)
+		for i := range ecc {
+			ecc[i] = raw[k+i*numBlocks+j]
+		}
+		assert.Equal(t, rsRemainder(blocks[j], rsDivisor(b.ecLen)), ecc, "block %d", j)
+		data = append(data, blocks[j]...)
+	}
+
+	require.Equal(t, byte(0b0100), data[0]>>4, "not byte mode")
+	bit := func(i int) int { return int(data[i/8]>>(7-i%8)) & 1 }
+	read := func(from, n int) int {
+		v := 0
+		for i := from; i < from+n; i++ {
+			v = v<<1 | bit(i)
+		}
+		return v
+	}
+	countBits := 8
+	if ver >= 10 {
+		countBits = 16
+	}
+	length := read(4, countBits)
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return string(text)
+}
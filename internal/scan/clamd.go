@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vposham/trustdoc/log"
+)
+
+// clamdChunkSize is the size of the chunks a document is streamed to clamd in
+const clamdChunkSize = 64 << 10
+
+// Clamd scans documents with a ClamAV daemon using its INSTREAM command,
+// see https://docs.clamav.net/manual/Usage/Scanning.html#clamd
+type Clamd struct {
+	// Network is tcp or unix, and Address is host:port or the socket path
+	Network string
+	Address string
+	Timeout time.Duration
+
+	// MaxStreamSize is the most clamd accepts in a stream (its StreamMaxLength). Larger documents
+	// are scanned up to it and then read to the end without scanning. 0 sends documents as a whole.
+	MaxStreamSize int64
+}
+
+// Scan streams the document to clamd and parses the verdict
+func (c *Clamd) Scan(ctx context.Context, doc io.Reader) (Result, error) {
+	logger := log.GetLogger(ctx)
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() { _ = conn.Close() }()
+	// scanning large documents takes a while, give up on it as soon as the request is gone
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if _, err = conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("failed to start clamd stream - %w", err)
+	}
+	var res Result
+	var sent int64
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, rErr := io.ReadFull(doc, buf[4:])
+		if c.MaxStreamSize > 0 && sent+int64(n) > c.MaxStreamSize {
+			n, res.Partial = int(c.MaxStreamSize-sent), true
+		}
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err = conn.Write(buf[:4+n]); err != nil {
+				return Result{}, fmt.Errorf("failed to stream to clamd - %w", err)
+			}
+			sent += int64(n)
+		}
+		if errors.Is(rErr, io.EOF) || errors.Is(rErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if rErr != nil {
+			return Result{}, fmt.Errorf("failed to read document - %w", rErr)
+		}
+	}
+	if _, err = conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, fmt.Errorf("failed to end clamd stream - %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		res.Infected = true
+		res.Signature = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+	case strings.HasSuffix(reply, "OK"):
+	default:
+		return Result{}, fmt.Errorf("clamd failed to scan - %s", reply)
+	}
+
+	res.Engine, err = c.Version(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	logger.Info("scanned document", zap.String("status", res.Status()), zap.String("signature", res.Signature),
+		zap.Int64("scannedBytes", sent), zap.String("engine", res.Engine))
+	return res, nil
+}
+
+// Version returns the clamd engine and signature database version
+func (c *Clamd) Version(ctx context.Context) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = conn.Write([]byte("zVERSION\x00")); err != nil {
+		return "", fmt.Errorf("failed to get clamd version - %w", err)
+	}
+	return readReply(conn)
+}
+
+// dial connects to clamd, the connection times out after the timeout or when ctx is done
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	d := net.Dialer{Timeout: c.Timeout}
+	conn, err := d.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd - %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if c.Timeout > 0 && (!ok || time.Now().Add(c.Timeout).Before(deadline)) {
+		deadline, ok = time.Now().Add(c.Timeout), true
+	}
+	if ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// readReply reads a null terminated clamd reply
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("failed to read clamd reply - %w", err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
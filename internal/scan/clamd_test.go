@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEngine = "ClamAV 1.2.1/27187/Tue Feb 13 08:25:43 2024"
+
+// stubClamd is a local clamd speaking just enough of its protocol for the client. Streams
+// containing "EICAR" are infected, and streams containing "BROKEN" fail to scan.
+type stubClamd struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	received []byte
+}
+
+func newStubClamd(t *testing.T) *stubClamd {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &stubClamd{ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *stubClamd) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case "zVERSION\x00":
+		_, _ = conn.Write([]byte(testEngine + "\x00"))
+	case "zINSTREAM\x00":
+		var stream bytes.Buffer
+		for {
+			var size uint32
+			if binary.Read(r, binary.BigEndian, &size) != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err = io.CopyN(&stream, r, int64(size)); err != nil {
+				return
+			}
+		}
+		s.mu.Lock()
+		s.received = stream.Bytes()
+		s.mu.Unlock()
+		switch {
+		case bytes.Contains(stream.Bytes(), []byte("EICAR")):
+			_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		case bytes.Contains(stream.Bytes(), []byte("BROKEN")):
+			_, _ = conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+		default:
+			_, _ = conn.Write([]byte("stream: OK\x00"))
+		}
+	}
+}
+
+func (s *stubClamd) lastReceived() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.received)
+}
+
+func TestClamd_Scan(t *testing.T) {
+	ctx := context.Background()
+	stub := newStubClamd(t)
+	c := &Clamd{Network: "tcp", Address: stub.ln.Addr().String(), Timeout: 5 * time.Second}
+
+	t.Run("clean", func(t *testing.T) {
+		doc := strings.Repeat("a clean document. ", 10000)
+		res, err := c.Scan(ctx, strings.NewReader(doc))
+		require.NoError(t, err)
+		assert.False(t, res.Infected)
+		assert.Equal(t, StatusClean, res.Status())
+		assert.Equal(t, testEngine, res.Engine)
+		assert.Equal(t, doc, stub.lastReceived())
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		res, err := c.Scan(ctx, strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+		require.NoError(t, err)
+		assert.True(t, res.Infected)
+		assert.Equal(t, "Eicar-Test-Signature", res.Signature)
+		assert.Equal(t, StatusInfected, res.Status())
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		_, err := c.Scan(ctx, strings.NewReader("BROKEN"))
+		assert.ErrorContains(t, err, "size limit exceeded")
+	})
+
+	t.Run("larger than max stream size", func(t *testing.T) {
+		partial := &Clamd{Network: "tcp", Address: c.Address, Timeout: c.Timeout, MaxStreamSize: 100}
+		doc := strings.Repeat("x", 100) + "EICAR"
+		r := strings.NewReader(doc)
+		res, err := partial.Scan(ctx, r)
+		require.NoError(t, err)
+		assert.False(t, res.Infected)
+		assert.Equal(t, StatusCleanPartial, res.Status())
+		assert.Equal(t, doc[:100], stub.lastReceived())
+		assert.Zero(t, r.Len(), "the rest of the document is still read")
+	})
+
+	t.Run("clamd down", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		_ = ln.Close()
+		_, err = (&Clamd{Network: "tcp", Address: addr, Timeout: time.Second}).Scan(ctx, strings.NewReader("doc"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,52 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// scan statuses recorded for a document
+const (
+	StatusClean        = "CLEAN"
+	StatusCleanPartial = "CLEAN_PARTIAL"
+	StatusInfected     = "INFECTED"
+)
+
+// Scanner scans document content for malware
+type Scanner interface {
+	// Scan reads doc till the end and reports whether malware was found in it
+	Scan(ctx context.Context, doc io.Reader) (Result, error)
+}
+
+// Result is the outcome of scanning a document
+type Result struct {
+	Infected bool
+	// Signature is the name of the malware found
+	Signature string
+	// Engine is the scan engine and signature database version
+	Engine string
+	// Partial is true when the document was larger than the scanner accepts, and only its start was scanned
+	Partial bool
+}
+
+// Status is the scan status recorded for a document
+func (r Result) Status() string {
+	switch {
+	case r.Infected:
+		return StatusInfected
+	case r.Partial:
+		return StatusCleanPartial
+	default:
+		return StatusClean
+	}
+}
+
+// InfectedError is returned for documents which carry malware
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("document is infected with %s", e.Signature)
+}
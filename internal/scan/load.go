@@ -0,0 +1,47 @@
+// Package scan will contain the interfaces and implementations of malware scanning
+package scan
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vposham/trustdoc/config"
+)
+
+var (
+	onceInit      = new(sync.Once)
+	concreteImpls = make(map[string]any)
+)
+
+const (
+	// scanExecKey implementation makes calls to clamd
+	scanExecKey = "ClamdScanExecKey"
+)
+
+// Load enables us inject this package as dependency from its parent
+func Load(ctx context.Context) error {
+	var appErr error
+	onceInit.Do(func() {
+		appErr = loadImpls(ctx)
+	})
+	return appErr
+}
+
+func loadImpls(_ context.Context) error {
+	props := config.GetAll()
+	if concreteImpls[scanExecKey] == nil {
+		var scanExec Scanner = &Clamd{
+			Network:       props.GetString("scan.clamd.network", "tcp"),
+			Address:       props.MustGetString("scan.clamd.address"),
+			Timeout:       props.MustGetParsedDuration("scan.clamd.timeout.dur"),
+			MaxStreamSize: props.MustGetInt64("scan.clamd.max.stream.size"),
+		}
+		concreteImpls[scanExecKey] = scanExec
+	}
+	return nil
+}
+
+// GetScanner is used to get the malware scanner
+func GetScanner() Scanner {
+	return concreteImpls[scanExecKey].(Scanner)
+}
@@ -0,0 +1,168 @@
+// Package receipt issues and validates signed verification receipts. A receipt is a JWS (RFC 7515) in compact
+// serialization, signed with an Ed25519 service key (RFC 8037). The service publishes its public keys as a
+// JWKS (RFC 7517), with which anyone holding a receipt can validate it without calling the service.
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Alg is the JWS algorithm receipts are signed with
+	Alg = "EdDSA"
+
+	// Type is the JWS type of receipts, which tells them apart from other tokens signed with the same key
+	Type = "verification-receipt+jwt"
+)
+
+// ErrInvalid is returned when a receipt is malformed or not signed by any of the keys it is validated with
+var ErrInvalid = errors.New("invalid receipt")
+
+// Claims is what a receipt attests, the result of verifying a document against its blockchain anchor
+type Claims struct {
+	// Id identifies the receipt, Issuer the service which signed it and IssuedAt is when, in unix seconds
+	Id       string `json:"jti"`
+	Issuer   string `json:"iss"`
+	IssuedAt int64  `json:"iat"`
+
+	DocId    string `json:"docId,omitempty"`
+	DocHash  string `json:"docHash"`
+	HashAlgo string `json:"hashAlgo"`
+
+	// BcTknId is the tkn the document was verified with, which minted TokenId in the contract on the chain
+	BcTknId         string `json:"bcTknId"`
+	TokenId         string `json:"tokenId,omitempty"`
+	ContractAddress string `json:"contractAddress,omitempty"`
+	ChainId         int64  `json:"chainId,omitempty"`
+
+	Verified bool     `json:"verified"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// JWK is an Ed25519 public key as a JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS is a set of public keys receipts are validated with
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Signer signs receipts with the service key
+type Signer struct {
+	key ed25519.PrivateKey
+	jwk JWK
+}
+
+// NewSigner creates a signer from the 32 byte seed of an Ed25519 key
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("receipt signing key must be %d bytes, not %d", ed25519.SeedSize, len(seed))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	return &Signer{key: key, jwk: PublicJWK(key.Public().(ed25519.PublicKey))}, nil
+}
+
+// Keys returns the public key of the signer, as it is published for receipts to be validated with
+func (s *Signer) Keys() JWKS {
+	return JWKS{Keys: []JWK{s.jwk}}
+}
+
+// Sign signs the claims into a receipt
+func (s *Signer) Sign(c Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: Alg, Typ: Type, Kid: s.jwk.Kid})
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	input := b64(h) + "." + b64(p)
+	return input + "." + b64(ed25519.Sign(s.key, []byte(input))), nil
+}
+
+// Validate checks that a receipt is signed by one of the keys, such as the ones the service publishes
+// at /.well-known/jwks.json, and returns what it attests. It does not call the service.
+func Validate(receipt string, keys JWKS) (Claims, error) {
+	var c Claims
+	parts := strings.Split(receipt, ".")
+	if len(parts) != 3 {
+		return c, fmt.Errorf("%w - not a compact jws", ErrInvalid)
+	}
+	var h header
+	if err := decode(parts[0], &h); err != nil {
+		return c, fmt.Errorf("%w - malformed header - %w", ErrInvalid, err)
+	}
+	if h.Alg != Alg || h.Typ != Type {
+		return c, fmt.Errorf("%w - unexpected alg %q or typ %q", ErrInvalid, h.Alg, h.Typ)
+	}
+	pub, err := keys.key(h.Kid)
+	if err != nil {
+		return c, fmt.Errorf("%w - %w", ErrInvalid, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return c, fmt.Errorf("%w - signature does not match", ErrInvalid)
+	}
+	if err = decode(parts[1], &c); err != nil {
+		return c, fmt.Errorf("%w - malformed claims - %w", ErrInvalid, err)
+	}
+	return c, nil
+}
+
+// PublicJWK is the JWK of an Ed25519 public key, identified by its RFC 7638 thumbprint
+func PublicJWK(pub ed25519.PublicKey) JWK {
+	x := b64(pub)
+	// the thumbprint is the hash of the required members of the key, in lexicographic order
+	thumbprint := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return JWK{Kty: "OKP", Crv: "Ed25519", X: x, Kid: b64(thumbprint[:]), Alg: Alg, Use: "sig"}
+}
+
+// key finds the public key with a kid
+func (s JWKS) key(kid string) (ed25519.PublicKey, error) {
+	for _, k := range s.Keys {
+		if k.Kid != kid {
+			continue
+		}
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("key %q is not an Ed25519 key", kid)
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q is malformed", kid)
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("key %q is unknown", kid)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
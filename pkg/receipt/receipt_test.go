@@ -0,0 +1,66 @@
+package receipt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignValidate(t *testing.T) {
+	s, err := NewSigner(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	require.NoError(t, err)
+	claims := Claims{Id: "r-1", Issuer: "trustdoc", IssuedAt: 1714559400, DocId: "doc-1",
+		DocHash: "781e5e245d69b566979b86e28d23f2c7", HashAlgo: "md5", BcTknId: "0xabc", TokenId: "7",
+		ContractAddress: "0xdef", ChainId: 1337, Verified: true}
+	r, err := s.Sign(claims)
+	require.NoError(t, err)
+
+	got, err := Validate(r, s.Keys())
+	require.NoError(t, err)
+	assert.Equal(t, claims, got)
+
+	other, err := NewSigner(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	require.NoError(t, err)
+	forged, err := other.Sign(claims)
+	require.NoError(t, err)
+	parts := strings.Split(r, ".")
+	tampered := `{"verified":false}`
+
+	tests := []struct {
+		name    string
+		receipt string
+		keys    JWKS
+	}{
+		{name: "signed by another key", receipt: forged, keys: s.Keys()},
+		{name: "signature of another key", receipt: parts[0] + "." + parts[1] + "." + strings.Split(forged, ".")[2],
+			keys: s.Keys()},
+		{name: "tampered claims", receipt: parts[0] + "." + b64([]byte(tampered)) + "." + parts[2], keys: s.Keys()},
+		{name: "no signature", receipt: parts[0] + "." + parts[1] + ".", keys: s.Keys()},
+		{name: "not a jws", receipt: "receipt", keys: s.Keys()},
+		{name: "no keys", receipt: r, keys: JWKS{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Validate(tt.receipt, tt.keys)
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestPublicJWK(t *testing.T) {
+	// the Ed25519 key and thumbprint of RFC 8037 appendix A
+	pub, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	require.NoError(t, err)
+	k := PublicJWK(pub)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", k.Kid)
+	assert.Equal(t, JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+		Kid: k.Kid, Alg: Alg, Use: "sig"}, k)
+
+	_, err = NewSigner([]byte("short"))
+	assert.Error(t, err)
+}
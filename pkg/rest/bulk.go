@@ -0,0 +1,58 @@
+package rest
+
+import "github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+
+// results of a bulk upload entry
+const (
+	BulkCreated   = "CREATED"
+	BulkDuplicate = "DUPLICATE"
+	BulkFailed    = "FAILED"
+)
+
+// BulkManifestEntry describes a document of a bulk upload archive, File is its path in the archive
+type BulkManifestEntry struct {
+	File           string `json:"file"`
+	OwnerEmail     string `json:"ownerEmail"`
+	DocTitle       string `json:"docTitle"`
+	DocDesc        string `json:"docDesc"`
+	OwnerFirstName string `json:"ownerFirstName"`
+	OwnerLastName  string `json:"ownerLastName"`
+	// Tags are the document tags, given as tag.<key> columns of a csv manifest
+	Tags map[string]string `json:"tags"`
+	// ValidFrom and ValidUntil are the optional RFC 3339 times the document is valid between
+	ValidFrom  string `json:"validFrom"`
+	ValidUntil string `json:"validUntil"`
+}
+
+// UploadReq is the upload request of a document in a bulk upload archive
+func (e BulkManifestEntry) UploadReq() UploadReq {
+	return UploadReq{
+		OwnerEmail:     e.OwnerEmail,
+		DocTitle:       e.DocTitle,
+		DocDesc:        e.DocDesc,
+		OwnerFirstName: e.OwnerFirstName,
+		OwnerLastName:  e.OwnerLastName,
+		DocTags:        e.Tags,
+		ValidFrom:      e.ValidFrom,
+		ValidUntil:     e.ValidUntil,
+	}
+}
+
+type BulkEntryResult struct {
+	File   string        `json:"file"`
+	Result string        `json:"result"`
+	Doc    *dbtx.DocMeta `json:"doc,omitempty"`
+	// Status is the http status an upload of the entry on its own would have got
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Duplicate is set when the document was already uploaded by another owner
+	Duplicate *DuplicateDoc `json:"duplicate,omitempty"`
+}
+
+type BulkUploadResp struct {
+	Created    int               `json:"created"`
+	Duplicates int               `json:"duplicates"`
+	Failed     int               `json:"failed"`
+	Entries    []BulkEntryResult `json:"entries,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
@@ -0,0 +1,46 @@
+package rest
+
+import "time"
+
+// DuplicateDoc is what an uploader is told of a document which was already uploaded by another owner,
+// along with their claim of co-ownership on it when duplicates are claimed
+type DuplicateDoc struct {
+	DocId       string `json:"docId"`
+	ClaimId     string `json:"claimId,omitempty"`
+	ClaimStatus string `json:"claimStatus,omitempty"`
+}
+
+type ClaimReq struct {
+	ClaimId string `uri:"claimId" binding:"required,uuid"`
+}
+
+// ClaimDecisionReq accepts or rejects a claim, with the one-time token the owner of the claimed document
+// was sent in the doc.claimed event of the claim
+type ClaimDecisionReq struct {
+	DecisionToken string `json:"decisionToken" binding:"required"`
+	Status        string `json:"status" binding:"required,oneof=ACCEPTED REJECTED"`
+}
+
+type ClaimResp struct {
+	ClaimId string `json:"claimId,omitempty"`
+	DocId   string `json:"docId,omitempty"`
+	// Status is PENDING until the claim is ACCEPTED or REJECTED by the owner of the document
+	Status    string     `json:"status,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// DocClaimedEventType is the type of the event telling the owner of a document of a claim of co-ownership on it
+const DocClaimedEventType = "doc.claimed"
+
+// DocClaimedEvent tells the owner of a document of a claim of co-ownership on it. DecisionToken is the one-time
+// token the claim is accepted or rejected with, it is sent only once.
+type DocClaimedEvent struct {
+	Type          string    `json:"type"`
+	ClaimId       string    `json:"claimId"`
+	DocId         string    `json:"docId"`
+	DocTitle      string    `json:"docTitle"`
+	OwnerEmail    string    `json:"ownerEmail"`
+	DecisionToken string    `json:"decisionToken"`
+	ClaimedAt     time.Time `json:"claimedAt"`
+}
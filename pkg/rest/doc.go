@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+)
+
+// states of the blockchain anchor of a document, besides its token not being found
+const (
+	// AnchorPending is the state of a document which is not minted yet
+	AnchorPending = "pending"
+	// Anchored is the state of a document whose hashes are the ones anchored with its token
+	Anchored = "anchored"
+	// AnchorMismatch is the state of a document whose hashes are not the ones anchored with its token
+	AnchorMismatch = "mismatch"
+	// AnchorUnavailable is the state of a document whose anchor could not be read from the blockchain
+	AnchorUnavailable = "unavailable"
+)
+
+// DocReq is the caller of a document lookup, the owner of the document is only shown to the owner.
+// OwnerEmail is not authenticated, it only hides the owner from callers who do not know their email.
+type DocReq struct {
+	OwnerEmail string `form:"ownerEmail" binding:"omitempty,email"`
+}
+
+// DocByTknReq looks up a document by the blockchain token it was minted with
+type DocByTknReq struct {
+	TokenId string `uri:"tokenId" binding:"required"`
+}
+
+type DocResp struct {
+	Doc    *dbtx.DocMeta `json:"doc,omitempty"`
+	Anchor *AnchorStatus `json:"anchor,omitempty"`
+
+	// Redacted lists the fields of the document which are left out or masked for the caller
+	Redacted []string `json:"redacted,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// AnchorStatus is the state of the blockchain anchor of a document, Anchor is where it is anchored
+// once its token is found
+type AnchorStatus struct {
+	State    string  `json:"state"`
+	Anchor   *Anchor `json:"anchor,omitempty"`
+	Validity string  `json:"validity,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// ListDocsReq lists the documents of an owner, uploaded between the RFC 3339 times UploadedAfter and
+// UploadedBefore. Cursor is the NextCursor of the previous page, which is listed with the same filters.
+type ListDocsReq struct {
+	Owner          string    `form:"owner" binding:"required,email"`
+	UploadedAfter  time.Time `form:"uploadedAfter"`
+	UploadedBefore time.Time `form:"uploadedBefore"`
+	Status         string    `form:"status" binding:"omitempty,oneof=active deleted revoked"`
+	// Tags are key:value filters which documents need to match all of
+	Tags   []string `form:"tag"`
+	Limit  int      `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string   `form:"cursor"`
+}
+
+type ListDocsResp struct {
+	Owner string         `json:"owner,omitempty"`
+	Docs  []dbtx.DocMeta `json:"docs"`
+	// Redacted lists the fields of the documents which are left out or masked for the caller
+	Redacted []string `json:"redacted,omitempty"`
+	// NextCursor lists the page of documents after this one, it is left out on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,16 @@
+package rest
+
+import "time"
+
+// DocExpiringEventType is the type of the event announcing a document which is about to expire
+const DocExpiringEventType = "doc.expiring"
+
+// DocExpiringEvent announces a document whose validity ends soon, so its owner can renew it
+type DocExpiringEvent struct {
+	Type       string    `json:"type"`
+	DocId      string    `json:"docId"`
+	DocTitle   string    `json:"docTitle"`
+	OwnerEmail string    `json:"ownerEmail"`
+	BcTknId    string    `json:"bcTknId"`
+	ValidUntil time.Time `json:"validUntil"`
+}
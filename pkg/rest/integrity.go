@@ -0,0 +1,29 @@
+package rest
+
+import "time"
+
+// DocIntegrityEventType is the type of the event raised for a document which failed an integrity check
+const DocIntegrityEventType = "doc.integrity.failed"
+
+// integrity checks of a document
+const (
+	// ContentCheck compares the content of a document in blob store with its hash
+	ContentCheck = "content"
+	// AnchorCheck compares the hash of a document with the one anchored in blockchain
+	AnchorCheck = "anchor"
+	// PresenceCheck looks for the content of a document which is still served in blob store
+	PresenceCheck = "presence"
+)
+
+// DocIntegrityEvent alerts of a document which no longer is what was uploaded, its content is missing from
+// blob store or does not match its hash, or its hash does not match the one anchored in blockchain
+type DocIntegrityEvent struct {
+	Type    string `json:"type"`
+	Check   string `json:"check"`
+	DocId   string `json:"docId"`
+	DocHash string `json:"docHash"`
+	// ContentHash is the hash of the content found in blob store, for failed content checks
+	ContentHash string    `json:"contentHash,omitempty"`
+	BcTknId     string    `json:"bcTknId,omitempty"`
+	DetectedAt  time.Time `json:"detectedAt"`
+}
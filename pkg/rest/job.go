@@ -0,0 +1,20 @@
+package rest
+
+import "github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+
+type JobReq struct {
+	JobId string `uri:"jobId" binding:"required,uuid"`
+}
+
+type JobResp struct {
+	JobId string `json:"jobId,omitempty"`
+	// Status is IN_PROGRESS until the job is DONE or FAILED
+	Status string `json:"status,omitempty"`
+	// Step is the step an in progress job is at, one of HASHING, STORAGE, MINTING, CONFIRMATION and SAVING
+	Step string `json:"step,omitempty"`
+	// CompletedSteps are the steps the job went through, in order
+	CompletedSteps []string `json:"completedSteps,omitempty"`
+	// Doc is the doc of a job which is done
+	Doc   *dbtx.DocMeta `json:"doc,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
@@ -0,0 +1,7 @@
+package rest
+
+// QrReq is the image a QR code is rendered as, a png with modules of Scale pixels by default
+type QrReq struct {
+	Format string `form:"format" binding:"omitempty,oneof=png svg"`
+	Scale  int    `form:"scale" binding:"omitempty,min=1,max=32"`
+}
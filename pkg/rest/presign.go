@@ -0,0 +1,43 @@
+package rest
+
+import "time"
+
+// PresignDownloadReq asks for a presigned download url of a document, its owner is recognised by their email
+type PresignDownloadReq struct {
+	OwnerEmail string `json:"ownerEmail" binding:"required,email"`
+}
+
+// PresignResp carries a presigned blob store url, which needs no credentials until it expires
+type PresignResp struct {
+	Url       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// DirectUploadReq creates a direct-to-blob upload. The document metadata is sent as with Upload, along with
+// the name and size of the document the client puts in blob store.
+type DirectUploadReq struct {
+	OwnerEmail     string `json:"ownerEmail"`
+	DocTitle       string `json:"docTitle"`
+	DocDesc        string `json:"docDesc"`
+	OwnerFirstName string `json:"ownerFirstName"`
+	OwnerLastName  string `json:"ownerLastName"`
+	Tags           string `json:"tags"`
+	ValidFrom      string `json:"validFrom"`
+	ValidUntil     string `json:"validUntil"`
+	FileName       string `json:"fileName" binding:"required"`
+	Size           int64  `json:"size" binding:"required,gt=0"`
+}
+
+// DirectUploadResp carries the presigned url the document is put at, with an http PUT of its content,
+// before the upload is finalised
+type DirectUploadResp struct {
+	UploadId  string     `json:"uploadId,omitempty"`
+	Url       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type DirectUploadUriReq struct {
+	UploadId string `uri:"uploadId" binding:"required,uuid"`
+}
@@ -0,0 +1,14 @@
+package rest
+
+// ProblemContentType is the media type of problem details, RFC 9457
+const ProblemContentType = "application/problem+json"
+
+// Problem details an error response as in RFC 9457. The type is about:blank, so the title is the
+// http status text and the detail explains this occurrence of the problem.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
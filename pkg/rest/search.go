@@ -0,0 +1,18 @@
+package rest
+
+import "github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+
+type SearchReq struct {
+	Email string `uri:"email" binding:"required,email"`
+	// Tags are key:value filters which documents need to match all of
+	Tags  []string `form:"tag"`
+	Limit int      `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type SearchResp struct {
+	Email string         `json:"email,omitempty"`
+	Docs  []dbtx.DocMeta `json:"docs"`
+	// Redacted lists the fields of the documents which are left out or masked for the caller
+	Redacted []string `json:"redacted,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
@@ -0,0 +1,5 @@
+package rest
+
+type TusUploadReq struct {
+	UploadId string `uri:"uploadId" binding:"required,uuid"`
+}
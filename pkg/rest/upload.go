@@ -2,8 +2,10 @@ package rest
 
 import (
 	"mime/multipart"
+	"time"
 
 	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/internal/policy"
 )
 
 type UploadReq struct {
@@ -12,14 +14,38 @@ type UploadReq struct {
 	DocDesc        string `form:"docDesc" json:"docDesc"`
 	OwnerFirstName string `form:"ownerFirstName" json:"ownerFirstName" binding:"required,alpha,min=3"`
 	OwnerLastName  string `form:"ownerLastName" json:"ownerLastName" binding:"required,alpha,min=3"`
+	// Tags is a json object of free-form string metadata, such as {"issuer":"acme"}
+	Tags string `form:"tags" json:"tags"`
+	// ValidFrom and ValidUntil are the optional RFC 3339 times the document, such as a certificate, is valid between
+	ValidFrom  string `form:"validFrom" json:"validFrom"`
+	ValidUntil string `form:"validUntil" json:"validUntil"`
 
 	// below items not sent via client
 	MpFileHeader      *multipart.FileHeader
 	OwnerEmailMd5Hash string
 	DocMd5Hash        string
+	DocHashAlgo       string
+	DocTags           map[string]string
+	DocValidFrom      *time.Time
+	DocValidUntil     *time.Time
 }
 
 type UploadResp struct {
 	Doc   *dbtx.DocMeta `json:"doc"`
 	Error string        `json:"error,omitempty"`
+
+	// JobId is the upload job of an asynchronous upload, which reports its progress
+	JobId string `json:"jobId,omitempty"`
+
+	// SimilarTo lists existing documents which are visually similar to the uploaded image
+	SimilarTo []dbtx.SimilarDoc `json:"similarTo,omitempty"`
+
+	// Violation details why a document was rejected by the upload policy
+	Violation *policy.Violation `json:"violation,omitempty"`
+
+	// Malware is the signature of the malware found in a rejected document
+	Malware string `json:"malware,omitempty"`
+
+	// Duplicate is set when the document was already uploaded by another owner
+	Duplicate *DuplicateDoc `json:"duplicate,omitempty"`
 }
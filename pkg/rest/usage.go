@@ -0,0 +1,15 @@
+package rest
+
+import "github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+
+type UsageReq struct {
+	Email string `uri:"email" binding:"required,email"`
+}
+
+type UsageResp struct {
+	Email string      `json:"email,omitempty"`
+	Usage *dbtx.Usage `json:"usage,omitempty"`
+	// Quota is the per-owner upload quota, zero limits are unlimited
+	Quota *dbtx.Usage `json:"quota,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
@@ -2,6 +2,10 @@ package rest
 
 import (
 	"mime/multipart"
+	"time"
+
+	"github.com/vposham/trustdoc/internal/db/sqlc/dbtx"
+	"github.com/vposham/trustdoc/pkg/receipt"
 )
 
 type VerifyReq struct {
@@ -12,9 +16,118 @@ type VerifyReq struct {
 	MpFileHeader      *multipart.FileHeader
 	OwnerEmailMd5Hash string
 	DocMd5Hash        string
+	DocHashAlgo       string
+}
+
+// VerifyHashReq verifies a document by its digest. The document anchored with the digest is looked up
+// when TokenId is left out, and its owner is verified when OwnerEmail is left out.
+type VerifyHashReq struct {
+	// Algorithm is the hash algo of the digest, the one the document was anchored with
+	Algorithm  string `json:"algorithm" binding:"required,oneof=md5 merkle-sha256"`
+	Digest     string `json:"digest" binding:"required,hexadecimal"`
+	OwnerEmail string `json:"ownerEmail" binding:"omitempty,email"`
+	TokenId    string `json:"tokenId"`
 }
 
+// validity states of a verified document
+const (
+	Valid       = "valid"
+	Expired     = "expired"
+	NotYetValid = "notYetValid"
+)
+
+// reasons a document is not verified, besides it being expired or not yet valid
+const (
+	TokenNotFound   = "tokenNotFound"
+	ContentMismatch = "contentMismatch"
+	OwnerMismatch   = "ownerMismatch"
+	Revoked         = "revoked"
+)
+
 type VerifyResp struct {
 	Verified bool   `json:"verified"`
 	Error    string `json:"error,omitempty"`
+
+	// Reasons tell why a document is not verified, and Report details what was checked of it
+	Reasons []string      `json:"reasons,omitempty"`
+	Report  *VerifyReport `json:"report,omitempty"`
+
+	// Receipt is a signed receipt of the verification, which third parties validate with the published keys
+	Receipt string `json:"receipt,omitempty"`
+
+	// Validity is the state of a document anchored with a validity window, a document which is expired
+	// or not yet valid is not verified
+	Validity   string     `json:"validity,omitempty"`
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+
+	// SimilarTo lists existing documents which are visually similar to the verified image
+	SimilarTo []dbtx.SimilarDoc `json:"similarTo,omitempty"`
+}
+
+// VerifyReport details what matched of a document and its owner against what is anchored with its token
+type VerifyReport struct {
+	ContentMatch bool `json:"contentMatch"`
+	OwnerMatch   bool `json:"ownerMatch"`
+	TokenExists  bool `json:"tokenExists"`
+	Revoked      bool `json:"revoked"`
+	Expired      bool `json:"expired"`
+
+	// Anchor is where the token is anchored, it is nil when the token does not exist
+	Anchor *Anchor `json:"anchor,omitempty"`
+}
+
+// Anchor is the block, contract and chain a document token is anchored in
+type Anchor struct {
+	TokenId         string    `json:"tokenId"`
+	TxHash          string    `json:"txHash"`
+	BlockNumber     uint64    `json:"blockNumber"`
+	AnchoredAt      time.Time `json:"anchoredAt"`
+	ContractAddress string    `json:"contractAddress"`
+	ChainId         int64     `json:"chainId"`
+}
+
+// ValidateReceiptReq validates a verification receipt issued by the service
+type ValidateReceiptReq struct {
+	Receipt string `json:"receipt" binding:"required"`
+}
+
+// ValidateReceiptResp tells whether a receipt is signed by the service, and what it attests when it is
+type ValidateReceiptResp struct {
+	Valid  bool            `json:"valid"`
+	Claims *receipt.Claims `json:"claims,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// NdjsonContentType is the content type of responses streamed as newline delimited json, one value a line
+const NdjsonContentType = "application/x-ndjson"
+
+// BatchVerifyReq verifies many documents by their digests at once
+type BatchVerifyReq struct {
+	Items []BatchVerifyItem `json:"items" binding:"required,min=1"`
+}
+
+// BatchVerifyItem verifies a document of a batch by its digest, Id is an optional reference of the client
+// which is returned along with the result of the item
+type BatchVerifyItem struct {
+	Id string `json:"id"`
+	VerifyHashReq
+}
+
+// BatchVerifyResult is the result of verifying a document of a batch. Index is the position of the item in
+// the request, as streamed results are sent in the order they are ready.
+type BatchVerifyResult struct {
+	Index int    `json:"index"`
+	Id    string `json:"id,omitempty"`
+	// Status is the http status a verification of the document on its own would have got
+	Status int `json:"status"`
+	VerifyResp
+}
+
+type BatchVerifyResp struct {
+	Verified   int                 `json:"verified"`
+	Unverified int                 `json:"unverified"`
+	Failed     int                 `json:"failed"`
+	Items      []BatchVerifyResult `json:"items,omitempty"`
+	Error      string              `json:"error,omitempty"`
 }